@@ -0,0 +1,107 @@
+// Package audit keeps a tamper-evident record of changes to users. Each
+// event holds who made the change, the RPC, the user changed, a field-level
+// diff and the request ID, and is chained to the previous event by hash.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/paulstuart/grpc-example/logging"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+// Sink stores audit events. Sinks only append: Append assigns the event's
+// sequence number and seals it to the last event in the sink.
+type Sink interface {
+	// Append seals event to the chain and stores it
+	Append(ctx context.Context, event *pb.AuditEvent) error
+
+	// List returns events matching the filter, oldest first
+	List(ctx context.Context, filter *Filter) ([]*pb.AuditEvent, error)
+}
+
+// Filter selects audit events; zero fields match everything
+type Filter struct {
+	TargetID      uint32
+	Actor         string
+	Method        string
+	Since         time.Time
+	Until         time.Time
+	AfterSequence uint64
+	Limit         int
+}
+
+// Match reports whether event passes the filter, ignoring Limit
+func (f *Filter) Match(event *pb.AuditEvent) bool {
+	if f == nil {
+		return true
+	}
+	t := event.GetTime().AsTime()
+	return (f.TargetID == 0 || event.TargetId == f.TargetID) &&
+		(f.Actor == "" || event.Actor == f.Actor) &&
+		(f.Method == "" || event.Method == f.Method) &&
+		(f.Since.IsZero() || !t.Before(f.Since)) &&
+		(f.Until.IsZero() || t.Before(f.Until)) &&
+		event.Sequence > f.AfterSequence
+}
+
+// ActorFunc names the caller of a request, e.g. from its JWT claims
+type ActorFunc func(ctx context.Context) string
+
+// Config configures a Log
+type Config struct {
+	// Actor names who made a change; nil records no actor
+	Actor ActorFunc
+	// Redactor hides sensitive values in diffs; nil means
+	// logging.DefaultRedactor
+	Redactor *logging.Redactor
+}
+
+// Log records changes to a Sink. A nil *Log records nothing, so callers
+// needn't check whether auditing is configured.
+type Log struct {
+	sink     Sink
+	actor    ActorFunc
+	redactor *logging.Redactor
+}
+
+// New creates a Log that writes to sink
+func New(sink Sink, cfg Config) *Log {
+	if cfg.Redactor == nil {
+		cfg.Redactor = logging.DefaultRedactor()
+	}
+	return &Log{sink: sink, actor: cfg.Actor, redactor: cfg.Redactor}
+}
+
+// Record appends an event for a change made by method to the user with the
+// given ID. before is nil for creations and after is nil for removals.
+func (l *Log) Record(ctx context.Context, method string, id uint32, before, after proto.Message) error {
+	if l == nil {
+		return nil
+	}
+	event := &pb.AuditEvent{
+		// Postgres keeps microseconds, the hash has to survive a round trip
+		Time:      timestamppb.New(time.Now().Truncate(time.Microsecond)),
+		Method:    method,
+		TargetId:  id,
+		Changes:   Diff(before, after, l.redactor),
+		RequestId: requestid.FromContext(ctx),
+	}
+	if l.actor != nil {
+		event.Actor = l.actor(ctx)
+	}
+	return l.sink.Append(ctx, event)
+}
+
+// List returns events matching the filter, oldest first
+func (l *Log) List(ctx context.Context, filter *Filter) ([]*pb.AuditEvent, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return l.sink.List(ctx, filter)
+}
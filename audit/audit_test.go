@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paulstuart/grpc-example/logging"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestDiff(t *testing.T) {
+	before := &pb.User{Id: 1, Username: "bob", Email: "bob@example.com", Profile: &pb.Profile{DisplayName: "Bob"}}
+	after := &pb.User{Id: 1, Username: "robert", Email: "robert@example.com", Profile: &pb.Profile{DisplayName: "Bob", Bio: "hi"}}
+
+	changes := Diff(before, after, logging.DefaultRedactor())
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, "email", changes[0].Field)
+	assert.Equal(t, logging.Redacted, changes[0].Before.GetStringValue())
+	assert.Equal(t, logging.Redacted, changes[0].After.GetStringValue())
+
+	assert.Equal(t, "profile.bio", changes[1].Field)
+	assert.Nil(t, changes[1].Before)
+	assert.Equal(t, "hi", changes[1].After.GetStringValue())
+
+	assert.Equal(t, "username", changes[2].Field)
+	assert.Equal(t, "bob", changes[2].Before.GetStringValue())
+	assert.Equal(t, "robert", changes[2].After.GetStringValue())
+
+	assert.Len(t, Diff(nil, after, nil), 5, "creation lists every set field")
+}
+
+func TestLogChain(t *testing.T) {
+	ctx := context.Background()
+	sink := NewMemorySink()
+	log := New(sink, Config{Actor: func(context.Context) string { return "alice" }})
+
+	require.NoError(t, log.Record(ctx, "AddUser", 1, nil, &pb.User{Id: 1, Username: "admin"}))
+	require.NoError(t, log.Record(ctx, "AddUser", 2, nil, &pb.User{Id: 2, Username: "bob"}))
+	require.NoError(t, log.Record(ctx, "UpdateUser", 2, &pb.User{Id: 2, Username: "bob"}, &pb.User{Id: 2, Username: "rob"}))
+
+	events, err := log.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, uint64(3), events[2].Sequence)
+	assert.Equal(t, "alice", events[2].Actor)
+	assert.Empty(t, events[0].PrevHash)
+	require.NoError(t, Verify(events))
+
+	filtered, err := log.List(ctx, &Filter{TargetID: 2, Method: "UpdateUser"})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, uint64(3), filtered[0].Sequence)
+
+	events[1].Changes[0].After.Kind = nil
+	assert.ErrorContains(t, Verify(events), "audit event 2: hash mismatch")
+
+	events, err = log.List(ctx, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, Verify([]*pb.AuditEvent{events[0], events[2]}), "follows event 1")
+
+	var nilLog *Log
+	assert.NoError(t, nilLog.Record(ctx, "AddUser", 1, nil, nil))
+}
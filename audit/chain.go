@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// Hash returns the hex SHA-256 of event with its hash unset. It covers the
+// previous event's hash, which is what chains the log together.
+func Hash(event *pb.AuditEvent) (string, error) {
+	unsealed := proto.Clone(event).(*pb.AuditEvent)
+	unsealed.Hash = ""
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(unsealed)
+	if err != nil {
+		return "", fmt.Errorf("hash audit event %d: %w", event.Sequence, err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Seal numbers event to follow prev, which is nil for the first event, and
+// sets its hashes
+func Seal(event, prev *pb.AuditEvent) error {
+	event.Sequence = prev.GetSequence() + 1
+	event.PrevHash = prev.GetHash()
+	hash, err := Hash(event)
+	if err != nil {
+		return err
+	}
+	event.Hash = hash
+	return nil
+}
+
+// Verify checks that events, oldest first, are consecutive and that each
+// hash matches its event and links to the one before. The first event is
+// trusted to follow whatever came before it, so a filtered listing can be
+// verified by fetching from the first event onward.
+func Verify(events []*pb.AuditEvent) error {
+	for i, event := range events {
+		hash, err := Hash(event)
+		if err != nil {
+			return err
+		}
+		if hash != event.Hash {
+			return fmt.Errorf("audit event %d: hash mismatch", event.Sequence)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := events[i-1]
+		if event.Sequence != prev.Sequence+1 {
+			return fmt.Errorf("audit event %d: follows event %d", event.Sequence, prev.Sequence)
+		}
+		if event.PrevHash != prev.Hash {
+			return fmt.Errorf("audit event %d: previous hash mismatch", event.Sequence)
+		}
+	}
+	return nil
+}
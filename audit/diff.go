@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/paulstuart/grpc-example/logging"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// Diff lists the fields that differ between before and after, either of
+// which may be nil. Nested messages such as the profile are compared field
+// by field; lists, maps and well-known types are compared whole. Values of
+// fields the redactor considers sensitive are recorded as logging.Redacted.
+func Diff(before, after proto.Message, redactor *logging.Redactor) []*pb.FieldChange {
+	raw := [2]map[string]any{flatten(before), flatten(after)}
+	var redacted [2]map[string]any
+	if redactor != nil {
+		redacted = [2]map[string]any{flatten(redactor.Message(before)), flatten(redactor.Message(after))}
+	} else {
+		redacted = raw
+	}
+
+	paths := make([]string, 0, len(raw[0])+len(raw[1]))
+	for _, m := range raw {
+		for path := range m {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	var changes []*pb.FieldChange
+	for _, path := range paths {
+		if reflect.DeepEqual(raw[0][path], raw[1][path]) {
+			continue
+		}
+		changes = append(changes, &pb.FieldChange{
+			Field:  path,
+			Before: value(raw[0], redacted[0], path),
+			After:  value(raw[1], redacted[1], path),
+		})
+	}
+	return changes
+}
+
+// value returns the recorded value of path: nil if it's unset, the redacted
+// marker if redaction changed or removed it
+func value(raw, redacted map[string]any, path string) *structpb.Value {
+	v, ok := raw[path]
+	if !ok {
+		return nil
+	}
+	if r, ok := redacted[path]; !ok || !reflect.DeepEqual(v, r) {
+		v = logging.Redacted
+	}
+	pv, err := structpb.NewValue(v)
+	if err != nil {
+		return structpb.NewStringValue(logging.Redacted)
+	}
+	return pv
+}
+
+// flatten maps the dotted proto field paths of msg's populated fields to
+// their JSON values
+func flatten(msg proto.Message) map[string]any {
+	out := map[string]any{}
+	if msg == nil || !msg.ProtoReflect().IsValid() {
+		return out
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return out
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return out
+	}
+	flattenInto(out, msg.ProtoReflect().Descriptor(), fields, "")
+	return out
+}
+
+func flattenInto(out map[string]any, md protoreflect.MessageDescriptor, fields map[string]any, prefix string) {
+	for name, v := range fields {
+		path := prefix + name
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		nested, ok := v.(map[string]any)
+		if ok && fd != nil && fd.Message() != nil && !fd.IsMap() && !fd.IsList() &&
+			!strings.HasPrefix(string(fd.Message().FullName()), "google.protobuf.") {
+			flattenInto(out, fd.Message(), nested, path+".")
+			continue
+		}
+		out[path] = v
+	}
+}
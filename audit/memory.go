@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// MemorySink keeps audit events in memory, for the in-memory storage and tests
+type MemorySink struct {
+	mu     sync.RWMutex
+	events []*pb.AuditEvent
+}
+
+// NewMemorySink creates an empty in-memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Verify that MemorySink implements Sink interface
+var _ Sink = (*MemorySink)(nil)
+
+// Append seals event to the last stored event and stores a copy
+func (m *MemorySink) Append(ctx context.Context, event *pb.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prev *pb.AuditEvent
+	if n := len(m.events); n > 0 {
+		prev = m.events[n-1]
+	}
+	if err := Seal(event, prev); err != nil {
+		return err
+	}
+	m.events = append(m.events, proto.Clone(event).(*pb.AuditEvent))
+	return nil
+}
+
+// List returns copies of the events matching the filter, oldest first
+func (m *MemorySink) List(ctx context.Context, filter *Filter) ([]*pb.AuditEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*pb.AuditEvent
+	for _, event := range m.events {
+		if !filter.Match(event) {
+			continue
+		}
+		result = append(result, proto.Clone(event).(*pb.AuditEvent))
+		if filter != nil && filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// appendLock is the advisory lock key that serializes appends, so that
+// concurrent servers extend the chain one event at a time
+const appendLock = 0x61756469 // "audi"
+
+// PostgresSink stores audit events in the audit_events table. A trigger
+// rejects updates and deletes, so rows can only be appended.
+type PostgresSink struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresSink returns a sink that writes to the audit_events table,
+// created by the server's schema migrations
+func NewPostgresSink(pool *pgxpool.Pool) *PostgresSink {
+	return &PostgresSink{pool: pool}
+}
+
+// Verify that PostgresSink implements Sink interface
+var _ Sink = (*PostgresSink)(nil)
+
+// Append seals event to the last stored event and inserts it
+func (s *PostgresSink) Append(ctx context.Context, event *pb.AuditEvent) error {
+	changes, err := marshalChanges(event.Changes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin audit append: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLock); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	prev := &pb.AuditEvent{}
+	err = tx.QueryRow(ctx, `SELECT sequence, hash FROM audit_events ORDER BY sequence DESC LIMIT 1`).
+		Scan(&prev.Sequence, &prev.Hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		prev = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read last audit event: %w", err)
+	}
+
+	if err := Seal(event, prev); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_events (
+			sequence, time, actor, method, target_id, changes, request_id, prev_hash, hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`,
+		event.Sequence, event.Time.AsTime(), event.Actor, event.Method, event.TargetId,
+		changes, event.RequestId, event.PrevHash, event.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// List returns the events matching the filter, oldest first
+func (s *PostgresSink) List(ctx context.Context, filter *Filter) ([]*pb.AuditEvent, error) {
+	query := `
+		SELECT sequence, time, actor, method, target_id, changes, request_id, prev_hash, hash
+		FROM audit_events
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	argIdx := 1
+
+	if filter != nil {
+		add := func(cond string, arg interface{}) {
+			query += fmt.Sprintf(" AND "+cond, argIdx)
+			args = append(args, arg)
+			argIdx++
+		}
+		if filter.TargetID != 0 {
+			add("target_id = $%d", filter.TargetID)
+		}
+		if filter.Actor != "" {
+			add("actor = $%d", filter.Actor)
+		}
+		if filter.Method != "" {
+			add("method = $%d", filter.Method)
+		}
+		if !filter.Since.IsZero() {
+			add("time >= $%d", filter.Since)
+		}
+		if !filter.Until.IsZero() {
+			add("time < $%d", filter.Until)
+		}
+		if filter.AfterSequence > 0 {
+			add("sequence > $%d", filter.AfterSequence)
+		}
+	}
+
+	query += " ORDER BY sequence"
+
+	if filter != nil && filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argIdx)
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*pb.AuditEvent
+	for rows.Next() {
+		var event pb.AuditEvent
+		var t time.Time
+		var changes []byte
+		err := rows.Scan(
+			&event.Sequence, &t, &event.Actor, &event.Method, &event.TargetId,
+			&changes, &event.RequestId, &event.PrevHash, &event.Hash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit event: %w", err)
+		}
+		event.Time = timestamppb.New(t)
+		if event.Changes, err = unmarshalChanges(changes); err != nil {
+			return nil, fmt.Errorf("audit event %d: %w", event.Sequence, err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
+
+// marshalChanges encodes changes as a JSON array for the changes column
+func marshalChanges(changes []*pb.FieldChange) ([]byte, error) {
+	items := make([]json.RawMessage, len(changes))
+	for i, c := range changes {
+		b, err := protojson.Marshal(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize audit changes: %w", err)
+		}
+		items[i] = b
+	}
+	return json.Marshal(items)
+}
+
+func unmarshalChanges(b []byte) ([]*pb.FieldChange, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, err
+	}
+	var changes []*pb.FieldChange
+	for _, item := range items {
+		var c pb.FieldChange
+		if err := protojson.Unmarshal(item, &c); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &c)
+	}
+	return changes, nil
+}
@@ -113,7 +113,7 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		func(token *jwt.Token) (interface{}, error) {
 			// Verify signing method
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v (%s)", token.Header["alg"], tokenString) // TODO: make this less leaky
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 			return m.secretKey, nil
 		},
@@ -123,7 +123,8 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			return nil, ErrExpiredToken
 		}
-		return nil, fmt.Errorf("%w: %v token: %q", ErrInvalidToken, err, tokenString) // TODO: make this less leaky
+		// Never include the token itself, errors end up in logs and responses
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
@@ -148,7 +149,7 @@ func (m *JWTManager) RefreshToken(tokenString string) (string, error) {
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("%w: %v token: %q", ErrInvalidToken, err, tokenString) // TODO: make this less leaky
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
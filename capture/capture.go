@@ -0,0 +1,287 @@
+// Package capture records sampled RPCs to rotating JSONL files so production
+// traffic can be replayed locally (see cmd/replay).
+//
+// Each line of a capture file is one Record: the method, the incoming
+// metadata minus secrets, the request and response messages as protojson, up
+// to a size limit, the final status and timing.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Record is a single captured RPC
+type Record struct {
+	Time      time.Time           `json:"time"`
+	Method    string              `json:"method"`
+	Kind      string              `json:"kind"`
+	RequestID string              `json:"request_id,omitempty"`
+	Metadata  map[string][]string `json:"metadata,omitempty"`
+	Requests  []json.RawMessage   `json:"requests"`
+	Responses []json.RawMessage   `json:"responses"`
+	Truncated bool                `json:"truncated,omitempty"`
+	Code      string              `json:"code"`
+	Message   string              `json:"message,omitempty"`
+	Duration  Duration            `json:"duration"`
+
+	// size is the bytes of messages held
+	size int
+}
+
+// AddRequest records a request message, see AddResponse
+func (r *Record) AddRequest(m any, limit int) {
+	r.Requests = r.add(r.Requests, m, limit)
+}
+
+// AddResponse records a response message. Once the messages held would pass
+// limit bytes, it and every later message are dropped and the record is
+// marked truncated, so long streams keep only their start.
+func (r *Record) AddResponse(m any, limit int) {
+	r.Responses = r.add(r.Responses, m, limit)
+}
+
+func (r *Record) add(msgs []json.RawMessage, m any, limit int) []json.RawMessage {
+	if r.Truncated {
+		return msgs
+	}
+	b := Marshal(m)
+	if r.size+len(b) > limit {
+		r.Truncated = true
+		return msgs
+	}
+	r.size += len(b)
+	return append(msgs, b)
+}
+
+// Duration is a time.Duration that encodes as a human readable string
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// secretKeys are metadata keys (or substrings of keys) never written to a capture
+var secretKeys = []string{
+	"authorization",
+	"cookie",
+	"token",
+	"secret",
+	"password",
+	"api-key",
+}
+
+// SafeMetadata returns a copy of md without credentials or cookies
+func SafeMetadata(md metadata.MD) map[string][]string {
+	if len(md) == 0 {
+		return nil
+	}
+
+	safe := make(map[string][]string, len(md))
+	for key, values := range md {
+		if isSecretKey(key) {
+			continue
+		}
+		safe[key] = append([]string(nil), values...)
+	}
+	return safe
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalOptions keeps field names as in the .proto so captures read like the schema
+var marshalOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+// Marshal encodes a message for a Record. Non-proto values are recorded as null.
+func Marshal(m any) json.RawMessage {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return json.RawMessage("null")
+	}
+	b, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// Writer appends Records to JSONL files in a directory, starting a new file
+// when the current one reaches MaxBytes and keeping at most MaxFiles
+type Writer struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+const (
+	// DefaultMaxBytes is the default size at which capture files rotate
+	DefaultMaxBytes = 64 << 20
+	// DefaultMaxFiles is the default number of capture files kept
+	DefaultMaxFiles = 10
+	// DefaultMaxRecordBytes is the default limit on the messages kept per record
+	DefaultMaxRecordBytes = 1 << 20
+
+	filePrefix = "capture-"
+	fileSuffix = ".jsonl"
+)
+
+// NewWriter creates a Writer for dir, creating the directory if needed.
+// Zero maxBytes or maxFiles select the defaults.
+func NewWriter(dir string, maxBytes int64, maxFiles int) (*Writer, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create capture dir: %w", err)
+	}
+	return &Writer{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles}, nil
+}
+
+// Write appends a record as one JSON line
+func (w *Writer) Write(rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode capture record: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || w.size+int64(len(line)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write capture record: %w", err)
+	}
+	// Flush per record so a crash loses at most the record in flight
+	return w.buf.Flush()
+}
+
+// Close flushes and closes the current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeFile()
+}
+
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.file, w.buf, w.size = nil, nil, 0
+	return errors.Join(flushErr, closeErr)
+}
+
+// rotate closes the current file, opens a new one and prunes old files
+func (w *Writer) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	name := filePrefix + time.Now().UTC().Format("20060102T150405.000000000") + fileSuffix
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+
+	return w.prune()
+}
+
+// prune removes the oldest capture files beyond maxFiles
+func (w *Writer) prune() error {
+	files, err := Files(w.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > w.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("failed to remove old capture file: %w", err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Files lists the capture files in dir, oldest first
+func Files(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	// Names embed a sortable UTC timestamp
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Reader reads Records from a JSONL stream
+type Reader struct {
+	dec *json.Decoder
+}
+
+// NewReader creates a Reader for r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record, or io.EOF when there are no more
+func (r *Reader) Next() (*Record, error) {
+	var rec Record
+	if err := r.dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
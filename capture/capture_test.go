@@ -0,0 +1,104 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, 0, 0)
+	require.NoError(t, err)
+
+	rec := &Record{
+		Time:      time.Now().UTC(),
+		Method:    "/proto.UserService/GetUser",
+		Kind:      "unary",
+		RequestID: "req-1",
+		Requests:  []json.RawMessage{Marshal(&pb.GetUserRequest{Id: 7})},
+		Responses: []json.RawMessage{Marshal(&pb.User{Id: 7, Username: "jdoe"})},
+		Code:      "OK",
+		Duration:  Duration(1500 * time.Microsecond),
+	}
+	require.NoError(t, w.Write(rec))
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := NewReader(f)
+	got, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, rec.Method, got.Method)
+	assert.Equal(t, rec.RequestID, got.RequestID)
+	assert.Equal(t, rec.Duration, got.Duration)
+	assert.JSONEq(t, `{"id":7,"username":"jdoe"}`, string(got.Responses[0]))
+
+	_, err = r.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	// Small enough that every record starts a new file
+	w, err := NewWriter(dir, 10, 3)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, w.Write(&Record{Method: "/m", Code: "OK"}))
+		// File names have nanosecond resolution; keep them distinct
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestSafeMetadata(t *testing.T) {
+	md := metadata.Pairs(
+		"authorization", "Bearer abc",
+		"cookie", "session=1",
+		"x-api-key", "k",
+		"x-request-id", "req-1",
+		"user-agent", "grpc-go",
+	)
+
+	safe := SafeMetadata(md)
+	assert.Equal(t, map[string][]string{
+		"x-request-id": {"req-1"},
+		"user-agent":   {"grpc-go"},
+	}, safe)
+}
+
+func TestRecordTruncated(t *testing.T) {
+	user := &pb.User{Id: 7, Username: "jdoe"}
+	size := len(Marshal(user))
+
+	rec := &Record{}
+	rec.AddRequest(user, 2*size)
+	rec.AddResponse(user, 2*size)
+	assert.False(t, rec.Truncated)
+
+	rec.AddRequest(user, 2*size)
+	rec.AddResponse(&pb.User{}, 2*size)
+	assert.True(t, rec.Truncated)
+	assert.Len(t, rec.Requests, 1)
+	assert.Len(t, rec.Responses, 1, "messages after the limit are dropped too")
+}
@@ -18,6 +18,7 @@ import (
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/paulstuart/grpc-example/compression"
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
 )
 
@@ -27,6 +28,8 @@ var (
 	jwtToken     = flag.String("token", "", "JWT token for authentication (optional)")
 	certFile     = flag.String("cert", "certs/server.crt", "TLS certificate file")
 	skipVerify   = flag.Bool("skip-verify", false, "skip TLS certificate verification")
+	compress     = flag.Bool("zstd", false, "compress calls with zstd, using a shared dictionary when possible")
+	zstdDicts    = flag.String("zstd-dicts", "", "directory of zstd dictionaries (*.dict) to load")
 )
 
 // loadClientTLSConfig loads the client TLS configuration
@@ -76,6 +79,17 @@ func main() {
 		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	}
 
+	if *zstdDicts != "" {
+		ids, err := compression.LoadDictionaries(*zstdDicts)
+		if err != nil {
+			log.Fatalf("Failed to load zstd dictionaries: %v", err)
+		}
+		log.Printf("Loaded zstd dictionaries %v", ids)
+	}
+	if *compress {
+		opts = append(opts, compression.DialOptions()...)
+	}
+
 	conn, err := grpc.NewClient(*serverAddr, opts...)
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
@@ -150,9 +164,7 @@ func demonstrateAddUser(ctx context.Context, client pb.UserServiceClient) {
 		Id:       1,
 		Role:     pb.Role_ADMIN,
 		Username: "admin",
-		ContactInfo: &pb.User_Email{
-			Email: "admin@example.com",
-		},
+		Email:    "admin@example.com",
 		Profile: &pb.Profile{
 			DisplayName: "Administrator",
 			Bio:         "System administrator",
@@ -213,9 +225,7 @@ func demonstrateUpdateUser(ctx context.Context, client pb.UserServiceClient) {
 		User: &pb.User{
 			Id:     1,
 			Status: pb.UserStatus_ACTIVE,
-			ContactInfo: &pb.User_Email{
-				Email: "admin-updated@example.com",
-			},
+			Email:  "admin-updated@example.com",
 		},
 		UpdateMask: nil, // Update all fields
 	}
@@ -299,25 +309,25 @@ func demonstrateBatchAddUsers(ctx context.Context, client pb.UserServiceClient)
 	// Send multiple users
 	users := []*pb.User{
 		{
-			Id:          2,
-			Role:        pb.Role_MEMBER,
-			Username:    "user1",
-			ContactInfo: &pb.User_Email{Email: "user1@example.com"},
-			Status:      pb.UserStatus_ACTIVE,
+			Id:       2,
+			Role:     pb.Role_MEMBER,
+			Username: "user1",
+			Email:    "user1@example.com",
+			Status:   pb.UserStatus_ACTIVE,
 		},
 		{
-			Id:          3,
-			Role:        pb.Role_MEMBER,
-			Username:    "user2",
-			ContactInfo: &pb.User_Phone{Phone: "+1234567890"},
-			Status:      pb.UserStatus_ACTIVE,
+			Id:       3,
+			Role:     pb.Role_MEMBER,
+			Username: "user2",
+			Phone:    "+1234567890",
+			Status:   pb.UserStatus_ACTIVE,
 		},
 		{
-			Id:          4,
-			Role:        pb.Role_MODERATOR,
-			Username:    "mod1",
-			ContactInfo: &pb.User_Email{Email: "mod1@example.com"},
-			Status:      pb.UserStatus_ACTIVE,
+			Id:       4,
+			Role:     pb.Role_MODERATOR,
+			Username: "mod1",
+			Email:    "mod1@example.com",
+			Status:   pb.UserStatus_ACTIVE,
 		},
 	}
 
@@ -459,18 +469,18 @@ func demonstrateSyncUsers(ctx context.Context, client pb.UserServiceClient) {
 	// Send users to sync
 	users := []*pb.User{
 		{
-			Id:          5,
-			Role:        pb.Role_MEMBER,
-			Username:    "synced_user_1",
-			ContactInfo: &pb.User_Email{Email: "synced1@example.com"},
-			Status:      pb.UserStatus_ACTIVE,
+			Id:       5,
+			Role:     pb.Role_MEMBER,
+			Username: "synced_user_1",
+			Email:    "synced1@example.com",
+			Status:   pb.UserStatus_ACTIVE,
 		},
 		{
-			Id:          2, // Update existing user
-			Role:        pb.Role_ADMIN,
-			Username:    "user1_updated",
-			ContactInfo: &pb.User_Email{Email: "user1@example.com"},
-			Status:      pb.UserStatus_ACTIVE,
+			Id:       2, // Update existing user
+			Role:     pb.Role_ADMIN,
+			Username: "user1_updated",
+			Email:    "user1@example.com",
+			Status:   pb.UserStatus_ACTIVE,
 		},
 	}
 
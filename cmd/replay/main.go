@@ -0,0 +1,431 @@
+// Command replay sends RPCs recorded by the server's -capture-dir option,
+// with their recorded metadata, to a server and reports responses that
+// differ from the recording.
+//
+// Usage:
+//
+//	replay [flags] <capture file or directory>...
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/paulstuart/grpc-example/capture"
+	_ "github.com/paulstuart/grpc-example/proto/pkg" // registers the service descriptors
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+var (
+	serverAddr   = flag.String("server", "localhost:10000", "gRPC server address")
+	insecureConn = flag.Bool("insecure", false, "use insecure connection")
+	jwtToken     = flag.String("token", "", "JWT token for authentication (optional)")
+	certFile     = flag.String("cert", "certs/server.crt", "TLS certificate file")
+	skipVerify   = flag.Bool("skip-verify", false, "skip TLS certificate verification")
+	methodFilter = flag.String("method", "", "only replay methods containing this string")
+	ignoreFields = flag.String("ignore", "create_date,processed_at,timestamp", "comma separated response fields to ignore when comparing")
+	timeout      = flag.Duration("timeout", 10*time.Second, "timeout per replayed RPC")
+	verbose      = flag.Bool("v", false, "print every replayed call, not just mismatches")
+)
+
+// loadClientTLSConfig loads the client TLS configuration
+func loadClientTLSConfig(certFile string, skipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: skipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if certFile != "" {
+		certPEM, err := os.ReadFile(certFile)
+		if err != nil {
+			log.Printf("Warning: Failed to read cert file %s (%v), using system certificates", certFile, err)
+			return tlsConfig, nil
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(certPEM) {
+			log.Printf("Warning: Failed to parse cert from %s, using system certificates", certFile)
+			return tlsConfig, nil
+		}
+
+		tlsConfig.RootCAs = certPool
+	}
+
+	return tlsConfig, nil
+}
+
+// captureFiles expands directories in args to the capture files they hold
+func captureFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		found, err := capture.Files(arg)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// summary counts replay outcomes
+type summary struct {
+	total, matched, mismatched, skipped, failed int
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <capture file or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := captureFiles(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to find capture files: %v", err)
+	}
+
+	var opts []grpc.DialOption
+	if *insecureConn {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig, err := loadClientTLSConfig(*certFile, *skipVerify)
+		if err != nil {
+			log.Fatalf("Failed to load TLS config: %v", err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	conn, err := grpc.NewClient(*serverAddr, opts...)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	ignore := make(map[string]bool)
+	for _, field := range strings.Split(*ignoreFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			ignore[field] = true
+		}
+	}
+
+	var sum summary
+	for _, file := range files {
+		if err := replayFile(conn, file, ignore, &sum); err != nil {
+			log.Fatalf("Failed to replay %s: %v", file, err)
+		}
+	}
+
+	fmt.Printf("\nReplayed %d calls: %d matched, %d mismatched, %d failed, %d skipped\n",
+		sum.total, sum.matched, sum.mismatched, sum.failed, sum.skipped)
+	if sum.mismatched > 0 || sum.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// replayFile replays every record in a capture file
+func replayFile(conn *grpc.ClientConn, file string, ignore map[string]bool, sum *summary) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Stop at the current end of file, in case the target server is
+	// capturing into the same directory
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r := capture.NewReader(io.LimitReader(f, info.Size()))
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if *methodFilter != "" && !strings.Contains(rec.Method, *methodFilter) {
+			continue
+		}
+
+		sum.total++
+		diffs, err := replay(conn, rec, ignore)
+		switch {
+		case errors.Is(err, errUnsupported):
+			sum.skipped++
+			fmt.Printf("SKIP     %s %s: %v\n", rec.Method, rec.RequestID, err)
+		case err != nil:
+			sum.failed++
+			fmt.Printf("FAIL     %s %s: %v\n", rec.Method, rec.RequestID, err)
+		case len(diffs) > 0:
+			sum.mismatched++
+			fmt.Printf("MISMATCH %s %s\n", rec.Method, rec.RequestID)
+			for _, d := range diffs {
+				fmt.Printf("    %s\n", d)
+			}
+		default:
+			sum.matched++
+			if *verbose {
+				fmt.Printf("OK       %s %s\n", rec.Method, rec.RequestID)
+			}
+		}
+	}
+}
+
+var errUnsupported = errors.New("cannot replay")
+
+// replay sends a recorded call and compares the outcome with the recording
+func replay(conn *grpc.ClientConn, rec *capture.Record, ignore map[string]bool) ([]string, error) {
+	if rec.Truncated {
+		return nil, fmt.Errorf("%w: messages past the capture limit were dropped", errUnsupported)
+	}
+	method, err := findMethod(rec.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]proto.Message, 0, len(rec.Requests))
+	for i, raw := range rec.Requests {
+		req, err := newMessage(method.Input())
+		if err != nil {
+			return nil, err
+		}
+		if err := protojson.Unmarshal(raw, req); err != nil {
+			return nil, fmt.Errorf("%w: request %d: %v", errUnsupported, i, err)
+		}
+		requests = append(requests, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// Send the recorded metadata, such as batch-mode and idempotency keys,
+	// since it changes how calls behave, and the recorded request ID so
+	// server logs of both runs correlate
+	md := outgoingMetadata(rec.Metadata)
+	if rec.RequestID != "" {
+		md.Set(requestid.Header, rec.RequestID)
+	}
+	if *jwtToken != "" {
+		md.Set("authorization", "Bearer "+*jwtToken)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	var responses []proto.Message
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		responses, err = replayStream(ctx, conn, rec.Method, method, requests)
+	} else {
+		responses, err = replayUnary(ctx, conn, rec.Method, method, requests)
+	}
+	if errors.Is(err, errUnsupported) {
+		return nil, err
+	}
+
+	var diffs []string
+	if code := status.Code(err).String(); code != rec.Code {
+		diffs = append(diffs, fmt.Sprintf("code: recorded %s, got %s (%v)", rec.Code, code, err))
+	}
+	return append(diffs, compareResponses(rec.Responses, responses, ignore)...), nil
+}
+
+// outgoingMetadata returns the recorded metadata to send with a replayed
+// call, without credentials or the headers gRPC sets for each call itself
+func outgoingMetadata(recorded map[string][]string) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range capture.SafeMetadata(recorded) {
+		if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		switch key {
+		case "content-type", "user-agent", "te":
+			continue
+		}
+		md[key] = values
+	}
+	return md
+}
+
+// findMethod resolves a gRPC method path such as /proto.UserService/GetUser
+func findMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	name := strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown method %s", errUnsupported, fullMethod)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a method", errUnsupported, fullMethod)
+	}
+	return method, nil
+}
+
+func newMessage(desc protoreflect.MessageDescriptor) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName())
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown message %s", errUnsupported, desc.FullName())
+	}
+	return mt.New().Interface(), nil
+}
+
+func replayUnary(ctx context.Context, conn *grpc.ClientConn, fullMethod string, method protoreflect.MethodDescriptor, requests []proto.Message) ([]proto.Message, error) {
+	if len(requests) != 1 {
+		return nil, fmt.Errorf("%w: unary call with %d requests", errUnsupported, len(requests))
+	}
+	resp, err := newMessage(method.Output())
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Invoke(ctx, fullMethod, requests[0], resp); err != nil {
+		return nil, err
+	}
+	return []proto.Message{resp}, nil
+}
+
+// replayStream sends every recorded request, half-closes, then drains the
+// responses. Interleaving of bidi calls is not reproduced.
+func replayStream(ctx context.Context, conn *grpc.ClientConn, fullMethod string, method protoreflect.MethodDescriptor, requests []proto.Message) ([]proto.Message, error) {
+	desc := &grpc.StreamDesc{
+		StreamName:    string(method.Name()),
+		ClientStreams: method.IsStreamingClient(),
+		ServerStreams: method.IsStreamingServer(),
+	}
+	stream, err := conn.NewStream(ctx, desc, fullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, req := range requests {
+		if err := stream.SendMsg(req); err != nil {
+			// The server ended the call; its status comes from RecvMsg
+			break
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var responses []proto.Message
+	for {
+		resp, err := newMessage(method.Output())
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.RecvMsg(resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				return responses, nil
+			}
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// compareResponses reports the differences between recorded and replayed
+// responses, ignoring the named fields at any depth
+func compareResponses(recorded []json.RawMessage, got []proto.Message, ignore map[string]bool) []string {
+	var diffs []string
+	if len(recorded) != len(got) {
+		diffs = append(diffs, fmt.Sprintf("responses: recorded %d, got %d", len(recorded), len(got)))
+	}
+
+	for i := 0; i < len(recorded) && i < len(got); i++ {
+		var want any
+		if err := json.Unmarshal(recorded[i], &want); err != nil {
+			diffs = append(diffs, fmt.Sprintf("[%d]: unreadable recording: %v", i, err))
+			continue
+		}
+		var have any
+		if err := json.Unmarshal(capture.Marshal(got[i]), &have); err != nil {
+			diffs = append(diffs, fmt.Sprintf("[%d]: unreadable response: %v", i, err))
+			continue
+		}
+		diffs = append(diffs, diff(fmt.Sprintf("[%d]", i), want, have, ignore)...)
+	}
+	return diffs
+}
+
+// diff walks two decoded JSON values and describes where they differ
+func diff(path string, want, have any, ignore map[string]bool) []string {
+	wantObj, wok := want.(map[string]any)
+	haveObj, hok := have.(map[string]any)
+	if wok && hok {
+		keys := make(map[string]bool)
+		for k := range wantObj {
+			keys[k] = true
+		}
+		for k := range haveObj {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			if !ignore[k] {
+				sorted = append(sorted, k)
+			}
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, k := range sorted {
+			diffs = append(diffs, diff(path+"."+k, wantObj[k], haveObj[k], ignore)...)
+		}
+		return diffs
+	}
+
+	wantList, wok := want.([]any)
+	haveList, hok := have.([]any)
+	if wok && hok && len(wantList) == len(haveList) {
+		var diffs []string
+		for i := range wantList {
+			diffs = append(diffs, diff(fmt.Sprintf("%s[%d]", path, i), wantList[i], haveList[i], ignore)...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(want, have) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: recorded %s, got %s", path, jsonString(want), jsonString(have))}
+}
+
+func jsonString(v any) string {
+	if v == nil {
+		return "<absent>"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,191 @@
+// Command traindict trains a zstd dictionary from the messages in RPC
+// capture files (see the server's -capture-dir option).
+//
+// Usage:
+//
+//	traindict -id 1 -out dicts/ <capture file or directory>...
+//
+// Load the result on the server and clients with -zstd-dicts dicts/.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/paulstuart/grpc-example/capture"
+	"github.com/paulstuart/grpc-example/compression"
+	_ "github.com/paulstuart/grpc-example/proto/pkg" // registers the service descriptors
+)
+
+var (
+	dictID      = flag.Uint("id", 0, "dictionary ID, unique across deployed dictionaries (required)")
+	dictSize    = flag.Int("size", compression.DefaultDictSize, "dictionary content size in bytes")
+	outDir      = flag.String("out", ".", "directory to write <id>.dict to")
+	messageType = flag.String("type", "proto.User", "train on messages of this type")
+	maxSamples  = flag.Int("max-samples", 100000, "maximum number of messages to sample")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -id N [flags] <capture file or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *dictID == 0 || *dictID > 1<<32-1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	samples, err := collectSamples(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to read captures: %v", err)
+	}
+	log.Printf("Collected %d %s messages", len(samples), *messageType)
+
+	dict, err := compression.Train(samples, uint32(*dictID), *dictSize)
+	if err != nil {
+		log.Fatalf("Failed to train dictionary: %v", err)
+	}
+
+	path := filepath.Join(*outDir, fmt.Sprintf("%d%s", *dictID, compression.DictSuffix))
+	if err := os.WriteFile(path, dict, 0o644); err != nil {
+		log.Fatalf("Failed to write dictionary: %v", err)
+	}
+	log.Printf("Wrote %s (%d bytes)", path, len(dict))
+
+	report(samples, dict)
+}
+
+// collectSamples extracts the binary encoding of every matching message
+func collectSamples(args []string) ([][]byte, error) {
+	var samples [][]byte
+	for _, arg := range args {
+		files := []string{arg}
+		if info, err := os.Stat(arg); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			if files, err = capture.Files(arg); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			found, err := samplesFromFile(file, *maxSamples-len(samples))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			samples = append(samples, found...)
+			if len(samples) >= *maxSamples {
+				return samples, nil
+			}
+		}
+	}
+	return samples, nil
+}
+
+func samplesFromFile(file string, limit int) ([][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples [][]byte
+	r := capture.NewReader(f)
+	for len(samples) < limit {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		input, output, ok := methodTypes(rec.Method)
+		if !ok {
+			continue
+		}
+		samples = appendSamples(samples, input, rec.Requests)
+		samples = appendSamples(samples, output, rec.Responses)
+	}
+	return samples, nil
+}
+
+// methodTypes looks up the request and response types of a gRPC method path
+func methodTypes(fullMethod string) (input, output protoreflect.MessageDescriptor, ok bool) {
+	name := strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, nil, false
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, nil, false
+	}
+	return method.Input(), method.Output(), true
+}
+
+// appendSamples encodes the payloads of type desc if it is the type trained on
+func appendSamples(samples [][]byte, desc protoreflect.MessageDescriptor, payloads []json.RawMessage) [][]byte {
+	if string(desc.FullName()) != *messageType {
+		return samples
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName())
+	if err != nil {
+		return samples
+	}
+	for _, raw := range payloads {
+		msg := mt.New().Interface()
+		if err := protojson.Unmarshal(raw, msg); err != nil {
+			continue
+		}
+		b, err := proto.Marshal(msg)
+		if err != nil || len(b) == 0 {
+			continue
+		}
+		samples = append(samples, b)
+	}
+	return samples
+}
+
+// report compares compressing the samples with and without the dictionary
+func report(samples [][]byte, dict []byte) {
+	plain, err := zstd.NewWriter(nil)
+	if err != nil {
+		return
+	}
+	withDict, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict))
+	if err != nil {
+		return
+	}
+
+	var raw, plainSize, dictSize int
+	for _, s := range samples {
+		raw += len(s)
+		plainSize += len(plain.EncodeAll(s, nil))
+		dictSize += len(withDict.EncodeAll(s, nil))
+	}
+	if raw == 0 {
+		return
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "Per-message compression of %d samples (%d bytes):\n", len(samples), raw)
+	fmt.Fprintf(&out, "  zstd:            %d bytes (%.1f%%)\n", plainSize, 100*float64(plainSize)/float64(raw))
+	fmt.Fprintf(&out, "  zstd+dictionary: %d bytes (%.1f%%)\n", dictSize, 100*float64(dictSize)/float64(raw))
+	fmt.Print(out.String())
+}
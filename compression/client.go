@@ -0,0 +1,132 @@
+package compression
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Negotiator picks the codec for a client connection. Calls start with
+// plain zstd; once the server lists its dictionaries in a response header,
+// later calls use the first dictionary both sides hold. If the server
+// rejects a codec, calls step down to plain zstd and then to no compression.
+type Negotiator struct {
+	codec atomic.Value // string
+}
+
+// NewNegotiator creates a Negotiator starting with plain zstd
+func NewNegotiator() *Negotiator {
+	n := &Negotiator{}
+	n.codec.Store(Name)
+	return n
+}
+
+// DialOptions returns the interceptors that negotiate compression for a
+// client connection
+func DialOptions() []grpc.DialOption {
+	n := NewNegotiator()
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(n.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(n.StreamClientInterceptor()),
+	}
+}
+
+// Codec returns the codec the next call will use; "" means none
+func (n *Negotiator) Codec() string {
+	return n.codec.Load().(string)
+}
+
+// learn updates the codec from the server's response headers
+func (n *Negotiator) learn(header metadata.MD) {
+	values := header.Get(DictionariesHeader)
+	if len(values) == 0 || n.Codec() == "" {
+		return
+	}
+	peer := []string{Name}
+	for _, id := range ParseIDs(strings.Join(values, ",")) {
+		peer = append(peer, CodecName(id))
+	}
+	n.codec.Store(Choose(peer))
+}
+
+// stepDown falls back after the server rejected codec, reporting whether
+// there is something left to retry with
+func (n *Negotiator) stepDown(codec string, err error) bool {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unimplemented || !strings.Contains(st.Message(), "Decompressor is not installed") {
+		return false
+	}
+	next := ""
+	if codec != Name {
+		next = Name
+	}
+	// Another call may already have stepped down
+	return n.codec.CompareAndSwap(codec, next) || n.Codec() != codec
+}
+
+// callOptions adds the codec to a call's options
+func callOptions(codec string, opts []grpc.CallOption) []grpc.CallOption {
+	if codec == "" {
+		return opts
+	}
+	return append(opts, grpc.UseCompressor(codec))
+}
+
+// UnaryClientInterceptor negotiates compression for unary calls. A call the
+// server rejects because of its codec is retried once with the fallback.
+func (n *Negotiator) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		codec := n.Codec()
+		var header metadata.MD
+		err := invoker(ctx, method, req, reply, cc, callOptions(codec, append(opts, grpc.Header(&header)))...)
+		if err != nil && n.stepDown(codec, err) {
+			header = nil
+			err = invoker(ctx, method, req, reply, cc, callOptions(n.Codec(), append(opts, grpc.Header(&header)))...)
+		}
+		n.learn(header)
+		return err
+	}
+}
+
+// StreamClientInterceptor negotiates compression for streaming calls
+func (n *Negotiator) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		codec := n.Codec()
+		cs, err := streamer(ctx, desc, cc, method, callOptions(codec, opts)...)
+		if err != nil {
+			return nil, err
+		}
+		return &negotiatingStream{ClientStream: cs, negotiator: n, codec: codec}, nil
+	}
+}
+
+// negotiatingStream learns from the response headers of a stream once
+// they have arrived. Messages already sent can't be resent, so a rejected
+// codec only steps down for the next call.
+type negotiatingStream struct {
+	grpc.ClientStream
+	negotiator *Negotiator
+	codec      string
+	once       sync.Once
+}
+
+// RecvMsg receives a message and, the first time, inspects the headers
+func (s *negotiatingStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.once.Do(func() {
+		if err != nil {
+			s.negotiator.stepDown(s.codec, err)
+		}
+		// Headers are available, or known to be missing, once a receive returns
+		if header, herr := s.ClientStream.Header(); herr == nil {
+			s.negotiator.learn(header)
+		}
+	})
+	return err
+}
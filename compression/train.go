@@ -0,0 +1,113 @@
+package compression
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DefaultDictSize is the default size of a trained dictionary's content
+	DefaultDictSize = 32 << 10
+
+	// segmentLen and segmentStride control how samples are cut into
+	// candidate segments for the dictionary content
+	segmentLen    = 32
+	segmentStride = 8
+)
+
+// Train builds a dictionary with the given ID from sample payloads, such
+// as encoded User messages. The content is made of the byte segments shared
+// by the most samples, with the most common last, where zstd can reference
+// them most cheaply.
+func Train(samples [][]byte, id uint32, size int) ([]byte, error) {
+	if id == 0 {
+		return nil, errors.New("dictionary ID must not be zero")
+	}
+	if size <= 0 {
+		size = DefaultDictSize
+	}
+	if len(samples) < 2 {
+		return nil, errors.New("need at least two samples to train a dictionary")
+	}
+
+	history := commonSegments(samples, size)
+	if len(history) < 8 {
+		return nil, errors.New("samples have too little in common to train a dictionary")
+	}
+
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dictionary: %w", err)
+	}
+	return dict, nil
+}
+
+// commonSegments returns up to size bytes of the segments that occur in
+// the most samples, least common first
+func commonSegments(samples [][]byte, size int) []byte {
+	type segment struct {
+		count int
+		first int
+	}
+	segments := make(map[string]*segment)
+	order := 0
+
+	for _, sample := range samples {
+		seen := make(map[string]bool)
+		for start := 0; start < len(sample); start += segmentStride {
+			end := min(start+segmentLen, len(sample))
+			key := string(sample[start:end])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			seg, ok := segments[key]
+			if !ok {
+				seg = &segment{first: order}
+				order++
+				segments[key] = seg
+			}
+			seg.count++
+		}
+	}
+
+	keys := make([]string, 0, len(segments))
+	for key, seg := range segments {
+		// Segments found in a single sample don't help the others
+		if seg.count > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := segments[keys[i]], segments[keys[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return a.first < b.first
+	})
+
+	var picked []string
+	total := 0
+	for _, key := range keys {
+		if total+len(key) > size {
+			break
+		}
+		picked = append(picked, key)
+		total += len(key)
+	}
+
+	history := make([]byte, 0, total)
+	for i := len(picked) - 1; i >= 0; i-- {
+		history = append(history, picked[i]...)
+	}
+	return history
+}
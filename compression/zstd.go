@@ -0,0 +1,261 @@
+// Package compression registers zstd compressors for gRPC, optionally
+// primed with trained dictionaries.
+//
+// The plain codec is registered as "zstd". Each loaded dictionary adds a
+// codec named "zstd-<id>" that compresses with that dictionary. Every zstd
+// frame records the ID of the dictionary it was compressed with, so all of
+// the codecs decompress frames made with any loaded dictionary, or none.
+//
+// Peers learn which dictionaries the other side holds in two ways: gRPC
+// lists every registered codec in the grpc-accept-encoding header, and
+// servers also send the IDs in the zstd-dictionaries response header. A
+// dictionary codec is only chosen when both sides hold it; otherwise calls
+// fall back to plain zstd.
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// Name is the name of the plain zstd codec
+	Name = "zstd"
+
+	// DictionariesHeader is the metadata key servers use to list the IDs
+	// of the dictionaries they hold
+	DictionariesHeader = "zstd-dictionaries"
+
+	// DictSuffix is the file extension of dictionaries in a directory
+	DictSuffix = ".dict"
+
+	// maxDecodedSize bounds the memory a single message may decompress to,
+	// matching the largest message size gRPC is commonly configured for
+	maxDecodedSize = 64 << 20
+)
+
+var (
+	mu      sync.RWMutex
+	decoder *zstd.Decoder
+	// dictIDs are the loaded dictionaries in order of preference
+	dictIDs []uint32
+)
+
+func init() {
+	if err := setDecoder(nil); err != nil {
+		panic(err)
+	}
+	encoding.RegisterCompressor(&compressor{name: Name, encoder: mustEncoder()})
+}
+
+// setDecoder replaces the shared decoder with one holding dicts
+func setDecoder(dicts [][]byte) error {
+	dec, err := zstd.NewReader(nil,
+		zstd.WithDecoderConcurrency(0),
+		zstd.WithDecoderMaxMemory(maxDecodedSize),
+		zstd.WithDecoderDicts(dicts...),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	mu.Lock()
+	old := decoder
+	decoder = dec
+	mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func mustEncoder(opts ...zstd.EOption) *zstd.Encoder {
+	enc, err := zstd.NewWriter(nil, append([]zstd.EOption{zstd.WithEncoderConcurrency(1)}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+	return enc
+}
+
+// CodecName returns the codec name for a dictionary ID
+func CodecName(id uint32) string {
+	return Name + "-" + strconv.FormatUint(uint64(id), 10)
+}
+
+// DictionaryID reads the ID of a zstd dictionary
+func DictionaryID(dict []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		return 0, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+	return info.ID(), nil
+}
+
+// RegisterDictionaries registers a codec for each dictionary, in order of
+// preference. Like encoding.RegisterCompressor it must be called during
+// startup, before any server or client connection is created.
+func RegisterDictionaries(dicts ...[]byte) error {
+	ids := make([]uint32, 0, len(dicts))
+	encoders := make([]*zstd.Encoder, 0, len(dicts))
+	for _, dict := range dicts {
+		id, err := DictionaryID(dict)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			return fmt.Errorf("zstd dictionary has no ID")
+		}
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderDict(dict))
+		if err != nil {
+			return fmt.Errorf("failed to create zstd encoder for dictionary %d: %w", id, err)
+		}
+		ids = append(ids, id)
+		encoders = append(encoders, enc)
+	}
+
+	if err := setDecoder(dicts); err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		encoding.RegisterCompressor(&compressor{name: CodecName(id), encoder: encoders[i]})
+	}
+
+	mu.Lock()
+	dictIDs = ids
+	mu.Unlock()
+	return nil
+}
+
+// LoadDictionaries reads every *.dict file in dir, in name order, and
+// registers them. It returns the IDs loaded.
+func LoadDictionaries(dir string) ([]uint32, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+DictSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	dicts := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		dict, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dictionary: %w", err)
+		}
+		dicts = append(dicts, dict)
+	}
+	if err := RegisterDictionaries(dicts...); err != nil {
+		return nil, err
+	}
+	return Dictionaries(), nil
+}
+
+// Dictionaries returns the IDs of the registered dictionaries
+func Dictionaries() []uint32 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]uint32(nil), dictIDs...)
+}
+
+// FormatIDs renders dictionary IDs for the DictionariesHeader
+func FormatIDs(ids []uint32) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseIDs parses a DictionariesHeader value, ignoring malformed entries
+func ParseIDs(s string) []uint32 {
+	var ids []uint32
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err == nil && id != 0 {
+			ids = append(ids, uint32(id))
+		}
+	}
+	return ids
+}
+
+// Choose returns the best codec for a peer: the first of our dictionaries
+// the peer also holds, else plain zstd if the peer supports it, else ""
+func Choose(peerCodecs []string) string {
+	peer := make(map[string]bool, len(peerCodecs))
+	for _, name := range peerCodecs {
+		peer[strings.TrimSpace(name)] = true
+	}
+	for _, id := range Dictionaries() {
+		if name := CodecName(id); peer[name] {
+			return name
+		}
+	}
+	if peer[Name] {
+		return Name
+	}
+	return ""
+}
+
+// compressor is an encoding.Compressor using a shared encoder. Messages are
+// already held in memory by gRPC, so whole-buffer EncodeAll/DecodeAll is
+// used rather than streaming, which keeps the encoder and decoder
+// safe to share between goroutines.
+type compressor struct {
+	name    string
+	encoder *zstd.Encoder
+}
+
+// Name implements encoding.Compressor
+func (c *compressor) Name() string {
+	return c.name
+}
+
+// Compress implements encoding.Compressor
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &writer{w: w, encoder: c.encoder}, nil
+}
+
+// Decompress implements encoding.Compressor
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.RLock()
+	dec := decoder
+	mu.RUnlock()
+
+	decoded, err := dec.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: %w", err)
+	}
+	return bytes.NewReader(decoded), nil
+}
+
+// writer buffers a message and compresses it on Close
+type writer struct {
+	w       io.Writer
+	encoder *zstd.Encoder
+	buf     bytes.Buffer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *writer) Close() error {
+	_, err := w.w.Write(w.encoder.EncodeAll(w.buf.Bytes(), nil))
+	return err
+}
@@ -0,0 +1,120 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func userSamples(t *testing.T, n int) [][]byte {
+	t.Helper()
+	samples := make([][]byte, n)
+	for i := range samples {
+		b, err := proto.Marshal(&pb.User{
+			Id:       uint32(i + 1),
+			Username: fmt.Sprintf("user%04d", i),
+			Email:    fmt.Sprintf("user%04d@example.com", i),
+			Role:     pb.Role_MEMBER,
+			Tags:     []string{"engineering", "on-call", "beta-tester"},
+			Profile: &pb.Profile{
+				DisplayName: fmt.Sprintf("User %d", i),
+				Bio:         "Enjoys distributed systems, protocol buffers and long walks on the beach",
+			},
+			Addresses: []*pb.Address{{Street: "123 Main Street", City: "Springfield", Country: "United States"}},
+		})
+		require.NoError(t, err)
+		samples[i] = b
+	}
+	return samples
+}
+
+func roundTrip(t *testing.T, c encoding.Compressor, msg []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	w, err := c.Compress(&compressed)
+	require.NoError(t, err)
+	_, err = w.Write(msg)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := c.Decompress(bytes.NewReader(compressed.Bytes()))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, msg, decoded)
+	return compressed.Bytes()
+}
+
+func TestPlainCodec(t *testing.T) {
+	c := encoding.GetCompressor(Name)
+	require.NotNil(t, c)
+	roundTrip(t, c, userSamples(t, 1)[0])
+}
+
+func TestDictionaryCodec(t *testing.T) {
+	samples := userSamples(t, 200)
+
+	dict, err := Train(samples, 42, 0)
+	require.NoError(t, err)
+
+	id, err := DictionaryID(dict)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(42), id)
+
+	require.NoError(t, RegisterDictionaries(dict))
+	t.Cleanup(func() { require.NoError(t, RegisterDictionaries()) })
+	assert.Equal(t, []uint32{42}, Dictionaries())
+
+	withDict := encoding.GetCompressor(CodecName(42))
+	require.NotNil(t, withDict)
+
+	msg := samples[7]
+	dictFrame := roundTrip(t, withDict, msg)
+	plainFrame := roundTrip(t, encoding.GetCompressor(Name), msg)
+	assert.Less(t, len(dictFrame), len(plainFrame), "dictionary should help small repetitive messages")
+
+	// Any codec decodes frames made with a loaded dictionary
+	r, err := encoding.GetCompressor(Name).Decompress(bytes.NewReader(dictFrame))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, msg, decoded)
+
+	// A peer without the dictionary can't decode the frame
+	dec, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	defer dec.Close()
+	_, err = dec.DecodeAll(dictFrame, nil)
+	assert.Error(t, err)
+}
+
+func TestChoose(t *testing.T) {
+	require.NoError(t, RegisterDictionaries(mustTrain(t, 7), mustTrain(t, 8)))
+	t.Cleanup(func() { require.NoError(t, RegisterDictionaries()) })
+
+	assert.Equal(t, "zstd-7", Choose([]string{"gzip", "zstd", "zstd-8", "zstd-7"}))
+	assert.Equal(t, "zstd-8", Choose([]string{"zstd", "zstd-8"}))
+	assert.Equal(t, "zstd", Choose([]string{"gzip", "zstd", "zstd-9"}))
+	assert.Equal(t, "", Choose([]string{"gzip"}))
+}
+
+func TestParseIDs(t *testing.T) {
+	assert.Equal(t, []uint32{1, 22}, ParseIDs("1, 22,bogus,0"))
+	assert.Equal(t, "1,22", FormatIDs([]uint32{1, 22}))
+}
+
+func mustTrain(t *testing.T, id uint32) []byte {
+	t.Helper()
+	dict, err := Train(userSamples(t, 50), id, 4096)
+	require.NoError(t, err)
+	return dict
+}
@@ -0,0 +1,244 @@
+// Package health runs dependency probes and reports the results through
+// the standard grpc.health.v1 service and HTTP liveness/readiness endpoints.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/paulstuart/grpc-example/logging"
+)
+
+// DefaultInterval is how often probes run unless configured otherwise
+const DefaultInterval = 10 * time.Second
+
+// DefaultTimeout bounds a single probe
+const DefaultTimeout = 2 * time.Second
+
+// Probe checks one dependency
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Optional probes are reported but don't make the server unready
+	Optional bool
+}
+
+// Result is the outcome of the latest run of a probe
+type Result struct {
+	Healthy  bool      `json:"healthy"`
+	Optional bool      `json:"optional,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Checked  time.Time `json:"checked"`
+	Duration string    `json:"duration"`
+}
+
+// Monitor runs probes periodically and keeps the health service current.
+// The overall ("") status is SERVING when every required probe passes;
+// services registered with Watch depend only on the probes they name.
+type Monitor struct {
+	server   *health.Server
+	probes   []Probe
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.RWMutex
+	results  map[string]Result
+	services map[string][]string
+	stopping bool
+
+	stop chan struct{}
+}
+
+// NewMonitor creates a Monitor for probes; a zero interval uses DefaultInterval
+func NewMonitor(interval time.Duration, probes ...Probe) *Monitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	m := &Monitor{
+		server:   health.NewServer(),
+		probes:   probes,
+		interval: interval,
+		timeout:  min(DefaultTimeout, interval),
+		results:  make(map[string]Result),
+		services: make(map[string][]string),
+		stop:     make(chan struct{}),
+	}
+	m.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return m
+}
+
+// Watch reports service as SERVING while the named probes pass
+func (m *Monitor) Watch(service string, probeNames ...string) {
+	m.mu.Lock()
+	m.services[service] = probeNames
+	m.mu.Unlock()
+	m.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Server returns the grpc.health.v1 implementation to register
+func (m *Monitor) Server() healthpb.HealthServer {
+	return m.server
+}
+
+// Start runs the probes once, then every interval until Shutdown
+func (m *Monitor) Start(ctx context.Context) {
+	m.Check(ctx)
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Check(ctx)
+			case <-ctx.Done():
+				return
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Check runs every probe and updates the serving status
+func (m *Monitor) Check(ctx context.Context) {
+	results := make(map[string]Result, len(m.probes))
+	for _, p := range m.probes {
+		pctx, cancel := context.WithTimeout(ctx, m.timeout)
+		start := time.Now()
+		err := p.Check(pctx)
+		cancel()
+
+		r := Result{
+			Healthy:  err == nil,
+			Optional: p.Optional,
+			Checked:  start,
+			Duration: time.Since(start).String(),
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results[p.Name] = r
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, r := range results {
+		if prev, ok := m.results[name]; ok && prev.Healthy != r.Healthy {
+			logger := logging.For("health")
+			if r.Healthy {
+				logger.Info("health probe recovered", "probe", name)
+			} else {
+				logger.Warn("health probe failing", "probe", name, "error", r.Error)
+			}
+		}
+	}
+	m.results = results
+	if m.stopping {
+		return
+	}
+
+	overall := true
+	for _, p := range m.probes {
+		if !p.Optional && !results[p.Name].Healthy {
+			overall = false
+		}
+	}
+	m.server.SetServingStatus("", servingStatus(overall))
+	for service, names := range m.services {
+		m.server.SetServingStatus(service, servingStatus(m.passing(names)))
+	}
+}
+
+// passing reports whether all the named probes passed; the caller holds mu
+func (m *Monitor) passing(names []string) bool {
+	for _, name := range names {
+		if !m.results[name].Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Shutdown stops the probes and reports NOT_SERVING for every service so
+// load balancers drain traffic before the server stops
+func (m *Monitor) Shutdown() {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return
+	}
+	m.stopping = true
+	m.mu.Unlock()
+
+	close(m.stop)
+	m.server.Shutdown()
+}
+
+// Ready reports whether the server should receive traffic
+func (m *Monitor) Ready() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.stopping || len(m.results) < len(m.probes) {
+		return false
+	}
+	for _, p := range m.probes {
+		if !p.Optional && !m.results[p.Name].Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Results returns the latest probe results by name
+func (m *Monitor) Results() map[string]Result {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make(map[string]Result, len(m.results))
+	for name, r := range m.results {
+		results[name] = r
+	}
+	return results
+}
+
+// LiveHandler serves /healthz: 200 whenever the process can answer. It
+// doesn't depend on probes, so a failing database or a graceful shutdown
+// doesn't get the container restarted.
+func (m *Monitor) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	})
+}
+
+// ReadyHandler serves /readyz: 200 when every required probe passes,
+// 503 otherwise, with the per-probe results in the body
+func (m *Monitor) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := "ready"
+		code := http.StatusOK
+		if !m.Ready() {
+			status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]any{"status": status, "probes": m.Results()})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
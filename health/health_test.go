@@ -0,0 +1,66 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func status(t *testing.T, m *Monitor, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := m.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestMonitor(t *testing.T) {
+	var dbErr, exportErr error
+	m := NewMonitor(0,
+		Probe{Name: "storage", Check: func(context.Context) error { return dbErr }},
+		Probe{Name: "otel", Check: func(context.Context) error { return exportErr }, Optional: true},
+	)
+	m.Watch("proto.UserService", "storage")
+	m.Watch("telemetry", "otel")
+
+	assert.False(t, m.Ready(), "not ready before the first check")
+
+	m.Check(context.Background())
+	assert.True(t, m.Ready())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, m, ""))
+
+	exportErr = errors.New("collector unavailable")
+	m.Check(context.Background())
+	assert.True(t, m.Ready(), "optional probes don't affect readiness")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, m, "proto.UserService"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, m, "telemetry"))
+
+	dbErr = errors.New("connection refused")
+	m.Check(context.Background())
+	assert.False(t, m.Ready())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, m, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, m, "proto.UserService"))
+
+	rec := httptest.NewRecorder()
+	m.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "connection refused")
+
+	dbErr = nil
+	m.Check(context.Background())
+	assert.True(t, m.Ready())
+
+	m.Shutdown()
+	m.Check(context.Background())
+	assert.False(t, m.Ready())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, m, "proto.UserService"))
+
+	rec = httptest.NewRecorder()
+	m.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
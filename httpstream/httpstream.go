@@ -0,0 +1,380 @@
+// Package httpstream serves server streaming RPCs over plain HTTP with
+// content negotiation on the Accept header:
+//
+//   - application/json (the default) buffers the stream into a JSON array
+//   - application/x-ndjson writes one message per line as it arrives
+//   - text/event-stream writes Server-Sent Events with resumable event IDs
+//
+// Errors before the first message are ordinary gateway error responses
+// with an HTTP status. Once streaming has begun the status can't change, so
+// an error is sent in-band: a final {"error": <status>} line for NDJSON, and
+// for SSE every stream ends with an "end" event whose data is the final
+// status, {"code": 0, ...} on success.
+package httpstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/paulstuart/grpc-example/logging"
+)
+
+const loggerName = "httpstream"
+
+// Content types of the streaming formats
+const (
+	ContentTypeNDJSON = "application/x-ndjson"
+	ContentTypeSSE    = "text/event-stream"
+)
+
+// DefaultMaxArrayItems is the default limit on a buffered JSON array
+const DefaultMaxArrayItems = 1000
+
+// EndEvent is the SSE event type of the final event of a stream
+const EndEvent = "end"
+
+// Format is a representation of a message stream
+type Format int
+
+const (
+	// JSONArray buffers the whole stream into one JSON array
+	JSONArray Format = iota
+	// NDJSON writes newline delimited JSON
+	NDJSON
+	// SSE writes Server-Sent Events
+	SSE
+)
+
+// Negotiate picks a Format for an Accept header. A streaming format is only
+// chosen when it's preferred over application/json; wildcards get the default.
+func Negotiate(accept string) Format {
+	best, bestQ, jsonQ := JSONArray, 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var format Format
+		switch mediaType {
+		case ContentTypeSSE:
+			format = SSE
+		case ContentTypeNDJSON:
+			format = NDJSON
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+			continue
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if bestQ > 0 && bestQ > jsonQ {
+		return best
+	}
+	return JSONArray
+}
+
+// RecvFunc returns the next message of a stream, or io.EOF at its end
+type RecvFunc func() (proto.Message, error)
+
+// Recv adapts a generated client stream to a RecvFunc
+func Recv[T proto.Message](stream interface{ Recv() (T, error) }) RecvFunc {
+	return func() (proto.Message, error) {
+		return stream.Recv()
+	}
+}
+
+// OpenFunc builds the RPC request from r and starts the stream. ctx carries
+// the outgoing metadata (authorization, request ID) of the gateway.
+type OpenFunc func(ctx context.Context, r *http.Request) (RecvFunc, error)
+
+// Config configures a Handler
+type Config struct {
+	// MaxArrayItems limits the buffered JSON array; longer results must be
+	// streamed. Zero means DefaultMaxArrayItems.
+	MaxArrayItems int
+	// EventID returns the SSE event ID of a message; nil omits IDs
+	EventID func(proto.Message) string
+	// Delivered reports whether msg was sent before the event lastEventID,
+	// letting a reconnecting SSE client resume where it left off
+	Delivered func(msg proto.Message, lastEventID string) bool
+}
+
+// Handler serves one server streaming method
+type Handler struct {
+	mux     *runtime.ServeMux
+	method  string
+	pattern string
+	open    OpenFunc
+	cfg     Config
+}
+
+// New creates a Handler for fullMethod (e.g. "/proto.UserService/ListUsers")
+// served at the HTTP path pattern. mux supplies the metadata, marshaling and
+// error handling of the gateway the handler sits alongside.
+func New(mux *runtime.ServeMux, fullMethod, pattern string, open OpenFunc, cfg Config) *Handler {
+	if cfg.MaxArrayItems <= 0 {
+		cfg.MaxArrayItems = DefaultMaxArrayItems
+	}
+	return &Handler{mux: mux, method: fullMethod, pattern: pattern, open: open, cfg: cfg}
+}
+
+// ServeHTTP negotiates the format and streams the RPC's messages in it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	_, marshaler := runtime.MarshalerForRequest(h.mux, r)
+	rpcCtx, err := runtime.AnnotateContext(ctx, h.mux, r, h.method, runtime.WithHTTPPathPattern(h.pattern))
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, marshaler, w, r, err)
+		return
+	}
+	recv, err := h.open(rpcCtx, r)
+	if err != nil {
+		runtime.HTTPError(ctx, h.mux, marshaler, w, r, err)
+		return
+	}
+
+	var enc encoder
+	lastEventID := ""
+	switch Negotiate(r.Header.Get("Accept")) {
+	case NDJSON:
+		enc = &ndjsonEncoder{marshaler: marshaler}
+	case SSE:
+		enc = &sseEncoder{marshaler: marshaler, eventID: h.cfg.EventID}
+		if h.cfg.Delivered != nil {
+			lastEventID = r.Header.Get("Last-Event-ID")
+		}
+	default:
+		enc = &arrayEncoder{marshaler: marshaler, limit: h.cfg.MaxArrayItems}
+	}
+
+	s := &stream{w: w, rc: http.NewResponseController(w), enc: enc}
+	for {
+		msg, err := recv()
+		if errors.Is(err, io.EOF) {
+			err = s.finish()
+			break
+		}
+		if err != nil {
+			if !s.started {
+				runtime.HTTPError(ctx, h.mux, marshaler, w, r, err)
+				return
+			}
+			err = s.fail(status.Convert(err))
+			break
+		}
+		if lastEventID != "" && h.cfg.Delivered(msg, lastEventID) {
+			continue
+		}
+		if err = s.send(msg); err != nil {
+			// Status errors come from encoding; anything else is a failed write
+			if st, ok := status.FromError(err); ok {
+				if !s.started {
+					runtime.HTTPError(ctx, h.mux, marshaler, w, r, err)
+					return
+				}
+				err = s.fail(st)
+			}
+			break
+		}
+	}
+	if err != nil {
+		// The client went away; cancelling ctx ends the RPC
+		logging.For(loggerName).DebugContext(ctx, "stream aborted", "method", h.method, "error", err)
+	}
+}
+
+// stream writes an encoder's output, starting the response lazily so that
+// errors before the first message can still set the HTTP status
+type stream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	enc     encoder
+	started bool
+}
+
+func (s *stream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	h := s.w.Header()
+	h.Set("Content-Type", s.enc.contentType())
+	if !s.enc.buffered() {
+		h.Set("Cache-Control", "no-cache")
+		// Stop proxies such as nginx from buffering the stream
+		h.Set("X-Accel-Buffering", "no")
+	}
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *stream) write(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func (s *stream) send(msg proto.Message) error {
+	b, err := s.enc.message(msg)
+	if err != nil {
+		return err
+	}
+	if s.enc.buffered() {
+		return nil
+	}
+	s.start()
+	return s.write(b)
+}
+
+func (s *stream) finish() error {
+	s.start()
+	return s.write(s.enc.end(status.New(codes.OK, "")))
+}
+
+func (s *stream) fail(st *status.Status) error {
+	s.start()
+	return s.write(s.enc.end(st))
+}
+
+// encoder renders messages and the final status in one Format
+type encoder interface {
+	contentType() string
+	// buffered reports whether output is held until the end of the stream
+	buffered() bool
+	message(msg proto.Message) ([]byte, error)
+	// end renders the end of the stream with its final status
+	end(st *status.Status) []byte
+}
+
+// statusJSON renders a status the way the gateway renders error bodies
+func statusJSON(m runtime.Marshaler, st *status.Status) []byte {
+	b, err := m.Marshal(st.Proto())
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"code":%d,"message":%q}`, codes.Internal, err.Error()))
+	}
+	return b
+}
+
+type arrayEncoder struct {
+	marshaler runtime.Marshaler
+	limit     int
+	items     [][]byte
+}
+
+func (e *arrayEncoder) contentType() string { return e.marshaler.ContentType(nil) }
+
+func (e *arrayEncoder) buffered() bool { return true }
+
+func (e *arrayEncoder) message(msg proto.Message) ([]byte, error) {
+	if len(e.items) == e.limit {
+		return nil, status.Errorf(codes.OutOfRange,
+			"more than %d results, request %s or %s to stream them", e.limit, ContentTypeNDJSON, ContentTypeSSE)
+	}
+	b, err := e.marshaler.Marshal(msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal response: %v", err)
+	}
+	e.items = append(e.items, b)
+	return nil, nil
+}
+
+func (e *arrayEncoder) end(*status.Status) []byte {
+	out := []byte{'['}
+	for i, item := range e.items {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, item...)
+	}
+	return append(out, ']', '\n')
+}
+
+type ndjsonEncoder struct {
+	marshaler runtime.Marshaler
+}
+
+func (e *ndjsonEncoder) contentType() string { return ContentTypeNDJSON }
+
+func (e *ndjsonEncoder) buffered() bool { return false }
+
+func (e *ndjsonEncoder) message(msg proto.Message) ([]byte, error) {
+	b, err := e.marshaler.Marshal(msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal response: %v", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// end writes nothing on success so that every line is a message
+func (e *ndjsonEncoder) end(st *status.Status) []byte {
+	if st.Code() == codes.OK {
+		return nil
+	}
+	out := append([]byte(`{"error":`), statusJSON(e.marshaler, st)...)
+	return append(out, '}', '\n')
+}
+
+type sseEncoder struct {
+	marshaler runtime.Marshaler
+	eventID   func(proto.Message) string
+}
+
+func (e *sseEncoder) contentType() string { return ContentTypeSSE }
+
+func (e *sseEncoder) buffered() bool { return false }
+
+// message writes an unnamed event, which EventSource delivers to onmessage
+func (e *sseEncoder) message(msg proto.Message) ([]byte, error) {
+	b, err := e.marshaler.Marshal(msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal response: %v", err)
+	}
+	var out []byte
+	if e.eventID != nil {
+		out = fmt.Appendf(out, "id: %s\n", e.eventID(msg))
+	}
+	return appendData(out, b), nil
+}
+
+func (e *sseEncoder) end(st *status.Status) []byte {
+	out := []byte("event: " + EndEvent + "\n")
+	return appendData(out, statusJSON(e.marshaler, st))
+}
+
+// appendData adds b as the data of an event, one data field per line
+func appendData(out, b []byte) []byte {
+	for _, line := range strings.Split(string(b), "\n") {
+		out = append(out, "data: "...)
+		out = append(out, line...)
+		out = append(out, '\n')
+	}
+	return append(out, '\n')
+}
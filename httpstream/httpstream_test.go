@@ -0,0 +1,157 @@
+package httpstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]Format{
+		"":                                       JSONArray,
+		"*/*":                                    JSONArray,
+		"application/json":                       JSONArray,
+		"text/event-stream":                      SSE,
+		"application/x-ndjson":                   NDJSON,
+		"application/json, application/x-ndjson": JSONArray,
+		"application/json;q=0.5, text/event-stream":     SSE,
+		"text/event-stream;q=0.2, application/x-ndjson": NDJSON,
+	} {
+		assert.Equal(t, want, Negotiate(accept), accept)
+	}
+}
+
+// newHandler serves users 1..n, then fails with failure if it's non-nil
+func newHandler(n int, failure error, cfg Config) http.Handler {
+	open := func(ctx context.Context, r *http.Request) (RecvFunc, error) {
+		i := 0
+		return func() (proto.Message, error) {
+			if i == n {
+				if failure != nil {
+					return nil, failure
+				}
+				return nil, io.EOF
+			}
+			i++
+			return &pb.User{Id: uint32(i), Username: "user" + strconv.Itoa(i)}, nil
+		}, nil
+	}
+	cfg.EventID = func(msg proto.Message) string { return strconv.Itoa(int(msg.(*pb.User).Id)) }
+	cfg.Delivered = func(msg proto.Message, lastEventID string) bool {
+		last, _ := strconv.Atoi(lastEventID)
+		return int(msg.(*pb.User).Id) <= last
+	}
+	return New(runtime.NewServeMux(), "/proto.UserService/ListUsers", "/api/v1/users", open, cfg)
+}
+
+func get(h http.Handler, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJSONArray(t *testing.T) {
+	rec := get(newHandler(2, nil, Config{}))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var users []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &users))
+	assert.Len(t, users, 2)
+
+	// Errors are reported with an HTTP status since nothing was written yet
+	rec = get(newHandler(2, status.Error(codes.Unavailable, "down"), Config{}))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = get(newHandler(3, nil, Config{MaxArrayItems: 2}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ContentTypeNDJSON)
+}
+
+func TestNDJSON(t *testing.T) {
+	rec := get(newHandler(2, status.Error(codes.Internal, "boom"), Config{}), "Accept", ContentTypeNDJSON)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentTypeNDJSON, rec.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], `"username":"user2"`)
+	var last struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &last))
+	assert.Equal(t, int(codes.Internal), last.Error.Code)
+	assert.Equal(t, "boom", last.Error.Message)
+}
+
+// event is one parsed Server-Sent Event
+type event struct {
+	id, name, data string
+}
+
+func readEvents(t *testing.T, body string) []event {
+	t.Helper()
+	var events []event
+	var ev event
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.name = value
+		case "data":
+			ev.data += value
+		case "":
+			events = append(events, ev)
+			ev = event{}
+		}
+	}
+	return events
+}
+
+func TestSSE(t *testing.T) {
+	h := newHandler(3, nil, Config{})
+	rec := get(h, "Accept", ContentTypeSSE)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	events := readEvents(t, rec.Body.String())
+	require.Len(t, events, 4)
+	assert.Equal(t, "1", events[0].id)
+	assert.Contains(t, events[2].data, `"username":"user3"`)
+	assert.Equal(t, EndEvent, events[3].name)
+	assert.JSONEq(t, `{"code":0, "message":"", "details":[]}`, events[3].data)
+
+	// A reconnecting client resumes after the last event it saw
+	events = readEvents(t, get(h, "Accept", ContentTypeSSE, "Last-Event-ID", "2").Body.String())
+	require.Len(t, events, 2)
+	assert.Equal(t, "3", events[0].id)
+
+	// Errors after the first event end the stream in-band
+	events = readEvents(t, get(newHandler(1, status.Error(codes.Aborted, "conflict"), Config{}), "Accept", ContentTypeSSE).Body.String())
+	require.Len(t, events, 2)
+	assert.Equal(t, EndEvent, events[1].name)
+	assert.Contains(t, events[1].data, `"message":"conflict"`)
+}
@@ -0,0 +1,221 @@
+// Package idempotency lets clients safely retry mutating RPCs.
+//
+// A client sends an idempotency-key with a call (the Idempotency-Key HTTP
+// header through the gateway). The first call with a key runs and its result,
+// response or error, is stored with a hash of the request. Retries with the
+// same key and request get the stored result without running the call again;
+// reusing the key for a different request is rejected with
+// FAILED_PRECONDITION. Results expire after the store's TTL.
+//
+// While the first call runs, its key is held for the store's lease, which is
+// much shorter than the TTL. If the server running it crashes, a retry after
+// the lease runs the call instead of being told it's still in progress.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/paulstuart/grpc-example/logging"
+	"github.com/paulstuart/grpc-example/validation"
+)
+
+const (
+	// Header is the gRPC metadata key holding the idempotency key
+	Header = "idempotency-key"
+
+	// HTTPHeader is the HTTP header the gateway forwards as Header
+	HTTPHeader = "Idempotency-Key"
+
+	// ReplayedHeader is set to "true" on responses replayed from the store
+	ReplayedHeader = "idempotency-replayed"
+
+	// DefaultTTL is how long results are kept by default
+	DefaultTTL = 24 * time.Hour
+
+	// DefaultLease is how long a running call holds its key by default
+	DefaultLease = 5 * time.Minute
+)
+
+// ErrorInfo reasons for idempotency failures
+const (
+	ReasonKeyReused    = "IDEMPOTENCY_KEY_REUSED"
+	ReasonKeyInFlight  = "IDEMPOTENCY_KEY_IN_FLIGHT"
+	ReasonStoreFailure = "IDEMPOTENCY_STORE_UNAVAILABLE"
+)
+
+// validKey limits keys to the characters and length of request IDs
+var validKey = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,255}$`)
+
+// Entry is what a store holds for a key: the hash of the request that
+// claimed it and, once that call finished, its result
+type Entry struct {
+	RequestHash []byte
+	// Pending is true while the first call is still running, until its
+	// lease runs out
+	Pending bool
+	// Response is the call's response, nil if it failed
+	Response *anypb.Any
+	// Status is the call's status, OK when it succeeded
+	Status *spb.Status
+}
+
+// Store keeps entries by key until they expire
+type Store interface {
+	// Reserve claims key for a request with the given hash, for the lease.
+	// It returns nil if the key was free or its pending entry's lease ran
+	// out, otherwise the entry already stored for it.
+	Reserve(ctx context.Context, key string, hash []byte) (*Entry, error)
+	// Complete stores the result of the call that reserved key
+	Complete(ctx context.Context, key string, entry *Entry) error
+	// Release frees a reserved key without storing a result, so the next
+	// request with it runs the call again
+	Release(ctx context.Context, key string) error
+}
+
+// ValidateKey checks that key is usable, reporting the problem as an
+// INVALID_ARGUMENT error
+func ValidateKey(key string) error {
+	if validKey.MatchString(key) {
+		return nil
+	}
+	return validation.NewError(validation.ReasonInvalidField, &errdetails.BadRequest_FieldViolation{
+		Field:       Header,
+		Description: "must be 1 to 255 letters, digits, '.', '_', ':' or '-'",
+	})
+}
+
+// Hash returns a digest identifying a call to method with the given request
+// headers, which change what the call does, and request messages, in order
+func Hash(method string, headers []string, msgs ...proto.Message) ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte(method))
+	var size [binary.MaxVarintLen64]byte
+	write := func(data []byte) {
+		h.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))])
+		h.Write(data)
+	}
+	h.Write(size[:binary.PutUvarint(size[:], uint64(len(headers)))])
+	for _, header := range headers {
+		write([]byte(header))
+	}
+	for _, msg := range msgs {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash request: %w", err)
+		}
+		write(data)
+	}
+	return h.Sum(nil), nil
+}
+
+// Key scopes a client's idempotency key to the method called and the
+// caller, so clients can't see each other's results
+func Key(method, caller, key string) string {
+	return method + "\x00" + caller + "\x00" + key
+}
+
+// Run calls call at most once for key. A retry with the same hash gets the
+// stored result and replayed set; a retry with another hash, or while the
+// first call is running and its lease lasts, fails. Results with transient codes aren't stored,
+// so those calls can be retried with the same key.
+func Run(ctx context.Context, store Store, key string, hash []byte, call func() (proto.Message, error)) (resp proto.Message, replayed bool, err error) {
+	entry, err := store.Reserve(ctx, key, hash)
+	if err != nil {
+		logging.For("idempotency").ErrorContext(ctx, "failed to reserve idempotency key", "error", err)
+		return nil, false, errorInfo(codes.Unavailable, ReasonStoreFailure, "idempotency store unavailable")
+	}
+	if entry != nil {
+		if !bytes.Equal(entry.RequestHash, hash) {
+			return nil, false, errorInfo(codes.FailedPrecondition, ReasonKeyReused,
+				"idempotency key was used for a different request")
+		}
+		if entry.Pending {
+			return nil, false, errorInfo(codes.Aborted, ReasonKeyInFlight,
+				"a request with this idempotency key is in progress")
+		}
+		resp, err := entry.result()
+		return resp, true, err
+	}
+
+	resp, err = call()
+
+	st := status.Convert(err)
+	if transient(st.Code()) {
+		if rerr := store.Release(ctx, key); rerr != nil {
+			logging.For("idempotency").WarnContext(ctx, "failed to release idempotency key", "error", rerr)
+		}
+		return resp, false, err
+	}
+
+	done := &Entry{RequestHash: hash, Status: st.Proto()}
+	if err == nil && resp != nil {
+		if done.Response, err = anypb.New(resp); err != nil {
+			_ = store.Release(ctx, key)
+			return nil, false, fmt.Errorf("failed to store response: %w", err)
+		}
+	}
+	if cerr := store.Complete(ctx, key, done); cerr != nil {
+		logging.For("idempotency").WarnContext(ctx, "failed to store idempotent result", "error", cerr)
+	}
+	return resp, false, err
+}
+
+// result returns the stored response or error
+func (e *Entry) result() (proto.Message, error) {
+	if code := codes.Code(e.Status.GetCode()); code != codes.OK {
+		return nil, status.FromProto(e.Status).Err()
+	}
+	if e.Response == nil {
+		return nil, nil
+	}
+	resp, err := e.Response.UnmarshalNew()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode stored response: %v", err)
+	}
+	return resp, nil
+}
+
+// transient reports whether a call that ended with code may succeed if it
+// is tried again
+func transient(code codes.Code) bool {
+	switch code {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable, codes.Aborted,
+		codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+func errorInfo(code codes.Code, reason, msg string) error {
+	st := status.New(code, msg)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: validation.ErrorDomain})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// clone returns a deep copy of e
+func (e *Entry) clone() *Entry {
+	c := &Entry{RequestHash: bytes.Clone(e.RequestHash), Pending: e.Pending}
+	if e.Response != nil {
+		c.Response = proto.Clone(e.Response).(*anypb.Any)
+	}
+	if e.Status != nil {
+		c.Status = proto.Clone(e.Status).(*spb.Status)
+	}
+	return c
+}
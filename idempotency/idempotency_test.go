@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Hour, time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	calls := 0
+	call := func() (proto.Message, error) {
+		calls++
+		return wrapperspb.Int32(int32(calls)), nil
+	}
+	hash, err := Hash("/test/Call", nil, wrapperspb.String("a"))
+	require.NoError(t, err)
+
+	resp, replayed, err := Run(ctx, store, "k", hash, call)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	resp, replayed, err = Run(ctx, store, "k", hash, call)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, int32(1), resp.(*wrapperspb.Int32Value).Value)
+
+	other, err := Hash("/test/Call", nil, wrapperspb.String("b"))
+	require.NoError(t, err)
+	_, _, err = Run(ctx, store, "k", other, call)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Headers that change what the call does are part of the request
+	moded, err := Hash("/test/Call", []string{"mode=atomic"}, wrapperspb.String("a"))
+	require.NoError(t, err)
+	_, _, err = Run(ctx, store, "k", moded, call)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Transient failures release the key
+	_, _, err = Run(ctx, store, "t", hash, func() (proto.Message, error) {
+		return nil, status.Error(codes.Unavailable, "try again")
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, replayed, err = Run(ctx, store, "t", hash, call)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+
+	now = now.Add(2 * time.Hour)
+	resp, replayed, err = Run(ctx, store, "k", other, call)
+	require.NoError(t, err)
+	assert.False(t, replayed, "expired keys can be reused")
+	assert.Equal(t, int32(3), resp.(*wrapperspb.Int32Value).Value)
+
+	// A call whose server died holds its key only for the lease
+	_, err = store.Reserve(ctx, "crashed", hash)
+	require.NoError(t, err)
+	_, _, err = Run(ctx, store, "crashed", hash, call)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	now = now.Add(time.Minute)
+	_, replayed, err = Run(ctx, store, "crashed", hash, call)
+	require.NoError(t, err)
+	assert.False(t, replayed, "a retry takes over after the lease")
+	now = now.Add(time.Minute)
+	_, replayed, err = Run(ctx, store, "crashed", hash, call)
+	require.NoError(t, err)
+	assert.True(t, replayed, "results outlast the lease")
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops expired entries
+const sweepInterval = time.Minute
+
+// MemoryStore keeps entries in memory, so they are per process and lost on
+// restart
+type MemoryStore struct {
+	ttl   time.Duration
+	lease time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	*Entry
+	expires time.Time
+}
+
+// NewMemoryStore creates a store keeping results for ttl and reserving keys
+// for running calls for lease
+func NewMemoryStore(ttl, lease time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, lease: lease, now: time.Now, entries: make(map[string]*memoryEntry)}
+}
+
+// Verify that MemoryStore implements Store interface
+var _ Store = (*MemoryStore)(nil)
+
+// Reserve claims key for the lease, or returns a copy of its unexpired entry
+func (m *MemoryStore) Reserve(ctx context.Context, key string, hash []byte) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		return e.clone(), nil
+	}
+	m.entries[key] = &memoryEntry{
+		Entry:   &Entry{RequestHash: hash, Pending: true},
+		expires: now.Add(m.lease),
+	}
+	return nil, nil
+}
+
+// Complete stores a copy of entry for key, restarting its TTL
+func (m *MemoryStore) Complete(ctx context.Context, key string, entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = &memoryEntry{Entry: entry.clone(), expires: m.now().Add(m.ttl)}
+	return nil
+}
+
+// Release deletes key
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// sweep drops expired entries, at most once per sweepInterval
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
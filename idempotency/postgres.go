@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// PostgresStore keeps entries in the idempotency_keys table, so they are
+// shared by every server using the database and survive restarts
+type PostgresStore struct {
+	pool  *pgxpool.Pool
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewPostgresStore returns a store keeping results for ttl, and reserving
+// keys for running calls for lease, in the idempotency_keys table, created
+// by the server's schema migrations
+func NewPostgresStore(pool *pgxpool.Pool, ttl, lease time.Duration) *PostgresStore {
+	return &PostgresStore{pool: pool, ttl: ttl, lease: lease}
+}
+
+// Verify that PostgresStore implements Store interface
+var _ Store = (*PostgresStore)(nil)
+
+// Reserve drops expired entries, including pending ones past their lease,
+// then claims key for the lease or returns its entry
+func (s *PostgresStore) Reserve(ctx context.Context, key string, hash []byte) (*Entry, error) {
+	var entry *Entry
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires <= now()`); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO idempotency_keys (key, request_hash, pending, expires)
+			VALUES ($1, $2, true, now() + make_interval(secs => $3))
+			ON CONFLICT (key) DO NOTHING
+		`, key, hash, s.lease.Seconds())
+		if err != nil || tag.RowsAffected() == 1 {
+			return err
+		}
+
+		var response, st []byte
+		entry = &Entry{}
+		err = tx.QueryRow(ctx, `
+			SELECT request_hash, pending, response, status FROM idempotency_keys WHERE key = $1
+		`, key).Scan(&entry.RequestHash, &entry.Pending, &response, &st)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Completed and released between the insert and the select
+			entry = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return entry.unmarshal(response, st)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return entry, nil
+}
+
+// Complete stores entry for key, restarting its TTL
+func (s *PostgresStore) Complete(ctx context.Context, key string, entry *Entry) error {
+	var response, st []byte
+	var err error
+	if entry.Response != nil {
+		if response, err = proto.Marshal(entry.Response); err != nil {
+			return fmt.Errorf("failed to serialize response: %w", err)
+		}
+	}
+	if st, err = proto.Marshal(entry.Status); err != nil {
+		return fmt.Errorf("failed to serialize status: %w", err)
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, pending, response, status, expires)
+		VALUES ($1, $2, false, $3, $4, now() + make_interval(secs => $5))
+		ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			pending = false,
+			response = EXCLUDED.response,
+			status = EXCLUDED.status,
+			expires = EXCLUDED.expires
+	`, key, entry.RequestHash, response, st, s.ttl.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release deletes key
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// unmarshal decodes a stored response and status into e
+func (e *Entry) unmarshal(response, st []byte) error {
+	if response != nil {
+		e.Response = &anypb.Any{}
+		if err := proto.Unmarshal(response, e.Response); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	if st != nil {
+		e.Status = &spb.Status{}
+		if err := proto.Unmarshal(st, e.Status); err != nil {
+			return fmt.Errorf("failed to parse status: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package idempotency
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paulstuart/grpc-example/server"
+)
+
+func TestPostgresStoreLease(t *testing.T) {
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	// The server storage migrates the schema, idempotency_keys included
+	storage, err := server.NewPostgresStorage(ctx, connString)
+	require.NoError(t, err)
+	t.Cleanup(storage.Close)
+	_, err = storage.Pool().Exec(ctx, "TRUNCATE idempotency_keys")
+	require.NoError(t, err)
+
+	store := NewPostgresStore(storage.Pool(), time.Hour, time.Second)
+	hash := []byte("hash")
+
+	entry, err := store.Reserve(ctx, "k", hash)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+	entry, err = store.Reserve(ctx, "k", hash)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.True(t, entry.Pending)
+
+	// The call never completes, as if its server died
+	time.Sleep(1100 * time.Millisecond)
+	entry, err = store.Reserve(ctx, "k", hash)
+	require.NoError(t, err)
+	assert.Nil(t, entry, "a retry takes over after the lease")
+
+	require.NoError(t, store.Complete(ctx, "k", &Entry{RequestHash: hash}))
+	time.Sleep(1100 * time.Millisecond)
+	entry, err = store.Reserve(ctx, "k", hash)
+	require.NoError(t, err)
+	require.NotNil(t, entry, "results outlast the lease")
+	assert.False(t, entry.Pending)
+}
@@ -2,13 +2,14 @@ package interceptors
 
 import (
 	"context"
-	"log"
 	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/logging"
 )
 
 // Simple auth interceptor for demonstration purposes
@@ -21,6 +22,8 @@ const (
 
 // AuthUnaryInterceptor provides simple authentication for unary RPCs
 func AuthUnaryInterceptor() grpc.UnaryServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		ctx context.Context,
 		req interface{},
@@ -33,17 +36,19 @@ func AuthUnaryInterceptor() grpc.UnaryServerInterceptor {
 		}
 
 		if err := authorize(ctx); err != nil {
-			log.Printf("[Auth] Unauthorized access attempt to %s", info.FullMethod)
+			logger.WarnContext(ctx, "unauthorized access attempt", "method", info.FullMethod)
 			return nil, err
 		}
 
-		log.Printf("[Auth] WE HAVE Authorized access to %s", info.FullMethod)
+		logger.DebugContext(ctx, "authorized access", "method", info.FullMethod)
 		return handler(ctx, req)
 	}
 }
 
 // AuthStreamInterceptor provides simple authentication for streaming RPCs
 func AuthStreamInterceptor() grpc.StreamServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		srv interface{},
 		ss grpc.ServerStream,
@@ -56,11 +61,11 @@ func AuthStreamInterceptor() grpc.StreamServerInterceptor {
 		}
 
 		if err := authorize(ss.Context()); err != nil {
-			log.Printf("[Auth] Unauthorized stream access attempt to %s", info.FullMethod)
+			logger.WarnContext(ss.Context(), "unauthorized stream access attempt", "method", info.FullMethod)
 			return err
 		}
 
-		log.Printf("[Auth] Authorized stream access to %s", info.FullMethod)
+		logger.DebugContext(ss.Context(), "authorized stream access", "method", info.FullMethod)
 		return handler(srv, ss)
 	}
 }
@@ -90,6 +95,16 @@ func authorize(ctx context.Context) error {
 	return nil
 }
 
+// publicMethodPrefixes lists method prefixes that skip authentication.
+// Health checks come from load balancers and orchestrators without credentials.
+var publicMethodPrefixes = []string{"/grpc.health.v1.Health/"}
+
+// AllowUnauthenticated lets methods starting with any of prefixes skip
+// authentication. Call it before the server starts.
+func AllowUnauthenticated(prefixes ...string) {
+	publicMethodPrefixes = append(publicMethodPrefixes, prefixes...)
+}
+
 // isPublicMethod determines if a method should skip authentication
 func isPublicMethod(method string) bool {
 	// Add methods that should be publicly accessible
@@ -99,5 +114,10 @@ func isPublicMethod(method string) bool {
 	//     "/proto.UserService/Login",
 	//     "/proto.UserService/Register",
 	// }
+	for _, prefix := range publicMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
 	return false
 }
@@ -0,0 +1,175 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/paulstuart/grpc-example/capture"
+	"github.com/paulstuart/grpc-example/logging"
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+// CaptureConfig configures the capture interceptors
+type CaptureConfig struct {
+	// SampleRate is the fraction of calls recorded, from 0 to 1
+	SampleRate float64
+	// MaxRecordBytes limits the messages kept per call (default
+	// capture.DefaultMaxRecordBytes); later ones are dropped and the record
+	// is marked truncated
+	MaxRecordBytes int
+	// Redactor scrubs sensitive fields from the messages (default
+	// logging.DefaultRedactor)
+	Redactor *logging.Redactor
+	// Unredacted records messages as they are, secrets included
+	Unredacted bool
+}
+
+// payload prepares a message for a record, redacting it unless configured not to
+func (c CaptureConfig) payload(m any) any {
+	msg, ok := m.(proto.Message)
+	if !ok || c.Unredacted {
+		return m
+	}
+	return c.Redactor.Message(msg)
+}
+
+func (c CaptureConfig) limit() int {
+	if c.MaxRecordBytes <= 0 {
+		return capture.DefaultMaxRecordBytes
+	}
+	return c.MaxRecordBytes
+}
+
+// CaptureUnaryInterceptor records a sample of unary RPCs to w
+func CaptureUnaryInterceptor(w *capture.Writer, cfg CaptureConfig) grpc.UnaryServerInterceptor {
+	logger := logging.For(loggerName)
+	if cfg.Redactor == nil {
+		cfg.Redactor = logging.DefaultRedactor()
+	}
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !sampled(cfg.SampleRate) {
+			return handler(ctx, req)
+		}
+
+		rec := newCaptureRecord(ctx, info.FullMethod, "unary")
+		rec.AddRequest(cfg.payload(req), cfg.limit())
+
+		resp, err := handler(ctx, req)
+
+		if err == nil {
+			rec.AddResponse(cfg.payload(resp), cfg.limit())
+		}
+		finishCaptureRecord(rec, err)
+
+		if werr := w.Write(rec); werr != nil {
+			logger.WarnContext(ctx, "failed to capture rpc", "method", info.FullMethod, "error", werr)
+		}
+		return resp, err
+	}
+}
+
+// CaptureStreamInterceptor records a sample of streaming RPCs to w,
+// including the messages sent and received up to the record limit
+func CaptureStreamInterceptor(w *capture.Writer, cfg CaptureConfig) grpc.StreamServerInterceptor {
+	logger := logging.For(loggerName)
+	if cfg.Redactor == nil {
+		cfg.Redactor = logging.DefaultRedactor()
+	}
+
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !sampled(cfg.SampleRate) {
+			return handler(srv, ss)
+		}
+
+		ctx := ss.Context()
+		cs := &capturingStream{
+			ServerStream: ss,
+			rec:          newCaptureRecord(ctx, info.FullMethod, determineStreamType(info)),
+			cfg:          cfg,
+		}
+
+		err := handler(srv, cs)
+
+		cs.mu.Lock()
+		finishCaptureRecord(cs.rec, err)
+		werr := w.Write(cs.rec)
+		cs.mu.Unlock()
+
+		if werr != nil {
+			logger.WarnContext(ctx, "failed to capture rpc", "method", info.FullMethod, "error", werr)
+		}
+		return err
+	}
+}
+
+// sampled decides whether to capture the current call
+func sampled(rate float64) bool {
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+func newCaptureRecord(ctx context.Context, method, kind string) *capture.Record {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return &capture.Record{
+		Time:      time.Now(),
+		Method:    method,
+		Kind:      kind,
+		RequestID: requestid.FromContext(ctx),
+		Metadata:  capture.SafeMetadata(md),
+		Requests:  []json.RawMessage{},
+		Responses: []json.RawMessage{},
+	}
+}
+
+func finishCaptureRecord(rec *capture.Record, err error) {
+	st := status.Convert(err)
+	rec.Code = st.Code().String()
+	rec.Message = st.Message()
+	rec.Duration = capture.Duration(time.Since(rec.Time))
+}
+
+// capturingStream records each message passing through a server stream.
+// Handlers may send and receive from different goroutines, hence the lock.
+type capturingStream struct {
+	grpc.ServerStream
+	mu  sync.Mutex
+	rec *capture.Record
+	cfg CaptureConfig
+}
+
+// SendMsg records and sends a message
+func (s *capturingStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	s.rec.AddResponse(s.cfg.payload(m), s.cfg.limit())
+	s.mu.Unlock()
+	return s.ServerStream.SendMsg(m)
+}
+
+// RecvMsg receives and records a message
+func (s *capturingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.rec.AddRequest(s.cfg.payload(m), s.cfg.limit())
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/paulstuart/grpc-example/capture"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// captureCall records one AddUser call with cfg and returns its record
+func captureCall(t *testing.T, cfg CaptureConfig) *capture.Record {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := capture.NewWriter(dir, 0, 0)
+	require.NoError(t, err)
+
+	user := &pb.User{Id: 7, Username: "jdoe", Email: "jdoe@example.com"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.AddUserResponse{User: user}, nil
+	}
+	cfg.SampleRate = 1
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.UserService/AddUser"}
+	_, err = CaptureUnaryInterceptor(w, cfg)(context.Background(), user, info, handler)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	files, err := capture.Files(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	rec, err := capture.NewReader(f).Next()
+	require.NoError(t, err)
+	return rec
+}
+
+func TestCaptureRedactsMessages(t *testing.T) {
+	rec := captureCall(t, CaptureConfig{})
+	assert.JSONEq(t, `{"id":7,"username":"jdoe","email":"[REDACTED]"}`, string(rec.Requests[0]))
+	assert.JSONEq(t, `{"user":{"id":7,"username":"jdoe","email":"[REDACTED]"}}`, string(rec.Responses[0]))
+
+	rec = captureCall(t, CaptureConfig{Unredacted: true})
+	assert.JSONEq(t, `{"id":7,"username":"jdoe","email":"jdoe@example.com"}`, string(rec.Requests[0]))
+}
@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/paulstuart/grpc-example/compression"
+)
+
+// CompressionUnaryInterceptor advertises the server's zstd dictionaries and
+// compresses responses with a dictionary the client also holds
+func CompressionUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if md := negotiateCompression(ctx); md != nil {
+			_ = grpc.SetHeader(ctx, md)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CompressionStreamInterceptor advertises the server's zstd dictionaries and
+// compresses stream messages with a dictionary the client also holds
+func CompressionStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if md := negotiateCompression(ss.Context()); md != nil {
+			_ = ss.SetHeader(md)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// negotiateCompression picks the response codec and returns the header
+// listing the server's dictionaries, or nil if it has none. Without a
+// shared dictionary responses keep the codec the request was sent with.
+func negotiateCompression(ctx context.Context) metadata.MD {
+	ids := compression.Dictionaries()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if supported, err := grpc.ClientSupportedCompressors(ctx); err == nil {
+		if codec := compression.Choose(supported); codec != "" && codec != compression.Name {
+			_ = grpc.SetSendCompressor(ctx, codec)
+		}
+	}
+	return metadata.Pairs(compression.DictionariesHeader, compression.FormatIDs(ids))
+}
@@ -0,0 +1,185 @@
+package interceptors
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Latency histograms use log-linear buckets: each bucket's upper bound is
+// histogramGrowth times the previous one, starting at histogramMin. That
+// keeps quantile error under 10% from a microsecond up to about an hour in
+// a fixed 2KB per histogram, however many requests are recorded.
+const (
+	histogramMin     = time.Microsecond
+	histogramGrowth  = 1.1
+	histogramBuckets = 232 // histogramMin * 1.1^231 is just over an hour
+)
+
+// histogramLogGrowth is precomputed for bucketFor
+var histogramLogGrowth = math.Log(histogramGrowth)
+
+// Histogram is a fixed-size latency histogram. The zero value is ready to
+// use. It is not safe for concurrent use; MetricsCollector guards it.
+type Histogram struct {
+	counts [histogramBuckets]uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// Record adds one observation
+func (h *Histogram) Record(d time.Duration) {
+	h.counts[bucketFor(d)]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Merge adds all observations of other into h
+func (h *Histogram) Merge(other *Histogram) {
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Mean returns the average observation
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Max returns the largest observation
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Quantile returns an upper estimate of the q-th quantile (0 < q <= 1),
+// never more than the largest observation
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return min(bucketUpperBound(i), h.max)
+		}
+	}
+	return h.max
+}
+
+// Summary reports the usual percentiles
+func (h *Histogram) Summary() LatencySummary {
+	return LatencySummary{
+		Count: h.count,
+		Mean:  h.Mean(),
+		P50:   h.Quantile(0.50),
+		P90:   h.Quantile(0.90),
+		P99:   h.Quantile(0.99),
+		Max:   h.max,
+	}
+}
+
+// LatencySummary is a point-in-time view of a Histogram
+type LatencySummary struct {
+	Count uint64
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// String formats the summary for logs
+func (s LatencySummary) String() string {
+	return fmt.Sprintf("n=%d avg=%v p50=%v p90=%v p99=%v max=%v",
+		s.Count, s.Mean, s.P50, s.P90, s.P99, s.Max)
+}
+
+// bucketFor returns the index of the bucket holding d
+func bucketFor(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / histogramLogGrowth))
+	// Guard against rounding putting d just above its bucket's bound
+	if i > 0 && d <= bucketUpperBound(i-1) {
+		i--
+	}
+	return min(i, histogramBuckets-1)
+}
+
+// bucketUpperBound returns the largest duration counted in bucket i
+func bucketUpperBound(i int) time.Duration {
+	if i == histogramBuckets-1 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(histogramMin) * math.Pow(histogramGrowth, float64(i)))
+}
+
+// windowSlot is the length of time covered by each slot of a window
+const windowSlot = 10 * time.Second
+
+// windowSlots covers the longest window reported, five minutes
+const windowSlots = int(5 * time.Minute / windowSlot)
+
+// WindowedHistogram keeps a Histogram per 10 second slot over the last five
+// minutes, so recent latency can be reported separately from the lifetime
+// totals. Like Histogram it is guarded by its owner.
+type WindowedHistogram struct {
+	slots [windowSlots]struct {
+		epoch int64
+		hist  *Histogram
+	}
+}
+
+// Record adds an observation made at now
+func (w *WindowedHistogram) Record(now time.Time, d time.Duration) {
+	epoch := now.UnixNano() / int64(windowSlot)
+	slot := &w.slots[epoch%int64(windowSlots)]
+	if slot.hist == nil {
+		slot.hist = &Histogram{}
+	} else if slot.epoch != epoch {
+		// The slot last held data from a previous lap of the ring
+		*slot.hist = Histogram{}
+	}
+	slot.epoch = epoch
+	slot.hist.Record(d)
+}
+
+// Window merges the slots covering the period up to now. The current slot
+// is partially filled, so the period covered is up to 10s shorter than window.
+func (w *WindowedHistogram) Window(now time.Time, window time.Duration) *Histogram {
+	current := now.UnixNano() / int64(windowSlot)
+	oldest := current - int64(window/windowSlot) + 1
+
+	merged := &Histogram{}
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if slot.hist != nil && slot.epoch >= oldest && slot.epoch <= current {
+			merged.Merge(slot.hist)
+		}
+	}
+	return merged
+}
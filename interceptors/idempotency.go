@@ -0,0 +1,211 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/paulstuart/grpc-example/idempotency"
+	"github.com/paulstuart/grpc-example/logging"
+)
+
+// IdempotencyMaxMessages caps how many messages a client streaming call
+// with an idempotency key may send, since they are all held to hash them
+var IdempotencyMaxMessages = 10000
+
+// IdempotencyUnaryInterceptor runs unary calls carrying an idempotency-key
+// at most once per key, replaying the stored result for retries. The
+// request metadata named by headers changes what calls do, so it's part of
+// the request a key is bound to.
+func IdempotencyUnaryInterceptor(store idempotency.Store, headers ...string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		key, err := idempotencyKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg, ok := req.(proto.Message)
+		if key == "" || !ok {
+			return handler(ctx, req)
+		}
+		hash, err := idempotency.Hash(info.FullMethod, requestHeaders(ctx, headers), msg)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		resp, replayed, err := idempotency.Run(ctx, store, scopedKey(ctx, info.FullMethod, key), hash, func() (proto.Message, error) {
+			resp, err := handler(ctx, req)
+			msg, _ := resp.(proto.Message)
+			return msg, err
+		})
+		if replayed {
+			markReplayed(ctx, info.FullMethod)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// IdempotencyStreamInterceptor runs client streaming calls carrying an
+// idempotency-key at most once per key. Every message the client sends is
+// read before the handler runs, to hash the whole request, then handed to
+// the handler in order. Other streaming calls don't accept keys. Like
+// IdempotencyUnaryInterceptor, the metadata named by headers is part of the
+// request.
+func IdempotencyStreamInterceptor(store idempotency.Store, headers ...string) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := ss.Context()
+		key, err := idempotencyKey(ctx)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return handler(srv, ss)
+		}
+		if !info.IsClientStream || info.IsServerStream {
+			return status.Errorf(codes.InvalidArgument, "%s does not accept an %s", info.FullMethod, idempotency.Header)
+		}
+
+		msgs, err := drainStream(ss, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		hash, err := idempotency.Hash(info.FullMethod, requestHeaders(ctx, headers), msgs...)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		replay := &replayServerStream{ServerStream: ss, msgs: msgs}
+		resp, replayed, err := idempotency.Run(ctx, store, scopedKey(ctx, info.FullMethod, key), hash, func() (proto.Message, error) {
+			err := handler(srv, replay)
+			return replay.sent, err
+		})
+		if !replayed || err != nil {
+			return err
+		}
+		markReplayed(ctx, info.FullMethod)
+		return ss.SendMsg(resp)
+	}
+}
+
+// idempotencyKey returns the call's idempotency key, or "" if it has none
+func idempotencyKey(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(idempotency.Header)
+	if len(values) == 0 {
+		return "", nil
+	}
+	if err := idempotency.ValidateKey(values[0]); err != nil {
+		return "", err
+	}
+	return values[0], nil
+}
+
+// requestHeaders returns the values of the named request metadata, as
+// key=value pairs in the order named
+func requestHeaders(ctx context.Context, names []string) []string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strings.Join(md.Get(name), ",")
+	}
+	return pairs
+}
+
+// scopedKey scopes key to the method and, with auth enabled, the caller
+func scopedKey(ctx context.Context, method, key string) string {
+	var caller string
+	if claims := GetClaimsFromContext(ctx); claims != nil {
+		caller = claims.UserID
+	}
+	return idempotency.Key(method, caller, key)
+}
+
+func markReplayed(ctx context.Context, method string) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(idempotency.ReplayedHeader, "true"))
+	logging.For(loggerName).InfoContext(ctx, "replayed idempotent call", "method", method)
+}
+
+// drainStream reads every message the client sends, using the request type
+// the method's descriptor names
+func drainStream(ss grpc.ServerStream, method string) ([]proto.Message, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "."))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unknown method %s: %v", method, err)
+	}
+	md, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "%s is not a method", method)
+	}
+	input, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unknown request type for %s: %v", method, err)
+	}
+
+	var msgs []proto.Message
+	for {
+		msg := input.New().Interface()
+		err := ss.RecvMsg(msg)
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == IdempotencyMaxMessages {
+			return nil, status.Errorf(codes.ResourceExhausted,
+				"calls with an %s are limited to %d messages", idempotency.Header, IdempotencyMaxMessages)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// replayServerStream hands the handler messages already read from the
+// client and keeps the response it sends
+type replayServerStream struct {
+	grpc.ServerStream
+	msgs []proto.Message
+	sent proto.Message
+}
+
+func (s *replayServerStream) RecvMsg(m interface{}) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	dst, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", m)
+	}
+	proto.Reset(dst)
+	proto.Merge(dst, s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func (s *replayServerStream) SendMsg(m interface{}) error {
+	if msg, ok := m.(proto.Message); ok {
+		s.sent = msg
+	}
+	return s.ServerStream.SendMsg(m)
+}
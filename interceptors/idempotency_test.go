@@ -0,0 +1,113 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/paulstuart/grpc-example/idempotency"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/server"
+)
+
+func newIdempotentClient(t *testing.T) pb.UserServiceClient {
+	t.Helper()
+	store := idempotency.NewMemoryStore(time.Hour, time.Minute)
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(IdempotencyUnaryInterceptor(store, server.BatchModeHeader)),
+		grpc.StreamInterceptor(IdempotencyStreamInterceptor(store, server.BatchModeHeader)),
+	)
+	pb.RegisterUserServiceServer(grpcServer, server.New(server.NewMemoryStorage()))
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewUserServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), idempotency.Header, key)
+}
+
+func TestIdempotencyUnaryInterceptor(t *testing.T) {
+	client := newIdempotentClient(t)
+	admin := &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN}
+
+	_, err := client.AddUser(withKey("add-1"), admin)
+	require.NoError(t, err)
+
+	// The retry gets the first result instead of ALREADY_EXISTS
+	var header metadata.MD
+	_, err = client.AddUser(withKey("add-1"), admin, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"true"}, header.Get(idempotency.ReplayedHeader))
+
+	_, err = client.AddUser(withKey("add-1"), &pb.User{Id: 2, Username: "bob"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.AddUser(context.Background(), admin)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err), "calls without a key aren't deduplicated")
+
+	// Errors are replayed too
+	_, err = client.AddUser(withKey("add-2"), admin)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.AddUser(withKey("add-2"), admin)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.AddUser(withKey("not a key"), admin)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestIdempotencyStreamInterceptor(t *testing.T) {
+	client := newIdempotentClient(t)
+	_, err := client.AddUser(context.Background(), &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN})
+	require.NoError(t, err)
+
+	batch := func(key string, users ...*pb.User) (*pb.BatchAddUsersResponse, error) {
+		stream, err := client.BatchAddUsers(withKey(key))
+		require.NoError(t, err)
+		for _, user := range users {
+			require.NoError(t, stream.Send(user))
+		}
+		return stream.CloseAndRecv()
+	}
+
+	users := []*pb.User{{Id: 2, Username: "bob"}, {Id: 3, Username: "carol"}}
+	first, err := batch("batch-1", users...)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), first.TotalAdded)
+
+	retry, err := batch("batch-1", users...)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), retry.TotalAdded, "no partial duplicates")
+	assert.Equal(t, first.ProcessedAt.AsTime(), retry.ProcessedAt.AsTime())
+
+	_, err = batch("batch-1", users[0])
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Switching the batch mode is a different request
+	stream, err := client.BatchAddUsers(metadata.AppendToOutgoingContext(withKey("batch-1"),
+		server.BatchModeHeader, server.BatchModeAtomic))
+	require.NoError(t, err)
+	for _, user := range users {
+		require.NoError(t, stream.Send(user))
+	}
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -2,8 +2,6 @@ package interceptors
 
 import (
 	"context"
-	"log"
-	"log/slog"
 	"strings"
 	"time"
 
@@ -13,6 +11,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/paulstuart/grpc-example/auth"
+	"github.com/paulstuart/grpc-example/logging"
 )
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -46,7 +45,7 @@ func (my FakeClaimsApprover) ValidMethod(fullMethod string, claim *auth.Claims)
 	default:
 
 	}
-	slog.Info("====> FakeClaimsApprover automatically validating method", "method", fullMethod, "user", claim.Username)
+	logging.For(loggerName).Debug("FakeClaimsApprover automatically validating method", "method", fullMethod, "user_id", claim.UserID)
 	return nil
 }
 
@@ -65,6 +64,8 @@ func NewJWTManager(secretKey string, tokenDuration time.Duration, issuer string)
 
 // JWTAuthUnaryInterceptor provides JWT authentication for unary RPCs
 func JWTAuthUnaryInterceptor(vapid auth.Approver) grpc.UnaryServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		ctx context.Context,
 		req any,
@@ -77,26 +78,28 @@ func JWTAuthUnaryInterceptor(vapid auth.Approver) grpc.UnaryServerInterceptor {
 		}
 		claims, err := validateJWT(ctx, vapid)
 		if err != nil {
-			log.Printf("[JWT Auth] Unauthorized access attempt to %s: %v", info.FullMethod, err)
+			logger.WarnContext(ctx, "unauthenticated access attempt", "method", info.FullMethod, "error", err)
 			return nil, err
 		}
 		// TODO: any call for special handling of errors here? Extend auth.Approver?
 		if err := vapid.ValidMethod(info.FullMethod, claims); err != nil {
-			log.Printf("[JWT Auth] Forbidden stream access attempt to %s by user %s: %v",
-				info.FullMethod, claims.Username, err)
+			logger.WarnContext(ctx, "forbidden access attempt",
+				"method", info.FullMethod, "user_id", claims.UserID, "error", err)
 			return nil, status.Error(codes.PermissionDenied, "insufficient permissions for method")
 		}
 
 		// Add claims to context for downstream use
 		ctx = context.WithValue(ctx, ClaimsContextKey, claims)
-		log.Printf("[JWT Auth] Authorized access to %s by user %s (roles: %v)",
-			info.FullMethod, claims.Username, claims.Roles)
+		logger.DebugContext(ctx, "authorized access",
+			"method", info.FullMethod, "user_id", claims.UserID, "roles", claims.Roles)
 		return handler(ctx, req)
 	}
 }
 
 // JWTAuthStreamInterceptor provides JWT authentication for streaming RPCs
 func JWTAuthStreamInterceptor(jwtManager auth.Approver) grpc.StreamServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		srv interface{},
 		ss grpc.ServerStream,
@@ -110,13 +113,13 @@ func JWTAuthStreamInterceptor(jwtManager auth.Approver) grpc.StreamServerInterce
 
 		claims, err := validateJWT(ss.Context(), jwtManager)
 		if err != nil {
-			log.Printf("[JWT Auth] Unauthorized stream access attempt to %s: %v", info.FullMethod, err)
+			logger.WarnContext(ss.Context(), "unauthenticated stream access attempt", "method", info.FullMethod, "error", err)
 			return err
 		}
 
 		if err := jwtManager.ValidMethod(info.FullMethod, claims); err != nil {
-			log.Printf("[JWT Auth] Forbidden stream access attempt to %s by user %s: %v",
-				info.FullMethod, claims.Username, err)
+			logger.WarnContext(ss.Context(), "forbidden stream access attempt",
+				"method", info.FullMethod, "user_id", claims.UserID, "error", err)
 			return status.Error(codes.PermissionDenied, "insufficient permissions for method")
 		}
 
@@ -126,8 +129,8 @@ func JWTAuthStreamInterceptor(jwtManager auth.Approver) grpc.StreamServerInterce
 			ctx:          context.WithValue(ss.Context(), ClaimsContextKey, claims),
 		}
 
-		log.Printf("[JWT Auth] Authorized stream access to %s by user %s (roles: %v)",
-			info.FullMethod, claims.Username, claims.Roles)
+		logger.DebugContext(ss.Context(), "authorized stream access",
+			"method", info.FullMethod, "user_id", claims.UserID, "roles", claims.Roles)
 		return handler(srv, wrappedStream)
 	}
 }
@@ -2,14 +2,23 @@ package interceptors
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/logging"
 )
 
-// LoggingUnaryInterceptor logs unary RPC calls
+// loggerName is the package name used for per-package log levels
+const loggerName = "interceptors"
+
+// LoggingUnaryInterceptor logs unary RPC calls. At debug level the request and
+// response payloads are logged too, with sensitive fields redacted.
 func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		ctx context.Context,
 		req interface{},
@@ -18,24 +27,26 @@ func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 	) (interface{}, error) {
 		start := time.Now()
 
-		log.Printf("[Unary] Started %s", info.FullMethod)
+		logger.InfoContext(ctx, "rpc started", "kind", "unary", "method", info.FullMethod)
+		logger.DebugContext(ctx, "rpc request", "method", info.FullMethod, "request", logging.Payload(req))
 
 		// Call the handler
 		resp, err := handler(ctx, req)
 
-		duration := time.Since(start)
-		if err != nil {
-			log.Printf("[Unary] Completed %s with error: %v (duration: %v)", info.FullMethod, err, duration)
-		} else {
-			log.Printf("[Unary] Completed %s successfully (duration: %v)", info.FullMethod, duration)
+		if err == nil {
+			logger.DebugContext(ctx, "rpc response", "method", info.FullMethod, "response", logging.Payload(resp))
 		}
+		logCompletion(ctx, logger, "unary", info.FullMethod, time.Since(start), err)
 
 		return resp, err
 	}
 }
 
-// LoggingStreamInterceptor logs streaming RPC calls
+// LoggingStreamInterceptor logs streaming RPC calls. At debug level every
+// message sent and received is logged too, with sensitive fields redacted.
 func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	logger := logging.For(loggerName)
+
 	return func(
 		srv interface{},
 		ss grpc.ServerStream,
@@ -43,28 +54,68 @@ func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
 		handler grpc.StreamHandler,
 	) error {
 		start := time.Now()
+		ctx := ss.Context()
+		streamType := determineStreamType(info)
 
-		streamType := "Unknown"
-		if info.IsClientStream && info.IsServerStream {
-			streamType = "Bidirectional"
-		} else if info.IsClientStream {
-			streamType = "ClientStream"
-		} else if info.IsServerStream {
-			streamType = "ServerStream"
-		}
+		logger.InfoContext(ctx, "rpc started", "kind", streamType, "method", info.FullMethod)
 
-		log.Printf("[%s] Started %s", streamType, info.FullMethod)
+		counted := newInstrumentedStream(ss, start, nil)
+		ss = counted
+
+		// Only pay for per-message logging when it will be written
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			ss = &payloadLoggingStream{ServerStream: ss, logger: logger, method: info.FullMethod}
+		}
 
 		// Call the handler
 		err := handler(srv, ss)
 
-		duration := time.Since(start)
-		if err != nil {
-			log.Printf("[%s] Completed %s with error: %v (duration: %v)", streamType, info.FullMethod, err, duration)
-		} else {
-			log.Printf("[%s] Completed %s successfully (duration: %v)", streamType, info.FullMethod, duration)
-		}
+		logCompletion(ctx, logger, streamType, info.FullMethod, time.Since(start), err, counted.totals().logAttrs()...)
+
+		return err
+	}
+}
+
+// logCompletion writes the final record of an RPC; extra holds additional
+// key-value pairs such as stream message totals
+func logCompletion(ctx context.Context, logger *slog.Logger, kind, method string, duration time.Duration, err error, extra ...any) {
+	if err != nil {
+		logger.WarnContext(ctx, "rpc failed", append([]any{
+			"kind", kind,
+			"method", method,
+			"code", status.Code(err).String(),
+			"duration", duration,
+			"error", err,
+		}, extra...)...)
+		return
+	}
+
+	logger.InfoContext(ctx, "rpc completed", append([]any{
+		"kind", kind,
+		"method", method,
+		"code", "OK",
+		"duration", duration,
+	}, extra...)...)
+}
+
+// payloadLoggingStream logs each message of a stream at debug level
+type payloadLoggingStream struct {
+	grpc.ServerStream
+	logger *slog.Logger
+	method string
+}
+
+// SendMsg logs and sends a message
+func (s *payloadLoggingStream) SendMsg(m interface{}) error {
+	s.logger.DebugContext(s.Context(), "stream send", "method", s.method, "response", logging.Payload(m))
+	return s.ServerStream.SendMsg(m)
+}
 
+// RecvMsg receives and logs a message
+func (s *payloadLoggingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
 		return err
 	}
+	s.logger.DebugContext(s.Context(), "stream recv", "method", s.method, "request", logging.Payload(m))
+	return nil
 }
@@ -3,26 +3,61 @@ package interceptors
 import (
 	"context"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// MetricsCollector collects simple metrics for demonstration
+// StatsWindows are the recent periods reported alongside lifetime totals
+var StatsWindows = []struct {
+	Name   string
+	Period time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+}
+
+// MetricsCollector collects simple metrics for demonstration. Memory use
+// is bounded: each method keeps fixed-size histograms rather than every
+// observed duration.
 type MetricsCollector struct {
-	mu               sync.RWMutex
-	totalRequests    int64
-	totalErrors      int64
-	requestDurations map[string][]time.Duration
-	methodCounts     map[string]int64
+	mu            sync.RWMutex
+	totalRequests int64
+	totalErrors   int64
+	codeCounts    map[codes.Code]int64
+	methods       map[string]*methodMetrics
+
+	// now is replaceable for tests
+	now func() time.Time
+}
+
+// methodMetrics holds the metrics of a single RPC method
+type methodMetrics struct {
+	count            int64
+	codeCounts       map[codes.Code]int64
+	latency          Histogram
+	recent           WindowedHistogram
+	streaming        bool
+	messagesSent     int64
+	messagesReceived int64
+	bytesSent        int64
+	bytesReceived    int64
+	// sendInterval and recvInterval hold the time between consecutive
+	// messages of a stream
+	sendInterval Histogram
+	recvInterval Histogram
 }
 
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector() *MetricsCollector {
 	return &MetricsCollector{
-		requestDurations: make(map[string][]time.Duration),
-		methodCounts:     make(map[string]int64),
+		codeCounts: make(map[codes.Code]int64),
+		methods:    make(map[string]*methodMetrics),
+		now:        time.Now,
 	}
 }
 
@@ -48,7 +83,7 @@ func MetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
 		resp, err := handler(ctx, req)
 
 		duration := time.Since(start)
-		globalMetrics.recordRequest(info.FullMethod, duration, err != nil)
+		globalMetrics.recordRequest(info.FullMethod, duration, err)
 
 		return resp, err
 	}
@@ -63,29 +98,83 @@ func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
 		handler grpc.StreamHandler,
 	) error {
 		start := time.Now()
+		counted := newInstrumentedStream(ss, start, func(ev messageEvent) {
+			globalMetrics.recordMessage(info.FullMethod, ev.direction, ev.interval)
+		})
 
 		// Call the handler
-		err := handler(srv, ss)
+		err := handler(srv, counted)
 
 		duration := time.Since(start)
-		globalMetrics.recordRequest(info.FullMethod, duration, err != nil)
+		globalMetrics.recordStream(info.FullMethod, duration, err, counted.totals())
 
 		return err
 	}
 }
 
 // recordRequest records a request's metrics
-func (m *MetricsCollector) recordRequest(method string, duration time.Duration, isError bool) {
+func (m *MetricsCollector) recordRequest(method string, duration time.Duration, err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.totalRequests++
-	m.methodCounts[method]++
-	m.requestDurations[method] = append(m.requestDurations[method], duration)
+	m.record(method, duration, err)
+}
+
+// recordStream records a stream's metrics including its message totals
+func (m *MetricsCollector) recordStream(method string, duration time.Duration, err error, stats streamStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if isError {
+	mm := m.record(method, duration, err)
+	mm.streaming = true
+	mm.messagesSent += stats.sent
+	mm.messagesReceived += stats.received
+	mm.bytesSent += stats.bytesSent
+	mm.bytesReceived += stats.bytesReceived
+}
+
+// recordMessage records the time since the previous message of a stream
+// in the same direction
+func (m *MetricsCollector) recordMessage(method, direction string, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mm := m.method(method)
+	if direction == messageSent {
+		mm.sendInterval.Record(interval)
+	} else {
+		mm.recvInterval.Record(interval)
+	}
+}
+
+// method returns the metrics of a method, creating them if needed; m.mu must be held
+func (m *MetricsCollector) method(name string) *methodMetrics {
+	mm, ok := m.methods[name]
+	if !ok {
+		mm = &methodMetrics{codeCounts: make(map[codes.Code]int64)}
+		m.methods[name] = mm
+	}
+	return mm
+}
+
+// record updates the totals and the method's metrics; m.mu must be held
+func (m *MetricsCollector) record(method string, duration time.Duration, err error) *methodMetrics {
+	mm := m.method(method)
+
+	code := status.Code(err)
+
+	m.totalRequests++
+	m.codeCounts[code]++
+	if err != nil {
 		m.totalErrors++
 	}
+
+	mm.count++
+	mm.codeCounts[code]++
+	mm.latency.Record(duration)
+	mm.recent.Record(m.now(), duration)
+
+	return mm
 }
 
 // GetStats returns current statistics
@@ -93,46 +182,88 @@ func (m *MetricsCollector) GetStats() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := m.now()
+
 	stats := make(map[string]interface{})
 	stats["total_requests"] = m.totalRequests
 	stats["total_errors"] = m.totalErrors
 	stats["error_rate"] = float64(0)
+	stats["codes"] = codeStats(m.codeCounts)
 
 	if m.totalRequests > 0 {
 		stats["error_rate"] = float64(m.totalErrors) / float64(m.totalRequests) * 100
 	}
 
 	methodStats := make(map[string]interface{})
-	for method, count := range m.methodCounts {
-		durations := m.requestDurations[method]
-		if len(durations) > 0 {
-			var total time.Duration
-			for _, d := range durations {
-				total += d
-			}
-			avg := total / time.Duration(len(durations))
-
-			methodStats[method] = map[string]interface{}{
-				"count":    count,
-				"avg_duration": avg.String(),
-			}
+	for method, mm := range m.methods {
+		latency := mm.latency.Summary()
+		ms := map[string]interface{}{
+			"count":        mm.count,
+			"codes":        codeStats(mm.codeCounts),
+			"avg_duration": latency.Mean.String(),
+			"p50":          latency.P50.String(),
+			"p90":          latency.P90.String(),
+			"p99":          latency.P99.String(),
+			"max":          latency.Max.String(),
+		}
+		for _, w := range StatsWindows {
+			ms["window_"+w.Name] = mm.recent.Window(now, w.Period).Summary()
 		}
+		if mm.streaming {
+			ms["messages_sent"] = mm.messagesSent
+			ms["messages_received"] = mm.messagesReceived
+			ms["bytes_sent"] = mm.bytesSent
+			ms["bytes_received"] = mm.bytesReceived
+			ms["send_interval"] = mm.sendInterval.Summary()
+			ms["recv_interval"] = mm.recvInterval.Summary()
+		}
+		methodStats[method] = ms
 	}
 	stats["methods"] = methodStats
 
 	return stats
 }
 
+// codeStats converts counts by code to counts by code name
+func codeStats(counts map[codes.Code]int64) map[string]int64 {
+	named := make(map[string]int64, len(counts))
+	for code, n := range counts {
+		named[code.String()] = n
+	}
+	return named
+}
+
 // PrintStats logs current statistics
 func (m *MetricsCollector) PrintStats() {
 	stats := m.GetStats()
 	log.Printf("[Metrics] Total Requests: %d", stats["total_requests"])
 	log.Printf("[Metrics] Total Errors: %d", stats["total_errors"])
 	log.Printf("[Metrics] Error Rate: %.2f%%", stats["error_rate"])
+	log.Printf("[Metrics] Codes: %v", stats["codes"])
 
-	if methods, ok := stats["methods"].(map[string]interface{}); ok {
-		for method, methodStats := range methods {
-			log.Printf("[Metrics] %s: %v", method, methodStats)
+	methods, ok := stats["methods"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(methods))
+	for method := range methods {
+		names = append(names, method)
+	}
+	sort.Strings(names)
+
+	for _, method := range names {
+		ms := methods[method].(map[string]interface{})
+		log.Printf("[Metrics] %s: count=%d codes=%v avg=%s p50=%s p90=%s p99=%s max=%s",
+			method, ms["count"], ms["codes"], ms["avg_duration"], ms["p50"], ms["p90"], ms["p99"], ms["max"])
+		if _, ok := ms["messages_sent"]; ok {
+			log.Printf("[Metrics]   messages: sent=%d (%d bytes) received=%d (%d bytes)",
+				ms["messages_sent"], ms["bytes_sent"], ms["messages_received"], ms["bytes_received"])
+			log.Printf("[Metrics]   send interval: %v", ms["send_interval"])
+			log.Printf("[Metrics]   recv interval: %v", ms["recv_interval"])
+		}
+		for _, w := range StatsWindows {
+			log.Printf("[Metrics]   last %s: %v", w.Name, ms["window_"+w.Name])
 		}
 	}
 }
@@ -144,6 +275,6 @@ func (m *MetricsCollector) Reset() {
 
 	m.totalRequests = 0
 	m.totalErrors = 0
-	m.requestDurations = make(map[string][]time.Duration)
-	m.methodCounts = make(map[string]int64)
+	m.codeCounts = make(map[codes.Code]int64)
+	m.methods = make(map[string]*methodMetrics)
 }
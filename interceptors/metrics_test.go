@@ -0,0 +1,98 @@
+package interceptors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	// Buckets grow by 10%, so estimates may be up to 10% high
+	within := func(want, got time.Duration) {
+		t.Helper()
+		assert.GreaterOrEqual(t, got, want)
+		assert.LessOrEqual(t, got, want+want/10)
+	}
+
+	within(500*time.Millisecond, h.Quantile(0.50))
+	within(900*time.Millisecond, h.Quantile(0.90))
+	within(990*time.Millisecond, h.Quantile(0.99))
+	assert.Equal(t, time.Second, h.Max())
+	assert.Equal(t, time.Second, h.Quantile(1))
+	assert.Equal(t, uint64(1000), h.Count())
+}
+
+func TestHistogramExtremes(t *testing.T) {
+	var h Histogram
+	assert.Zero(t, h.Quantile(0.5))
+
+	h.Record(0)
+	h.Record(48 * time.Hour)
+	assert.LessOrEqual(t, h.Quantile(0.5), time.Microsecond)
+	assert.Equal(t, 48*time.Hour, h.Quantile(1))
+}
+
+func TestWindowedHistogram(t *testing.T) {
+	var w WindowedHistogram
+	start := time.Unix(1_700_000_000, 0)
+
+	w.Record(start, time.Second)
+	w.Record(start.Add(2*time.Minute), 10*time.Millisecond)
+	now := start.Add(2*time.Minute + 5*time.Second)
+
+	assert.Equal(t, uint64(1), w.Window(now, time.Minute).Count())
+	assert.Equal(t, uint64(2), w.Window(now, 5*time.Minute).Count())
+
+	// Slots are reused once the ring wraps around
+	w.Record(start.Add(5*time.Minute), time.Millisecond)
+	assert.Equal(t, uint64(2), w.Window(start.Add(5*time.Minute), 5*time.Minute).Count())
+}
+
+func TestMetricsCollectorStats(t *testing.T) {
+	m := NewMetricsCollector()
+	now := time.Unix(1_700_000_000, 0)
+	m.now = func() time.Time { return now }
+
+	m.recordRequest("/svc/Get", 2*time.Millisecond, nil)
+	m.recordRequest("/svc/Get", 4*time.Millisecond, status.Error(codes.NotFound, "missing"))
+	m.recordStream("/svc/List", time.Second, nil, streamStats{sent: 3, received: 1, bytesSent: 30, bytesReceived: 5})
+	m.recordMessage("/svc/List", messageSent, 20*time.Millisecond)
+
+	stats := m.GetStats()
+	assert.Equal(t, int64(3), stats["total_requests"])
+	assert.Equal(t, int64(1), stats["total_errors"])
+	assert.Equal(t, map[string]int64{"OK": 2, "NotFound": 1}, stats["codes"])
+
+	methods := stats["methods"].(map[string]interface{})
+
+	get := methods["/svc/Get"].(map[string]interface{})
+	assert.Equal(t, int64(2), get["count"])
+	assert.Equal(t, map[string]int64{"OK": 1, "NotFound": 1}, get["codes"])
+	assert.Equal(t, "4ms", get["max"])
+	assert.NotContains(t, get, "messages_sent")
+
+	recent, ok := get["window_1m"].(LatencySummary)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), recent.Count)
+
+	list := methods["/svc/List"].(map[string]interface{})
+	assert.Equal(t, int64(3), list["messages_sent"])
+	assert.Equal(t, int64(1), list["messages_received"])
+	assert.Equal(t, int64(30), list["bytes_sent"])
+	assert.Equal(t, uint64(1), list["send_interval"].(LatencySummary).Count)
+
+	// Nothing recorded in the last minute once time moves on
+	now = now.Add(2 * time.Minute)
+	get = m.GetStats()["methods"].(map[string]interface{})["/svc/Get"].(map[string]interface{})
+	assert.Equal(t, uint64(0), get["window_1m"].(LatencySummary).Count)
+	assert.Equal(t, uint64(2), get["window_5m"].(LatencySummary).Count)
+}
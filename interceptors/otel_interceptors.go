@@ -2,7 +2,7 @@ package interceptors
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -12,6 +12,9 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/logging"
+	"github.com/paulstuart/grpc-example/requestid"
 )
 
 const (
@@ -24,6 +27,10 @@ type OtelMetrics struct {
 	requestDuration  metric.Float64Histogram
 	errorCounter     metric.Int64Counter
 	activeRequests   metric.Int64UpDownCounter
+	streamMessages   metric.Int64Counter
+	streamBytes      metric.Int64Counter
+	messageSize      metric.Int64Histogram
+	messageInterval  metric.Float64Histogram
 }
 
 var globalOtelMetrics *OtelMetrics
@@ -68,20 +75,62 @@ func InitializeOtelMetrics() error {
 		return err
 	}
 
+	streamMessages, err := meter.Int64Counter(
+		"grpc.server.stream.messages",
+		metric.WithDescription("Messages sent and received on gRPC streams"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	streamBytes, err := meter.Int64Counter(
+		"grpc.server.stream.bytes",
+		metric.WithDescription("Encoded bytes of messages sent and received on gRPC streams"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	messageSize, err := meter.Int64Histogram(
+		"grpc.server.stream.message.size",
+		metric.WithDescription("Encoded size of gRPC stream messages"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304),
+	)
+	if err != nil {
+		return err
+	}
+
+	messageInterval, err := meter.Float64Histogram(
+		"grpc.server.stream.message.interval",
+		metric.WithDescription("Time between consecutive messages of a gRPC stream in the same direction"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
 	globalOtelMetrics = &OtelMetrics{
 		requestCounter:  requestCounter,
 		requestDuration: requestDuration,
 		errorCounter:    errorCounter,
 		activeRequests:  activeRequests,
+		streamMessages:  streamMessages,
+		streamBytes:     streamBytes,
+		messageSize:     messageSize,
+		messageInterval: messageInterval,
 	}
 
-	log.Println("OpenTelemetry metrics initialized")
+	logging.For(loggerName).Info("OpenTelemetry metrics initialized")
 	return nil
 }
 
 // OtelLoggingUnaryInterceptor combines logging with OpenTelemetry tracing
 func OtelLoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 	tracer := otel.Tracer(instrumentationName)
+	logger := logging.For(loggerName)
 
 	return func(
 		ctx context.Context,
@@ -96,12 +145,14 @@ func OtelLoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 				attribute.String("rpc.service", extractService(info.FullMethod)),
 				attribute.String("rpc.method", extractMethod(info.FullMethod)),
 				attribute.String("rpc.grpc.kind", "unary"),
+				requestid.Attribute(requestid.FromContext(ctx)),
 			),
 		)
 		defer span.End()
 
 		start := time.Now()
-		log.Printf("[Unary] Started %s", info.FullMethod)
+		logger.InfoContext(ctx, "rpc started", "kind", "unary", "method", info.FullMethod)
+		logger.DebugContext(ctx, "rpc request", "method", info.FullMethod, "request", logging.Payload(req))
 
 		// Call the handler
 		resp, err := handler(ctx, req)
@@ -118,16 +169,14 @@ func OtelLoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 				attribute.String("rpc.grpc.status_code", st.Code().String()),
 				attribute.String("error.message", st.Message()),
 			)
-
-			log.Printf("[Unary] Completed %s with error: %v (duration: %v)",
-				info.FullMethod, err, duration)
 		} else {
 			span.SetStatus(codes.Ok, "Success")
 			span.SetAttributes(attribute.String("rpc.grpc.status_code", "OK"))
-			log.Printf("[Unary] Completed %s successfully (duration: %v)",
-				info.FullMethod, duration)
+			logger.DebugContext(ctx, "rpc response", "method", info.FullMethod, "response", logging.Payload(resp))
 		}
 
+		logCompletion(ctx, logger, "unary", info.FullMethod, duration, err)
+
 		span.SetAttributes(attribute.Int64("rpc.duration_ms", duration.Milliseconds()))
 
 		// Add user info from JWT claims if available
@@ -146,6 +195,7 @@ func OtelLoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 // OtelLoggingStreamInterceptor combines logging with OpenTelemetry tracing for streams
 func OtelLoggingStreamInterceptor() grpc.StreamServerInterceptor {
 	tracer := otel.Tracer(instrumentationName)
+	logger := logging.For(loggerName)
 
 	return func(
 		srv interface{},
@@ -161,6 +211,7 @@ func OtelLoggingStreamInterceptor() grpc.StreamServerInterceptor {
 				attribute.String("rpc.method", extractMethod(info.FullMethod)),
 				attribute.Bool("rpc.grpc.is_client_stream", info.IsClientStream),
 				attribute.Bool("rpc.grpc.is_server_stream", info.IsServerStream),
+				requestid.Attribute(requestid.FromContext(ss.Context())),
 			),
 		)
 		defer span.End()
@@ -170,18 +221,41 @@ func OtelLoggingStreamInterceptor() grpc.StreamServerInterceptor {
 		streamType := determineStreamType(info)
 		span.SetAttributes(attribute.String("rpc.grpc.kind", streamType))
 
-		log.Printf("[%s] Started %s", streamType, info.FullMethod)
+		logger.InfoContext(ctx, "rpc started", "kind", streamType, "method", info.FullMethod)
 
-		// Wrap the stream to use the traced context
-		wrappedStream := &tracedServerStream{
+		// Wrap the stream to use the traced context, recording an event per
+		// message and a child span for each slow one
+		instrumented := newInstrumentedStream(&tracedServerStream{
 			ServerStream: ss,
 			ctx:          ctx,
+		}, start, func(ev messageEvent) {
+			recordMessageEvent(ctx, tracer, info.FullMethod, span, ev)
+		})
+		instrumented.onProcessed = func(seq int64, start, end time.Time) {
+			if end.Sub(start) >= SlowStreamMessageThreshold {
+				startChildSpan(ctx, tracer, info.FullMethod+"/process", start, end,
+					attribute.String("message.type", messageReceived),
+					attribute.Int64("message.id", seq),
+				)
+			}
+		}
+		var wrappedStream grpc.ServerStream = instrumented
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			wrappedStream = &payloadLoggingStream{ServerStream: wrappedStream, logger: logger, method: info.FullMethod}
 		}
 
 		// Call the handler
 		err := handler(srv, wrappedStream)
 
 		duration := time.Since(start)
+		instrumented.finish(time.Now())
+		totals := instrumented.totals()
+		span.SetAttributes(
+			attribute.Int64("rpc.messages_sent", totals.sent),
+			attribute.Int64("rpc.messages_received", totals.received),
+			attribute.Int64("rpc.bytes_sent", totals.bytesSent),
+			attribute.Int64("rpc.bytes_received", totals.bytesReceived),
+		)
 
 		// Record span status
 		if err != nil {
@@ -193,16 +267,13 @@ func OtelLoggingStreamInterceptor() grpc.StreamServerInterceptor {
 				attribute.String("rpc.grpc.status_code", st.Code().String()),
 				attribute.String("error.message", st.Message()),
 			)
-
-			log.Printf("[%s] Completed %s with error: %v (duration: %v)",
-				streamType, info.FullMethod, err, duration)
 		} else {
 			span.SetStatus(codes.Ok, "Success")
 			span.SetAttributes(attribute.String("rpc.grpc.status_code", "OK"))
-			log.Printf("[%s] Completed %s successfully (duration: %v)",
-				streamType, info.FullMethod, duration)
 		}
 
+		logCompletion(ctx, logger, streamType, info.FullMethod, duration, err, totals.logAttrs()...)
+
 		span.SetAttributes(attribute.Int64("rpc.duration_ms", duration.Milliseconds()))
 
 		// Add user info from JWT claims if available
@@ -218,6 +289,40 @@ func OtelLoggingStreamInterceptor() grpc.StreamServerInterceptor {
 	}
 }
 
+// recordMessageEvent adds a span event for a stream message, following the
+// OpenTelemetry RPC conventions, and a child span if sending it was slow
+func recordMessageEvent(ctx context.Context, tracer trace.Tracer, method string, span trace.Span, ev messageEvent) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent("message",
+		trace.WithTimestamp(ev.at),
+		trace.WithAttributes(
+			attribute.String("message.type", ev.direction),
+			attribute.Int64("message.id", ev.seq),
+			attribute.Int("message.uncompressed_size", ev.size),
+		),
+	)
+
+	if ev.took >= SlowStreamMessageThreshold {
+		startChildSpan(ctx, tracer, method+"/send", ev.at, ev.at.Add(ev.took),
+			attribute.String("message.type", ev.direction),
+			attribute.Int64("message.id", ev.seq),
+			attribute.Int("message.uncompressed_size", ev.size),
+		)
+	}
+}
+
+// startChildSpan records a span for work that has already finished
+func startChildSpan(ctx context.Context, tracer trace.Tracer, name string, start, end time.Time, attrs ...attribute.KeyValue) {
+	_, child := tracer.Start(ctx, name,
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+	)
+	child.End(trace.WithTimestamp(end))
+}
+
 // OtelMetricsUnaryInterceptor collects OpenTelemetry metrics for unary RPCs
 func OtelMetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(
@@ -291,8 +396,16 @@ func OtelMetricsStreamInterceptor() grpc.StreamServerInterceptor {
 
 		start := time.Now()
 
+		instrumented := newInstrumentedStream(ss, start, func(ev messageEvent) {
+			msgAttrs := metric.WithAttributes(append(attrs, attribute.String("message.type", ev.direction))...)
+			globalOtelMetrics.streamMessages.Add(ctx, 1, msgAttrs)
+			globalOtelMetrics.streamBytes.Add(ctx, int64(ev.size), msgAttrs)
+			globalOtelMetrics.messageSize.Record(ctx, int64(ev.size), msgAttrs)
+			globalOtelMetrics.messageInterval.Record(ctx, float64(ev.interval)/float64(time.Millisecond), msgAttrs)
+		})
+
 		// Call the handler
-		err := handler(srv, ss)
+		err := handler(srv, instrumented)
 
 		duration := time.Since(start).Milliseconds()
 
@@ -0,0 +1,110 @@
+package interceptors
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+// RequestIDUnaryInterceptor accepts or generates an x-request-id for unary RPCs.
+// It should be first in the chain so every later interceptor sees the ID.
+func RequestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, id := withRequestID(ctx)
+
+		resp, err := handler(ctx, req)
+
+		setRequestIDTrailer(ctx, id)
+		return resp, attachRequestID(err, id)
+	}
+}
+
+// RequestIDStreamInterceptor accepts or generates an x-request-id for streaming RPCs
+func RequestIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, id := withRequestID(ss.Context())
+
+		wrappedStream := &serverStreamWithContext{
+			ServerStream: ss,
+			ctx:          ctx,
+		}
+
+		err := handler(srv, wrappedStream)
+
+		ss.SetTrailer(metadata.Pairs(requestid.Header, id))
+		return attachRequestID(err, id)
+	}
+}
+
+// GetRequestIDFromContext returns the request ID for the current RPC, if any
+func GetRequestIDFromContext(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// withRequestID pulls the request ID from incoming metadata (or makes one),
+// stores it in the context, tags the current span and sends it back as a header
+func withRequestID(ctx context.Context) (context.Context, string) {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestid.Header); len(values) > 0 {
+			incoming = values[0]
+		}
+	}
+
+	id := requestid.Ensure(incoming)
+	ctx = requestid.NewContext(ctx, id)
+	trace.SpanFromContext(ctx).SetAttributes(requestid.Attribute(id))
+
+	// SetHeader only fails if headers were already sent, which can't
+	// have happened before the handler runs
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id))
+
+	return ctx, id
+}
+
+// setRequestIDTrailer echoes the request ID in the trailer of a unary RPC
+func setRequestIDTrailer(ctx context.Context, id string) {
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(requestid.Header, id))
+}
+
+// attachRequestID adds a RequestInfo detail carrying the request ID to a gRPC
+// error so clients can quote it when reporting problems
+func attachRequestID(err error, id string) error {
+	if err == nil {
+		return nil
+	}
+
+	// Convert plain errors the same way gRPC itself would
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.FromContextError(err)
+	}
+
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RequestInfo); ok {
+			return err
+		}
+	}
+
+	withInfo, detailErr := st.WithDetails(&errdetails.RequestInfo{RequestId: id})
+	if detailErr != nil {
+		return err
+	}
+	return withInfo.Err()
+}
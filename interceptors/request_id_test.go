@@ -0,0 +1,88 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+func TestRequestIDUnaryInterceptor(t *testing.T) {
+	interceptor := RequestIDUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/proto.UserService/GetUser",
+	}
+
+	t.Run("uses incoming request id", func(t *testing.T) {
+		md := metadata.Pairs(requestid.Header, "abc-123")
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+
+		var seen string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			seen = GetRequestIDFromContext(ctx)
+			return "success", nil
+		}
+
+		resp, err := interceptor(ctx, nil, info, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "success", resp)
+		assert.Equal(t, "abc-123", seen)
+	})
+
+	t.Run("generates missing request id", func(t *testing.T) {
+		var seen string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			seen = GetRequestIDFromContext(ctx)
+			return nil, nil
+		}
+
+		_, err := interceptor(context.Background(), nil, info, handler)
+		require.NoError(t, err)
+		assert.True(t, requestid.Valid(seen))
+	})
+
+	t.Run("replaces invalid request id", func(t *testing.T) {
+		md := metadata.Pairs(requestid.Header, "bad id\nwith newline")
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+
+		var seen string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			seen = GetRequestIDFromContext(ctx)
+			return nil, nil
+		}
+
+		_, err := interceptor(ctx, nil, info, handler)
+		require.NoError(t, err)
+		assert.NotEqual(t, "bad id\nwith newline", seen)
+		assert.True(t, requestid.Valid(seen))
+	})
+
+	t.Run("error carries request id", func(t *testing.T) {
+		md := metadata.Pairs(requestid.Header, "err-456")
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		_, err := interceptor(ctx, nil, info, handler)
+		require.Error(t, err)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.NotFound, st.Code())
+		assert.Equal(t, "user not found", st.Message())
+
+		require.Len(t, st.Details(), 1)
+		reqInfo, ok := st.Details()[0].(*errdetails.RequestInfo)
+		require.True(t, ok)
+		assert.Equal(t, "err-456", reqInfo.RequestId)
+	})
+}
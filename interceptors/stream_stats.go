@@ -0,0 +1,163 @@
+package interceptors
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Message directions, as used by the OpenTelemetry RPC conventions for
+// the message.type attribute
+const (
+	messageSent     = "SENT"
+	messageReceived = "RECEIVED"
+)
+
+// SlowStreamMessageThreshold is how long sending or handling a single stream
+// message may take before it gets its own child span
+var SlowStreamMessageThreshold = 100 * time.Millisecond
+
+// messageEvent describes one message sent or received on a stream
+type messageEvent struct {
+	direction string
+	// seq numbers the messages of each direction from 1
+	seq  int64
+	size int
+	at   time.Time
+	// interval is the time since the previous message in the same direction,
+	// or since the stream started for the first one
+	interval time.Duration
+	// took is how long SendMsg blocked; zero for received messages
+	took time.Duration
+}
+
+// streamStats are the totals of a stream
+type streamStats struct {
+	sent, received           int64
+	bytesSent, bytesReceived int64
+}
+
+// logAttrs returns the totals as slog key-value pairs
+func (s streamStats) logAttrs() []any {
+	return []any{
+		"messages_sent", s.sent,
+		"messages_received", s.received,
+		"bytes_sent", s.bytesSent,
+		"bytes_received", s.bytesReceived,
+	}
+}
+
+// instrumentedStream observes every message of a server stream. onMessage is
+// called for each message; onProcessed, if set, is called when the handler
+// has finished with a received message, i.e. when it asks for the next one
+// or returns. Handlers may send and receive on different goroutines.
+type instrumentedStream struct {
+	grpc.ServerStream
+	onMessage   func(messageEvent)
+	onProcessed func(seq int64, start, end time.Time)
+
+	mu                     sync.Mutex
+	stats                  streamStats
+	lastSent, lastReceived time.Time
+	pendingSeq             int64
+	pendingAt              time.Time
+}
+
+// newInstrumentedStream wraps ss; start is when the RPC began
+func newInstrumentedStream(ss grpc.ServerStream, start time.Time, onMessage func(messageEvent)) *instrumentedStream {
+	return &instrumentedStream{
+		ServerStream: ss,
+		onMessage:    onMessage,
+		lastSent:     start,
+		lastReceived: start,
+	}
+}
+
+// SendMsg sends and observes a message
+func (s *instrumentedStream) SendMsg(m interface{}) error {
+	start := time.Now()
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	took := time.Since(start)
+	size := messageSize(m)
+
+	s.mu.Lock()
+	s.stats.sent++
+	s.stats.bytesSent += int64(size)
+	ev := messageEvent{
+		direction: messageSent,
+		seq:       s.stats.sent,
+		size:      size,
+		at:        start,
+		interval:  start.Sub(s.lastSent),
+		took:      took,
+	}
+	s.lastSent = start
+	s.mu.Unlock()
+
+	if s.onMessage != nil {
+		s.onMessage(ev)
+	}
+	return nil
+}
+
+// RecvMsg receives and observes a message
+func (s *instrumentedStream) RecvMsg(m interface{}) error {
+	s.finish(time.Now())
+
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	at := time.Now()
+	size := messageSize(m)
+
+	s.mu.Lock()
+	s.stats.received++
+	s.stats.bytesReceived += int64(size)
+	ev := messageEvent{
+		direction: messageReceived,
+		seq:       s.stats.received,
+		size:      size,
+		at:        at,
+		interval:  at.Sub(s.lastReceived),
+	}
+	s.lastReceived = at
+	s.pendingSeq, s.pendingAt = ev.seq, at
+	s.mu.Unlock()
+
+	if s.onMessage != nil {
+		s.onMessage(ev)
+	}
+	return nil
+}
+
+// finish reports the handling of the last received message as done.
+// Interceptors call it once more after the handler returns.
+func (s *instrumentedStream) finish(end time.Time) {
+	s.mu.Lock()
+	seq, start := s.pendingSeq, s.pendingAt
+	s.pendingSeq = 0
+	s.mu.Unlock()
+
+	if seq > 0 && s.onProcessed != nil {
+		s.onProcessed(seq, start, end)
+	}
+}
+
+// totals returns the stream's totals so far
+func (s *instrumentedStream) totals() streamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// messageSize returns the encoded size of a proto message
+func messageSize(m interface{}) int {
+	if msg, ok := m.(proto.Message); ok {
+		return proto.Size(msg)
+	}
+	return 0
+}
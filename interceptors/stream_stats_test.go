@@ -0,0 +1,74 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// fakeServerStream receives queued messages and discards sent ones
+type fakeServerStream struct {
+	grpc.ServerStream
+	incoming []*pb.User
+}
+
+func (f *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (f *fakeServerStream) SendMsg(interface{}) error { return nil }
+
+func (f *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(f.incoming) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), f.incoming[0])
+	f.incoming = f.incoming[1:]
+	return nil
+}
+
+func TestInstrumentedStream(t *testing.T) {
+	users := []*pb.User{{Id: 1, Username: "a"}, {Id: 2, Username: "bb"}}
+	fake := &fakeServerStream{incoming: users}
+
+	var events []messageEvent
+	var processed []int64
+	s := newInstrumentedStream(fake, time.Now(), func(ev messageEvent) {
+		events = append(events, ev)
+	})
+	s.onProcessed = func(seq int64, start, end time.Time) {
+		assert.False(t, end.Before(start))
+		processed = append(processed, seq)
+	}
+
+	// Echo every message, as SyncUsers does
+	for {
+		var u pb.User
+		if err := s.RecvMsg(&u); err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+		require.NoError(t, s.SendMsg(&u))
+	}
+	s.finish(time.Now())
+
+	totals := s.totals()
+	wantBytes := int64(proto.Size(users[0]) + proto.Size(users[1]))
+	assert.Equal(t, streamStats{sent: 2, received: 2, bytesSent: wantBytes, bytesReceived: wantBytes}, totals)
+
+	require.Len(t, events, 4)
+	assert.Equal(t, messageReceived, events[0].direction)
+	assert.Equal(t, int64(1), events[0].seq)
+	assert.Equal(t, messageSent, events[3].direction)
+	assert.Equal(t, int64(2), events[3].seq)
+	assert.Equal(t, proto.Size(users[1]), events[3].size)
+
+	// Each received message is processed once, the last when the handler returns
+	assert.Equal(t, []int64{1, 2}, processed)
+}
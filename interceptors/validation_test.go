@@ -0,0 +1,81 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/server"
+)
+
+func newValidatingClient(t *testing.T) pb.UserServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(ValidationUnaryInterceptor()),
+		grpc.StreamInterceptor(ValidationStreamInterceptor()),
+	)
+	pb.RegisterUserServiceServer(grpcServer, server.New(server.NewMemoryStorage()))
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewUserServiceClient(conn)
+}
+
+func TestValidationUnaryInterceptor(t *testing.T) {
+	client := newValidatingClient(t)
+
+	_, err := client.AddUser(context.Background(), &pb.User{Id: 1, Username: "alice", Email: "not-an-email"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "email")
+
+	_, err = client.AddUser(context.Background(), &pb.User{Id: 1, Username: "alice", Role: pb.Role_ADMIN, Email: "alice@example.com"})
+	require.NoError(t, err)
+}
+
+func TestValidationStreamInterceptor(t *testing.T) {
+	client := newValidatingClient(t)
+
+	// Invalid users fail individually while the rest of the batch is added
+	stream, err := client.BatchAddUsers(context.Background())
+	require.NoError(t, err)
+	for _, user := range []*pb.User{
+		{Id: 1, Username: "alice", Role: pb.Role_ADMIN},
+		{Id: 4, Username: "-nobody"},
+		{Id: 2, Username: "bob", Tags: []string{"dup", "dup"}},
+		{Id: 3, Username: "carol"},
+	} {
+		require.NoError(t, stream.Send(user))
+	}
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), resp.TotalReceived)
+	assert.Equal(t, int32(2), resp.TotalAdded)
+	require.Len(t, resp.Errors, 2)
+	assert.Contains(t, resp.Errors[0], "user 2: username:")
+	assert.Contains(t, resp.Errors[1], "user 3: tags:")
+
+	sync, err := client.SyncUsers(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, sync.Send(&pb.User{Id: 9, Email: "bad"}))
+	got, err := sync.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(9), got.UserId)
+	assert.Equal(t, pb.SyncUserResponse_FAILED, got.Status)
+	require.NoError(t, sync.CloseSend())
+}
@@ -0,0 +1,193 @@
+// Package logging configures the process-wide slog logger: JSON or text
+// output, a default level with per-package overrides, request ID tagging
+// and redaction of sensitive values.
+//
+// Packages get their logger with For, after main has called Setup:
+//
+//	logger := logging.For("interceptors")
+//	logger.InfoContext(ctx, "user added", "user_id", id)
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+// Format selects the log output encoding
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// PackageKey is the attribute key identifying which package logged a record
+const PackageKey = "pkg"
+
+// Config holds logging configuration
+type Config struct {
+	// Format is the output encoding, text (default) or json
+	Format Format
+	// Level is the default minimum level
+	Level slog.Level
+	// PackageLevels overrides Level for specific packages, as named in For
+	PackageLevels map[string]slog.Level
+	// AddSource includes the caller's file and line in each record
+	AddSource bool
+	// Output is where logs are written (default os.Stderr)
+	Output io.Writer
+	// Redactor scrubs sensitive values (default DefaultRedactor)
+	Redactor *Redactor
+}
+
+// state is the currently configured root handler and levels
+type state struct {
+	mu       sync.RWMutex
+	root     slog.Handler
+	level    *slog.LevelVar
+	packages map[string]*slog.LevelVar
+}
+
+var current = &state{
+	root:     slog.Default().Handler(),
+	level:    new(slog.LevelVar),
+	packages: make(map[string]*slog.LevelVar),
+}
+
+// Setup builds the root handler from cfg and installs it as the slog default.
+// Loggers obtained from For before Setup keep writing to the previous handler.
+func Setup(cfg Config) *slog.Logger {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	redactor := cfg.Redactor
+	if redactor == nil {
+		redactor = DefaultRedactor()
+	}
+
+	// The output handler accepts everything, levelHandler does the filtering
+	opts := &slog.HandlerOptions{
+		AddSource: cfg.AddSource,
+		Level:     slog.LevelDebug - 4,
+	}
+
+	var base slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		base = slog.NewJSONHandler(out, opts)
+	default:
+		base = slog.NewTextHandler(out, opts)
+	}
+
+	root := requestid.NewHandler(NewRedactingHandler(base, redactor))
+
+	current.mu.Lock()
+	current.root = root
+	current.level.Set(cfg.Level)
+	for pkg, level := range cfg.PackageLevels {
+		lv, ok := current.packages[pkg]
+		if !ok {
+			lv = new(slog.LevelVar)
+			current.packages[pkg] = lv
+		}
+		lv.Set(level)
+	}
+	current.mu.Unlock()
+
+	logger := slog.New(&levelHandler{Handler: root, level: current.level})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// For returns the logger for the named package. Records are tagged with
+// pkg=<name> and filtered by that package's level, if one was configured.
+func For(pkg string) *slog.Logger {
+	current.mu.RLock()
+	root := current.root
+	level, ok := current.packages[pkg]
+	current.mu.RUnlock()
+
+	var leveler slog.Leveler = current.level
+	if ok {
+		leveler = level
+	}
+
+	return slog.New(&levelHandler{
+		Handler: root.WithAttrs([]slog.Attr{slog.String(PackageKey, pkg)}),
+		level:   leveler,
+	})
+}
+
+// SetPackageLevel changes the level of a package at runtime
+func SetPackageLevel(pkg string, level slog.Level) {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	lv, ok := current.packages[pkg]
+	if !ok {
+		lv = new(slog.LevelVar)
+		current.packages[pkg] = lv
+	}
+	lv.Set(level)
+}
+
+// ParseLevel parses a level name such as debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+// ParsePackageLevels parses a comma separated list of package=level pairs,
+// e.g. "interceptors=debug,server=warn"
+func ParsePackageLevels(s string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	if strings.TrimSpace(s) == "" {
+		return levels, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		pkg, lvl, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid package level %q, expected package=level", pair)
+		}
+		level, err := ParseLevel(lvl)
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(pkg)] = level
+	}
+	return levels, nil
+}
+
+// levelHandler filters records below its level before handing them on
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+// Enabled implements slog.Handler
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+// WithAttrs implements slog.Handler
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup implements slog.Handler
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
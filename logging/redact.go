@@ -0,0 +1,250 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// Redacted replaces sensitive values in logs
+const Redacted = "[REDACTED]"
+
+// DefaultSensitivePaths are field names and attribute keys that are always
+// redacted, in addition to proto fields marked with the (sensitive) option
+var DefaultSensitivePaths = []string{
+	"email",
+	"phone",
+	"addresses",
+	"street",
+	"postal_code",
+	"date_of_birth",
+	"token",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"password",
+	"secret",
+	"authorization",
+}
+
+var (
+	// jwtPattern matches the three base64url segments of a JWT
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// bearerPattern matches an Authorization header value
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`)
+	// emailPattern matches email addresses embedded in free text
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// Redactor decides which values are sensitive and scrubs them
+type Redactor struct {
+	paths map[string]bool
+}
+
+// NewRedactor creates a Redactor for the given field names, dotted field
+// paths (e.g. "profile.bio") and attribute keys. Matching is case-insensitive.
+func NewRedactor(paths ...string) *Redactor {
+	r := &Redactor{paths: make(map[string]bool, len(paths))}
+	for _, p := range paths {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			r.paths[p] = true
+		}
+	}
+	return r
+}
+
+// DefaultRedactor returns a Redactor for DefaultSensitivePaths
+func DefaultRedactor() *Redactor {
+	return NewRedactor(DefaultSensitivePaths...)
+}
+
+// With returns a copy of r that also redacts the given paths
+func (r *Redactor) With(paths ...string) *Redactor {
+	all := make([]string, 0, len(r.paths)+len(paths))
+	for p := range r.paths {
+		all = append(all, p)
+	}
+	return NewRedactor(append(all, paths...)...)
+}
+
+// SensitiveKey reports whether a log attribute key names a sensitive value
+func (r *Redactor) SensitiveKey(key string) bool {
+	return r.paths[strings.ToLower(key)]
+}
+
+// sensitiveField reports whether fd, reached via path, must be redacted
+func (r *Redactor) sensitiveField(fd protoreflect.FieldDescriptor, path string) bool {
+	if opts := fd.Options(); opts != nil && proto.HasExtension(opts, pb.E_Sensitive) {
+		if sensitive, _ := proto.GetExtension(opts, pb.E_Sensitive).(bool); sensitive {
+			return true
+		}
+	}
+	return r.paths[strings.ToLower(string(fd.Name()))] || r.paths[strings.ToLower(path)]
+}
+
+// Message returns a redacted copy of msg; msg itself is never modified
+func (r *Redactor) Message(msg proto.Message) proto.Message {
+	if msg == nil {
+		return nil
+	}
+	clone := proto.Clone(msg)
+	r.redactMessage(clone.ProtoReflect(), "")
+	return clone
+}
+
+// redactMessage scrubs sensitive fields of m in place
+func (r *Redactor) redactMessage(m protoreflect.Message, prefix string) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if r.sensitiveField(fd, path) {
+			r.redactField(m, fd, v)
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				r.redactMessage(list.Get(i).Message(), path)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				r.redactMessage(mv.Message(), path)
+				return true
+			})
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			r.redactMessage(v.Message(), path)
+		}
+		return true
+	})
+}
+
+// redactField replaces string values with the Redacted marker and clears
+// anything else, so the field's presence stays visible but its value does not
+func (r *Redactor) redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsList() && fd.Kind() == protoreflect.StringKind:
+		list := v.List()
+		for i := 0; i < list.Len(); i++ {
+			list.Set(i, protoreflect.ValueOfString(Redacted))
+		}
+	case fd.IsMap() && fd.MapValue().Kind() == protoreflect.StringKind:
+		mp := v.Map()
+		mp.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			mp.Set(k, protoreflect.ValueOfString(Redacted))
+			return true
+		})
+	case !fd.IsList() && !fd.IsMap() && fd.Kind() == protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(Redacted))
+	default:
+		m.Clear(fd)
+	}
+}
+
+// Text scrubs tokens and email addresses from free text such as error messages
+func (r *Redactor) Text(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+Redacted)
+	s = emailPattern.ReplaceAllString(s, Redacted)
+	return s
+}
+
+// Attr returns a redacted copy of a log attribute
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	if r.SensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Text(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		scrubbed := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			scrubbed[i] = r.Attr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindAny:
+		switch val := v.Any().(type) {
+		case proto.Message:
+			return slog.String(a.Key, r.JSON(val))
+		case error:
+			return slog.String(a.Key, r.Text(val.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// JSON renders a redacted copy of msg as compact protojson
+func (r *Redactor) JSON(msg proto.Message) string {
+	b, err := protojson.Marshal(r.Message(msg))
+	if err != nil {
+		return "<unmarshalable " + string(msg.ProtoReflect().Descriptor().FullName()) + ">"
+	}
+	return string(b)
+}
+
+// RedactingHandler is a slog.Handler that scrubs sensitive values from
+// the message and attributes of every record
+type RedactingHandler struct {
+	handler  slog.Handler
+	redactor *Redactor
+}
+
+// NewRedactingHandler wraps h so records are redacted by r before being written
+func NewRedactingHandler(h slog.Handler, r *Redactor) *RedactingHandler {
+	return &RedactingHandler{handler: h, redactor: r}
+}
+
+// Enabled implements slog.Handler
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	scrubbed := slog.NewRecord(record.Time, record.Level, h.redactor.Text(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(h.redactor.Attr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, scrubbed)
+}
+
+// WithAttrs implements slog.Handler
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = h.redactor.Attr(a)
+	}
+	return &RedactingHandler{handler: h.handler.WithAttrs(scrubbed), redactor: h.redactor}
+}
+
+// WithGroup implements slog.Handler
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{handler: h.handler.WithGroup(name), redactor: h.redactor}
+}
+
+// Payload prepares a request or response for logging. Proto messages are
+// kept as-is so RedactingHandler can render them as redacted protojson;
+// anything else is logged by type name only, since it can't be redacted.
+func Payload(v any) slog.Value {
+	if msg, ok := v.(proto.Message); ok {
+		return slog.AnyValue(msg)
+	}
+	return slog.StringValue(fmt.Sprintf("%T", v))
+}
@@ -5,28 +5,55 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
+	"log/slog"
 	"mime"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-
+	grpcinsecure "google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/paulstuart/grpc-example/audit"
+	"github.com/paulstuart/grpc-example/auth"
+	"github.com/paulstuart/grpc-example/capture"
+	"github.com/paulstuart/grpc-example/compression"
+	"github.com/paulstuart/grpc-example/health"
+	"github.com/paulstuart/grpc-example/httpstream"
+	"github.com/paulstuart/grpc-example/idempotency"
 	"github.com/paulstuart/grpc-example/insecure"
 	"github.com/paulstuart/grpc-example/interceptors"
+	"github.com/paulstuart/grpc-example/logging"
 	"github.com/paulstuart/grpc-example/otel"
+	"github.com/paulstuart/grpc-example/problem"
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/requestid"
+	"github.com/paulstuart/grpc-example/schema"
 	"github.com/paulstuart/grpc-example/server"
+	"github.com/paulstuart/grpc-example/webrpc"
+	"github.com/paulstuart/grpc-example/wsbridge"
 )
 
 var (
@@ -41,6 +68,7 @@ var (
 	gatewayPort   = flag.Int("gateway-port", defaultRest, "The gRPC-Gateway server port")
 	nocheck       = flag.Bool("insecure", false, "don't complain about self-signed certs")
 	enableAuth    = flag.Bool("enable-auth", false, "enable authentication interceptor")
+	singlePort    = flag.Bool("single-port", DefaultEnv("SINGLE_PORT", false), "serve gRPC, the gateway and OpenAPI UI on the gateway port, with the gateway calling gRPC in-process")
 	printMetrics  = flag.Bool("print-metrics", false, "print metrics on shutdown")
 	hostname      = flag.String("host", defaultHost, "bind to host address")
 	validateToken = flag.String("validate", "", "validate this JWT token and exit")
@@ -54,8 +82,50 @@ var (
 	serviceName  = flag.String("service-name", DefaultEnv("SERVICE_NAME", "grpc-example"), "service name for OpenTelemetry")
 	environment  = flag.String("environment", DefaultEnv("ENVIRONMENT", "development"), "deployment environment")
 
+	// Prometheus flags
+	prometheusEnabled = flag.Bool("prometheus", DefaultEnv("PROMETHEUS_ENABLED", false), "expose Prometheus metrics on the gateway")
+	metricsPath       = flag.String("metrics-path", DefaultEnv("METRICS_PATH", "/metrics"), "gateway path for Prometheus metrics")
+	slowMessage       = flag.Duration("slow-message-threshold", DefaultEnv("SLOW_MESSAGE_THRESHOLD", interceptors.SlowStreamMessageThreshold), "trace stream messages slower than this as child spans")
+
+	// Health flags
+	healthInterval = flag.Duration("health-interval", DefaultEnv("HEALTH_INTERVAL", health.DefaultInterval), "how often to probe dependencies for health checks")
+
+	// Browser flags
+	grpcWebEnabled = flag.Bool("grpc-web", DefaultEnv("GRPC_WEB_ENABLED", true), "serve gRPC-Web and Connect protocol calls on the gateway")
+	corsOrigins    = flag.String("cors-origins", DefaultEnv("CORS_ORIGINS", ""), "comma separated origins allowed to call the gateway from browsers, * for any (empty = CORS disabled)")
+
+	// Reflection flags
+	reflectionEnabled = flag.Bool("reflection", DefaultEnv("REFLECTION_ENABLED", false), "register gRPC server reflection and serve descriptors on the gateway")
+	reflectionPublic  = flag.Bool("reflection-public", DefaultEnv("REFLECTION_PUBLIC", false), "allow reflection and descriptors without authentication when auth is enabled")
+
 	// Database flags
-	dbConnString = flag.String("db", DefaultEnv("DATABASE_URL", ""), "PostgreSQL connection string (empty = use in-memory storage)")
+	dbConnString     = flag.String("db", DefaultEnv("DATABASE_URL", ""), "PostgreSQL connection string (empty = use in-memory storage)")
+	dbMigrate        = flag.Bool("db-migrate", DefaultEnv("DB_MIGRATE", true), "apply pending schema migrations at startup (false = refuse to start while any are pending)")
+	validateStorage  = flag.Bool("validate-storage", DefaultEnv("VALIDATE_STORAGE", false), "debug: log users read from storage that break the proto validation rules")
+	deleteRetention  = flag.Duration("delete-retention", DefaultEnv("DELETE_RETENTION", 30*24*time.Hour), "how long deleted users can be undeleted before they're purged (0 = never purge)")
+	purgeInterval    = flag.Duration("purge-interval", DefaultEnv("PURGE_INTERVAL", time.Hour), "how often to purge deleted users past the retention window")
+	cacheSize        = flag.Int("cache-size", DefaultEnv("CACHE_SIZE", 0), "number of users to cache in front of storage (0 = no cache)")
+	cacheTTL         = flag.Duration("cache-ttl", DefaultEnv("CACHE_TTL", time.Minute), "how long cached users are kept")
+	idempotencyTTL   = flag.Duration("idempotency-ttl", DefaultEnv("IDEMPOTENCY_TTL", idempotency.DefaultTTL), "how long results of calls with an idempotency key are kept for retries (0 = ignore keys)")
+	idempotencyLease = flag.Duration("idempotency-lease", DefaultEnv("IDEMPOTENCY_LEASE", idempotency.DefaultLease), "how long a call with an idempotency key holds it before a retry may take over, in case its server died")
+
+	// Logging flags
+	logFormat     = flag.String("log-format", DefaultEnv("LOG_FORMAT", "text"), "log output format (text or json)")
+	logLevel      = flag.String("log-level", DefaultEnv("LOG_LEVEL", "info"), "default log level (debug, info, warn, error)")
+	logLevels     = flag.String("log-levels", DefaultEnv("LOG_LEVELS", ""), "per-package log levels, e.g. interceptors=debug,server=warn")
+	logRedactions = flag.String("log-redact", DefaultEnv("LOG_REDACT", ""), "extra comma separated field names or paths to redact from logs")
+
+	// Compression flags
+	zstdDicts          = flag.String("zstd-dicts", DefaultEnv("ZSTD_DICTS", ""), "directory of zstd dictionaries (*.dict) to load")
+	gatewayCompression = flag.Bool("gateway-compression", DefaultEnv("GATEWAY_COMPRESSION", false), "compress gateway to gRPC server traffic with zstd")
+
+	// Capture flags
+	captureDir        = flag.String("capture-dir", DefaultEnv("CAPTURE_DIR", ""), "record sampled RPCs as JSONL files in this directory (empty = disabled)")
+	captureSample     = flag.Float64("capture-sample", DefaultEnv("CAPTURE_SAMPLE", 1.0), "fraction of RPCs to capture, from 0 to 1")
+	captureMaxSize    = flag.Int("capture-max-size", DefaultEnv("CAPTURE_MAX_SIZE", 64), "capture file size in MB before rotating")
+	captureMaxFiles   = flag.Int("capture-max-files", DefaultEnv("CAPTURE_MAX_FILES", capture.DefaultMaxFiles), "number of capture files to keep")
+	captureUnredacted = flag.Bool("capture-unredacted", DefaultEnv("CAPTURE_UNREDACTED", false), "capture messages without redacting sensitive fields")
+	captureMaxCall    = flag.Int("capture-max-call", DefaultEnv("CAPTURE_MAX_CALL", capture.DefaultMaxRecordBytes>>10), "KB of messages captured per call; later ones are dropped and the record marked truncated")
 )
 
 // getJWTSecret returns the JWT secret key from environment variables
@@ -126,6 +196,21 @@ func DefaultEnv[T any](name string, def T) T {
 			} else {
 				ret = def
 			}
+		case time.Duration:
+			v, err := time.ParseDuration(val)
+			if err == nil {
+				ret = any(v).(T)
+			} else {
+				ret = def
+			}
+		case float64:
+			var v float64
+			_, err := fmt.Sscanf(val, "%g", &v)
+			if err == nil {
+				ret = any(v).(T)
+			} else {
+				ret = def
+			}
 		default:
 			log.Fatalf("unsupported env var type for %s - %T", name, def)
 		}
@@ -141,6 +226,59 @@ func init() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
 
+// redactor returns the redactor for logs and captures configured by the
+// log-redact flag
+func redactor() *logging.Redactor {
+	r := logging.DefaultRedactor()
+	if *logRedactions != "" {
+		r = r.With(strings.Split(*logRedactions, ",")...)
+	}
+	return r
+}
+
+// setupLogging installs the structured logger configured by the log flags
+func setupLogging() error {
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return err
+	}
+
+	packageLevels, err := logging.ParsePackageLevels(*logLevels)
+	if err != nil {
+		return err
+	}
+
+	logging.Setup(logging.Config{
+		Format:        logging.Format(*logFormat),
+		Level:         level,
+		PackageLevels: packageLevels,
+		AddSource:     level <= slog.LevelDebug,
+		Redactor:      redactor(),
+	})
+
+	// slog now timestamps and attributes lines from the standard log package
+	log.SetFlags(0)
+	return nil
+}
+
+// gatewayHeaderMatcher maps gRPC response headers to HTTP headers the way the
+// gateway does by default, except x-request-id which requestid.Middleware
+// already sets on the response
+func gatewayHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, requestid.Header) {
+		return "", false
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// gatewayTrailerMatcher is gatewayHeaderMatcher for gRPC trailers
+func gatewayTrailerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, requestid.Header) {
+		return "", false
+	}
+	return runtime.MetadataTrailerPrefix + key, true
+}
+
 // func XserverPProf(mux http.ServeMux, addr string) {
 // 	{
 // 		log.Printf("Starting pprof server on %s", addr)
@@ -196,6 +334,136 @@ func serverPProf(addr, prefix string) {
 	}
 }
 
+// inProcessBufferSize is the buffer of the in-memory gateway connection
+const inProcessBufferSize = 1 << 20
+
+// grpcOrHTTP sends gRPC requests (HTTP/2 with a gRPC content type) to the
+// gRPC server and everything else to the HTTP handler, so both share one
+// TLS listener. ALPN negotiates h2 for gRPC clients; browsers and curl may
+// use HTTP/1.1, which only the HTTP handler serves.
+func grpcOrHTTP(grpcServer *grpc.Server, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// originHosts converts CORS origins to the host patterns the WebSocket
+// origin check expects
+func originHosts(origins string) []string {
+	var hosts []string
+	for _, origin := range strings.Split(origins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			origin = u.Host
+		}
+		hosts = append(hosts, origin)
+	}
+	return hosts
+}
+
+// registerListStreams serves the user list routes with content negotiation.
+// SSE event IDs are user IDs; lists are ordered by ID so a reconnecting
+// client skips every user up to its Last-Event-ID.
+func registerListStreams(mux *http.ServeMux, gwmux *runtime.ServeMux, client pb.UserServiceClient) {
+	cfg := httpstream.Config{
+		EventID: func(msg proto.Message) string {
+			return strconv.FormatUint(uint64(msg.(*pb.User).GetId()), 10)
+		},
+		Delivered: func(msg proto.Message, lastEventID string) bool {
+			last, err := strconv.ParseUint(lastEventID, 10, 32)
+			return err == nil && uint64(msg.(*pb.User).GetId()) <= last
+		},
+	}
+
+	mux.Handle("GET /api/v1/users", httpstream.New(gwmux, "/proto.UserService/ListUsers", "/api/v1/users",
+		func(ctx context.Context, r *http.Request) (httpstream.RecvFunc, error) {
+			var req pb.ListUsersRequest
+			if err := runtime.PopulateQueryParameters(&req, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			stream, err := client.ListUsers(ctx, &req)
+			if err != nil {
+				return nil, err
+			}
+			return httpstream.Recv(stream), nil
+		}, cfg))
+
+	mux.Handle("GET /api/v1/users/role/{role}", httpstream.New(gwmux, "/proto.UserService/ListUsersByRole", "/api/v1/users/role/{role}",
+		func(ctx context.Context, r *http.Request) (httpstream.RecvFunc, error) {
+			role, err := runtime.Enum(r.PathValue("role"), pb.Role_value)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "type mismatch, parameter: %s, error: %v", "role", err)
+			}
+			// The path sets the role; the query sets the rest, like show_deleted
+			req := pb.UserRole{Role: pb.Role(role)}
+			if err := runtime.PopulateQueryParameters(&req, r.URL.Query(), utilities.NewDoubleArray([][]string{{"role"}})); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			stream, err := client.ListUsersByRole(ctx, &req)
+			if err != nil {
+				return nil, err
+			}
+			return httpstream.Recv(stream), nil
+		}, cfg))
+
+	// Audit events resume by sequence number
+	auditCfg := httpstream.Config{
+		EventID: func(msg proto.Message) string {
+			return strconv.FormatUint(msg.(*pb.AuditEvent).GetSequence(), 10)
+		},
+		Delivered: func(msg proto.Message, lastEventID string) bool {
+			last, err := strconv.ParseUint(lastEventID, 10, 64)
+			return err == nil && msg.(*pb.AuditEvent).GetSequence() <= last
+		},
+	}
+	mux.Handle("GET /api/v1/audit/events", httpstream.New(gwmux, "/proto.UserService/ListAuditEvents", "/api/v1/audit/events",
+		func(ctx context.Context, r *http.Request) (httpstream.RecvFunc, error) {
+			var req pb.ListAuditEventsRequest
+			if err := runtime.PopulateQueryParameters(&req, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			stream, err := client.ListAuditEvents(ctx, &req)
+			if err != nil {
+				return nil, err
+			}
+			return httpstream.Recv(stream), nil
+		}, auditCfg))
+}
+
+// auditActor names the caller in audit events by their JWT claims
+func auditActor(ctx context.Context) string {
+	claims := interceptors.GetClaimsFromContext(ctx)
+	if claims == nil {
+		return ""
+	}
+	if claims.Username != "" {
+		return claims.Username
+	}
+	return claims.UserID
+}
+
+// descriptorPath is where the gateway serves the API's FileDescriptorSet
+const descriptorPath = "/api/v1/descriptors"
+
+// bearerAuthorizer requires a valid JWT in the Authorization header
+func bearerAuthorizer(jwtMgr *auth.JWTManager) func(*http.Request) error {
+	return func(r *http.Request) error {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return errors.New("missing bearer token")
+		}
+		_, err := jwtMgr.ValidateToken(token)
+		return err
+	}
+}
+
 // serveOpenAPI serves an OpenAPI UI on /openapi-ui/
 func serveOpenAPI(mux *http.ServeMux) error {
 	if err := mime.AddExtensionType(".svg", "image/svg+xml"); err != nil {
@@ -217,6 +485,23 @@ func serveOpenAPI(mux *http.ServeMux) error {
 func main() {
 	flag.Parse()
 
+	if err := setupLogging(); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// grpc-example migrate ... manages the database schema and exits
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(context.Background(), flag.Args()[1:])
+		if errors.Is(err, errMigrateUsage) {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		if err != nil {
+			log.Fatalf("Migrate failed: %v", err)
+		}
+		return
+	}
+
 	if *validateToken != "" {
 		secretKey := secretKey
 		jwtMgr := interceptors.NewJWTManager(secretKey, time.Hour*24, jwtIssuer)
@@ -224,7 +509,8 @@ func main() {
 		if err != nil {
 			log.Fatalf("Token validation failed: %v", err)
 		}
-		log.Printf("Token is valid. Claims: Username=%s, Roles=%v, IssuedAt=%v, ExpiresAt=%v",
+		// This is an interactive check of the caller's own token, so print the claims in full
+		fmt.Printf("Token is valid. Claims: Username=%s, Roles=%v, IssuedAt=%v, ExpiresAt=%v\n",
 			claims.Username, claims.Roles, claims.IssuedAt, claims.ExpiresAt)
 		return
 	}
@@ -263,16 +549,19 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Initialize OpenTelemetry
+	// Initialize OpenTelemetry; the Prometheus exporter uses the same
+	// instruments, so it is set up even when OTLP export is off
+	otelMetrics := *otelEnabled || *prometheusEnabled
 	var otelShutdown otel.Shutdown
-	if *otelEnabled {
+	if otelMetrics {
 		var err error
 		otelShutdown, err = otel.Setup(ctx, otel.Config{
 			ServiceName:    *serviceName,
 			ServiceVersion: "1.0.0", // TODO: get from build info
 			Environment:    *environment,
 			OTLPEndpoint:   *otelEndpoint,
-			Enabled:        true,
+			Enabled:        *otelEnabled,
+			Prometheus:     *prometheusEnabled,
 		})
 		if err != nil {
 			log.Fatalf("Failed to initialize OpenTelemetry: %v", err)
@@ -291,26 +580,64 @@ func main() {
 		}
 	}
 
-	// Create gRPC server with interceptors
+	// Create gRPC server with interceptors. In single-port mode gRPC shares
+	// the gateway's TLS listener and the gateway reaches it through an
+	// in-memory connection, so there is no gRPC port.
 	addr := fmt.Sprintf("%s:%d", *hostname, *gRPCPort)
-	lis, err := net.Listen("tcp", addr)
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+	var lis net.Listener
+	if *singlePort {
+		lis = bufconn.Listen(inProcessBufferSize)
+	} else {
+		lis, err = net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+	}
+
+	interceptors.SlowStreamMessageThreshold = *slowMessage
+
+	// Load zstd dictionaries before any connection is made
+	if *zstdDicts != "" {
+		ids, err := compression.LoadDictionaries(*zstdDicts)
+		if err != nil {
+			log.Fatalf("Failed to load zstd dictionaries: %v", err)
+		}
+		log.Printf("Loaded zstd dictionaries %v from %s", ids, *zstdDicts)
+	}
+
+	// Initialize storage backend
+	var storage server.Storage
+	if *dbConnString != "" {
+		var err error
+		var opts []server.PostgresOption
+		if !*dbMigrate {
+			opts = append(opts, server.WithoutMigrations())
+		}
+		storage, err = server.NewPostgresStorage(ctx, *dbConnString, opts...)
+		if err != nil {
+			log.Fatalf("Failed to initialize PostgreSQL storage: %v", err)
+		}
+		log.Println("PostgreSQL storage initialized successfully")
+		defer storage.(*server.PostgresStorage).Close()
+	} else {
+		storage = server.NewMemoryStorage()
+		log.Println("In-memory storage initialized")
 	}
 
 	// Build interceptor chain
 	var unaryInterceptors []grpc.UnaryServerInterceptor
 	var streamInterceptors []grpc.StreamServerInterceptor
 
+	// Request IDs come first so every later interceptor can log and trace them
+	unaryInterceptors = append(unaryInterceptors, interceptors.RequestIDUnaryInterceptor())
+	streamInterceptors = append(streamInterceptors, interceptors.RequestIDStreamInterceptor())
+
 	// Add OpenTelemetry or standard interceptors based on configuration
 	if *otelEnabled {
 		// Use OpenTelemetry-enhanced interceptors
 		unaryInterceptors = append(unaryInterceptors, interceptors.OtelLoggingUnaryInterceptor())
 		streamInterceptors = append(streamInterceptors, interceptors.OtelLoggingStreamInterceptor())
 
-		unaryInterceptors = append(unaryInterceptors, interceptors.OtelMetricsUnaryInterceptor())
-		streamInterceptors = append(streamInterceptors, interceptors.OtelMetricsStreamInterceptor())
-
 		// Note: otelgrpc interceptors are not needed as we have custom Otel interceptors
 		// that provide more detailed instrumentation
 	} else {
@@ -322,9 +649,16 @@ func main() {
 		streamInterceptors = append(streamInterceptors, interceptors.MetricsStreamInterceptor())
 	}
 
+	// grpc.server.* instruments feed both the OTLP and Prometheus exporters
+	if otelMetrics {
+		unaryInterceptors = append(unaryInterceptors, interceptors.OtelMetricsUnaryInterceptor())
+		streamInterceptors = append(streamInterceptors, interceptors.OtelMetricsStreamInterceptor())
+	}
+
 	// Optionally add auth
+	var jwtMgr *auth.JWTManager
 	if *enableAuth {
-		jwtMgr := interceptors.NewJWTManager(secretKey, time.Hour*24, jwtIssuer)
+		jwtMgr = interceptors.NewJWTManager(secretKey, time.Hour*24, jwtIssuer)
 		var approver interceptors.FakeClaimsApprover     // TODO: replace with real RBAC approver
 		jm := interceptors.NewApprover(jwtMgr, approver) //auth.MyApprover{jwtManager: jwtMgr}
 		unaryInterceptors = append(unaryInterceptors, interceptors.JWTAuthUnaryInterceptor(jm))
@@ -333,35 +667,134 @@ func main() {
 		log.Println("Authentication interceptor enabled - using JWT tokens for Bear")
 	}
 
+	// Advertise zstd dictionaries and use them for responses when shared
+	unaryInterceptors = append(unaryInterceptors, interceptors.CompressionUnaryInterceptor())
+	streamInterceptors = append(streamInterceptors, interceptors.CompressionStreamInterceptor())
+
+	// Optionally capture traffic for replay; runs after auth so only
+	// authorized calls are recorded
+	if *captureDir != "" {
+		cw, err := capture.NewWriter(*captureDir, int64(*captureMaxSize)<<20, *captureMaxFiles)
+		if err != nil {
+			log.Fatalf("Failed to set up capture: %v", err)
+		}
+		defer func() {
+			if err := cw.Close(); err != nil {
+				log.Printf("Error closing capture file: %v", err)
+			}
+		}()
+		captureCfg := interceptors.CaptureConfig{
+			SampleRate:     *captureSample,
+			MaxRecordBytes: *captureMaxCall << 10,
+			Redactor:       redactor(),
+			Unredacted:     *captureUnredacted,
+		}
+		unaryInterceptors = append(unaryInterceptors, interceptors.CaptureUnaryInterceptor(cw, captureCfg))
+		streamInterceptors = append(streamInterceptors, interceptors.CaptureStreamInterceptor(cw, captureCfg))
+		log.Printf("Capturing %.0f%% of RPCs to %s", *captureSample*100, *captureDir)
+		if *captureUnredacted {
+			log.Printf("WARNING: captures include sensitive fields unredacted")
+		}
+	}
+
+	// Retries carrying an idempotency key get the first call's result; keys
+	// are scoped to the caller, so this runs after auth
+	if *idempotencyTTL > 0 {
+		var store idempotency.Store = idempotency.NewMemoryStore(*idempotencyTTL, *idempotencyLease)
+		if pg, ok := storage.(*server.PostgresStorage); ok {
+			store = idempotency.NewPostgresStore(pg.Pool(), *idempotencyTTL, *idempotencyLease)
+		}
+		unaryInterceptors = append(unaryInterceptors, interceptors.IdempotencyUnaryInterceptor(store, server.BatchModeHeader))
+		streamInterceptors = append(streamInterceptors, interceptors.IdempotencyStreamInterceptor(store, server.BatchModeHeader))
+	}
+
+	// Enforce the proto validation rules last, right before the handlers,
+	// so captures record invalid requests too
+	unaryInterceptors = append(unaryInterceptors, interceptors.ValidationUnaryInterceptor())
+	streamInterceptors = append(streamInterceptors, interceptors.ValidationStreamInterceptor())
+
 	// Chain interceptors
 	opts := []grpc.ServerOption{
-		grpc.Creds(credentials.NewServerTLSFromCert(tlsCert)),
 		grpc.ChainUnaryInterceptor(unaryInterceptors...),
 		grpc.ChainStreamInterceptor(streamInterceptors...),
 	}
+	if !*singlePort {
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(tlsCert)))
+	}
 
 	grpcServer := grpc.NewServer(opts...)
 
-	// Initialize storage backend
-	var storage server.Storage
-	if *dbConnString != "" {
-		var err error
-		storage, err = server.NewPostgresStorage(ctx, *dbConnString)
+	// Changes to users are audited next to the users themselves
+	var auditSink audit.Sink = audit.NewMemorySink()
+	if pg, ok := storage.(*server.PostgresStorage); ok {
+		auditSink = audit.NewPostgresSink(pg.Pool())
+	}
+	auditLog := audit.New(auditSink, audit.Config{Actor: auditActor})
+
+	// Register the UserService with configured storage
+	serviceStorage := storage
+	if *cacheSize > 0 {
+		cache, err := server.NewCachingStorage(storage, *cacheSize, *cacheTTL)
 		if err != nil {
-			log.Fatalf("Failed to initialize PostgreSQL storage: %v", err)
+			log.Fatalf("Failed to create user cache: %v", err)
 		}
-		log.Println("PostgreSQL storage initialized successfully")
-		defer storage.(*server.PostgresStorage).Close()
-	} else {
-		storage = server.NewMemoryStorage()
-		log.Println("In-memory storage initialized")
+		// Other servers sharing the database report the users they change
+		if pg, ok := storage.(*server.PostgresStorage); ok {
+			go cache.Watch(ctx, pg)
+		}
+		serviceStorage = cache
+		log.Printf("Caching up to %d users for %v", *cacheSize, *cacheTTL)
+	}
+	if *validateStorage {
+		serviceStorage = server.NewValidatingStorage(serviceStorage)
+		log.Println("Validating users read from storage")
 	}
+	pb.RegisterUserServiceServer(grpcServer, server.New(serviceStorage, server.WithAuditLog(auditLog)))
 
-	// Register the UserService with configured storage
-	pb.RegisterUserServiceServer(grpcServer, server.New(storage))
+	// Deleted users stay undeletable until the purger removes them; it
+	// purges through the cache, so purged users are dropped from it
+	if *deleteRetention > 0 {
+		purger, err := server.NewPurger(serviceStorage, auditLog, *deleteRetention, *purgeInterval)
+		if err != nil {
+			log.Fatalf("Failed to create purger: %v", err)
+		}
+		go purger.Run(ctx)
+		log.Printf("Purging users deleted more than %v ago, every %v", *deleteRetention, *purgeInterval)
+	}
+
+	// Health checks: the UserService needs its storage, telemetry export is
+	// reported separately and doesn't make the server unready
+	probes := []health.Probe{{Name: "storage", Check: func(context.Context) error { return nil }}}
+	if pg, ok := storage.(*server.PostgresStorage); ok {
+		probes[0].Check = pg.Ping
+	}
+	if *otelEnabled {
+		probes = append(probes, health.Probe{Name: "otel", Check: otel.ExporterHealth, Optional: true})
+	}
+	healthMonitor := health.NewMonitor(*healthInterval, probes...)
+	healthMonitor.Watch(pb.UserService_ServiceDesc.ServiceName, "storage")
+	if *otelEnabled {
+		healthMonitor.Watch("telemetry", "otel")
+	}
+	healthpb.RegisterHealthServer(grpcServer, healthMonitor.Server())
+	healthMonitor.Start(ctx)
+
+	// Reflection lets grpcurl and other dynamic clients discover the API
+	if *reflectionEnabled {
+		reflection.Register(grpcServer)
+		if *reflectionPublic {
+			interceptors.AllowUnauthenticated(
+				"/grpc.reflection.v1.ServerReflection/",
+				"/grpc.reflection.v1alpha.ServerReflection/",
+			)
+		}
+		log.Printf("gRPC reflection enabled (public: %v)", *reflectionPublic || !*enableAuth)
+	}
 
 	// Serve gRPC Server in background
-	log.Printf("Serving gRPC on https://%s", addr)
+	if !*singlePort {
+		log.Printf("Serving gRPC on https://%s", addr)
+	}
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
 			log.Fatalf("Failed to serve gRPC: %v", err)
@@ -369,19 +802,34 @@ func main() {
 	}()
 
 	// Setup gRPC-Gateway
-	// Gateway connects to gRPC server on localhost (same container)
-	// Use localhost instead of hostname because:
-	// 1. They're in the same process/container
-	// 2. Certificate has localhost in SANs but not 0.0.0.0
-	grpcDialHost := "localhost"
-	if *hostname != "" && *hostname != "0.0.0.0" {
-		grpcDialHost = *hostname
-	}
-	dialAddr := fmt.Sprintf("%s:%d", grpcDialHost, *gRPCPort)
-
+	var dialAddr string
 	var dialOpts []grpc.DialOption
-	// Use the cert pool from loaded credentials (or embedded if fallback occurred)
-	dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(certPool, "")))
+	if bufLis, ok := lis.(*bufconn.Listener); ok {
+		// In-process: the connection never leaves memory, so it needs no TLS
+		dialAddr = "passthrough:///in-process"
+		dialOpts = append(dialOpts,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return bufLis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(grpcinsecure.NewCredentials()),
+		)
+	} else {
+		// Gateway connects to gRPC server on localhost (same container)
+		// Use localhost instead of hostname because:
+		// 1. They're in the same process/container
+		// 2. Certificate has localhost in SANs but not 0.0.0.0
+		grpcDialHost := "localhost"
+		if *hostname != "" && *hostname != "0.0.0.0" {
+			grpcDialHost = *hostname
+		}
+		dialAddr = fmt.Sprintf("%s:%d", grpcDialHost, *gRPCPort)
+
+		// Use the cert pool from loaded credentials (or embedded if fallback occurred)
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(certPool, "")))
+	}
+	if *gatewayCompression {
+		dialOpts = append(dialOpts, compression.DialOptions()...)
+	}
 
 	conn, err := grpc.NewClient(dialAddr, dialOpts...)
 	if err != nil {
@@ -391,7 +839,23 @@ func main() {
 	defer conn.Close()
 
 	mux := http.NewServeMux()
-	gwmux := runtime.NewServeMux()
+	gwmux := runtime.NewServeMux(
+		// Forward the request ID assigned by requestid.Middleware, and any
+		// idempotency key, to the gRPC server
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			md := metadata.Pairs(requestid.Header, requestid.FromContext(ctx))
+			if key := r.Header.Get(idempotency.HTTPHeader); key != "" {
+				md.Set(idempotency.Header, key)
+			}
+			return md
+		}),
+		// The middleware already sets X-Request-Id, don't echo it a second time
+		// as Grpc-Metadata-X-Request-Id
+		runtime.WithOutgoingHeaderMatcher(gatewayHeaderMatcher),
+		runtime.WithOutgoingTrailerMatcher(gatewayTrailerMatcher),
+		// Errors are RFC 7807 application/problem+json
+		runtime.WithErrorHandler(problem.ErrorHandler(problem.Config{HeaderMatcher: gatewayHeaderMatcher})),
+	)
 
 	err = pb.RegisterUserServiceHandler(ctx, gwmux, conn)
 	if err != nil {
@@ -399,6 +863,47 @@ func main() {
 	}
 
 	mux.Handle("/", gwmux)
+	// The list streams negotiate JSON array, NDJSON or SSE responses; these
+	// routes take precedence over the gateway's
+	registerListStreams(mux, gwmux, pb.NewUserServiceClient(conn))
+	// WebSocket bridges for the bidirectional streams REST can't express
+	wsConfig := wsbridge.Config{OriginPatterns: originHosts(*corsOrigins)}
+	if jwtMgr != nil {
+		wsConfig.Authorize = func(token string) error {
+			_, err := jwtMgr.ValidateToken(token)
+			return err
+		}
+	}
+	for path, method := range map[string]string{
+		"/api/v1/activity/ws":   "/proto.UserService/UserActivityStream",
+		"/api/v1/users/sync/ws": "/proto.UserService/SyncUsers",
+	} {
+		bridge, err := wsbridge.New(conn, method, wsConfig)
+		if err != nil {
+			log.Fatalf("Failed to create WebSocket bridge: %v", err)
+		}
+		mux.Handle(path, bridge)
+	}
+
+	mux.Handle("/healthz", healthMonitor.LiveHandler())
+	mux.Handle("/readyz", healthMonitor.ReadyHandler())
+
+	// Serve the same schema reflection exposes, for clients that speak HTTP
+	if *reflectionEnabled {
+		var authorize func(*http.Request) error
+		if jwtMgr != nil && !*reflectionPublic {
+			authorize = bearerAuthorizer(jwtMgr)
+		}
+		descriptors, err := schema.Handler(schema.FileDescriptorSet(pb.File_example_proto), authorize)
+		if err != nil {
+			log.Fatalf("Failed to serve descriptors: %v", err)
+		}
+		mux.Handle(descriptorPath, descriptors)
+	}
+
+	if *prometheusEnabled {
+		mux.Handle(*metricsPath, otel.PrometheusHandler())
+	}
 
 	// Try to serve OpenAPI UI if files exist
 	if err := serveOpenAPI(mux); err != nil {
@@ -406,8 +911,17 @@ func main() {
 	}
 
 	gatewayAddr := fmt.Sprintf("%s:%d", *hostname, *gatewayPort)
+	if *singlePort {
+		log.Printf("Serving gRPC on https://%s", gatewayAddr)
+	}
 	log.Printf("Serving gRPC-Gateway on https://%s", gatewayAddr)
 	log.Printf("Serving OpenAPI Documentation on https://%s/openapi-ui/", gatewayAddr)
+	if *prometheusEnabled {
+		log.Printf("Serving Prometheus metrics on https://%s%s", gatewayAddr, *metricsPath)
+	}
+	if *reflectionEnabled {
+		log.Printf("Serving API descriptors on https://%s%s", gatewayAddr, descriptorPath)
+	}
 
 	// Update TLS config for gateway with InsecureSkipVerify if needed
 	gatewayTLSConfig := tlsConfig
@@ -419,12 +933,33 @@ func main() {
 		}
 	}
 
-	// Wrap HTTP handler with OpenTelemetry instrumentation if enabled
+	// gRPC-Web and Connect calls go straight to the gRPC server in-process,
+	// everything else to the gateway mux
 	var httpHandler http.Handler = mux
+	if *grpcWebEnabled {
+		httpHandler = webrpc.New(grpcServer).Wrap(httpHandler)
+		log.Printf("Serving gRPC-Web and Connect on https://%s", gatewayAddr)
+	}
+
+	// Wrap HTTP handler with OpenTelemetry instrumentation if enabled.
+	// The request ID middleware runs inside the OTel handler so it can tag its span.
+	httpHandler = requestid.Middleware(httpHandler)
 	if *otelEnabled {
-		httpHandler = otel.WrapMux(mux, *serviceName+"-gateway")
+		httpHandler = otel.WrapHandler(httpHandler, *serviceName+"-gateway")
 		log.Println("HTTP Gateway instrumented with OpenTelemetry")
 	}
+	if *corsOrigins != "" {
+		origins := strings.Split(*corsOrigins, ",")
+		for i := range origins {
+			origins[i] = strings.TrimSpace(origins[i])
+		}
+		httpHandler = webrpc.CORS(webrpc.CORSConfig{AllowedOrigins: origins}, httpHandler)
+		log.Printf("CORS enabled for origins: %s", *corsOrigins)
+	}
+	if *singlePort {
+		// gRPC has its own interceptors, route it before the HTTP middleware
+		httpHandler = grpcOrHTTP(grpcServer, httpHandler)
+	}
 
 	gwServer := &http.Server{
 		Addr:      gatewayAddr,
@@ -461,6 +996,9 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	// Report NOT_SERVING so load balancers stop sending new requests
+	healthMonitor.Shutdown()
+
 	// Shutdown HTTP gateway
 	if err := gwServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP gateway shutdown error: %v", err)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/paulstuart/grpc-example/server"
+)
+
+const migrateUsage = `usage: grpc-example -db <url> migrate <command>
+
+commands:
+  status        list applied and pending migrations
+  up            apply every pending migration
+  down [n]      undo the last n migrations (default 1)
+  to <version>  apply or undo migrations until version is the latest applied`
+
+// errMigrateUsage means the migrate arguments were wrong
+var errMigrateUsage = errors.New("invalid migrate command")
+
+// runMigrate runs the migrate subcommand against the -db database
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+	if *dbConnString == "" {
+		return errors.New("migrate needs a database, set -db or DATABASE_URL")
+	}
+
+	pool, err := pgxpool.New(ctx, *dbConnString)
+	if err != nil {
+		return fmt.Errorf("unable to create connection pool: %w", err)
+	}
+	defer pool.Close()
+
+	migrator, err := server.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "status" && len(args) == 0:
+		applied, pending, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, a := range applied {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05Z07:00"))
+		}
+		for _, p := range pending {
+			fmt.Fprintf(w, "%d\t%s\tpending\n", p.Version, p.Name)
+		}
+		return w.Flush()
+	case cmd == "up" && len(args) == 0:
+		return migrator.Up(ctx)
+	case cmd == "down" && len(args) <= 1:
+		steps := 1
+		if len(args) == 1 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case cmd == "to" && len(args) == 1:
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return migrator.To(ctx, version)
+	default:
+		return errMigrateUsage
+	}
+}
@@ -0,0 +1,313 @@
+// Package migrate applies versioned SQL migrations to a PostgreSQL database.
+//
+// Migrations are files named NNNN_name.up.sql, each with an optional
+// NNNN_name.down.sql that undoes it. Applied versions are recorded in the
+// schema_migrations table. Every migration runs in its own transaction while
+// the migrator holds an advisory lock, so servers starting at the same time
+// don't apply the same migration twice.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/paulstuart/grpc-example/logging"
+)
+
+// lockKey is the advisory lock key held while migrating
+const lockKey = 0x6d696772 // "migr"
+
+// ErrUnknownVersion is returned when the database has a schema version
+// newer than any migration known to this binary
+var ErrUnknownVersion = errors.New("database schema is newer than this server")
+
+// fileName matches migration files, capturing version, name and direction
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema change and the SQL to undo it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down is empty for migrations that can't be undone
+	Down string
+}
+
+// Applied is a migration recorded in schema_migrations
+type Applied struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Load reads migrations from the files in fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// New creates a migrator for the migrations, as returned by Load
+func New(pool *pgxpool.Pool, migrations []Migration) *Migrator {
+	return &Migrator{pool: pool, migrations: migrations}
+}
+
+// Latest returns the highest known version, or 0 if there are none
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns the applied migrations, oldest first, and the known
+// migrations not applied yet
+func (m *Migrator) Status(ctx context.Context) (applied []Applied, pending []Migration, err error) {
+	applied, err = listApplied(ctx, m.pool)
+	if err != nil {
+		return nil, nil, err
+	}
+	done := make(map[int64]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+	for _, mig := range m.migrations {
+		if !done[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return applied, pending, nil
+}
+
+// Check fails with ErrUnknownVersion if the database has a migration this
+// binary doesn't know, and returns the number of pending migrations
+func (m *Migrator) Check(ctx context.Context) (int, error) {
+	applied, pending, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down undoes the last steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied []Applied) error {
+		target := int64(0)
+		if steps < len(applied) {
+			target = applied[len(applied)-1-steps].Version
+		}
+		return m.migrate(ctx, conn, applied, target)
+	})
+}
+
+// To applies or undoes migrations until version is the latest applied one
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied []Applied) error {
+		return m.migrate(ctx, conn, applied, version)
+	})
+}
+
+// locked runs fn holding the migration lock on one connection, with the
+// migrations applied so far
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn, applied []Applied) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer func() {
+		// A new context, so the lock is released even if ctx is done
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			logging.For("migrate").Error("failed to release migration lock", "error", err)
+		}
+	}()
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := listApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+// migrate undoes applied migrations newer than target, newest first, then
+// applies pending ones up to target, oldest first
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, applied []Applied, target int64) error {
+	logger := logging.For("migrate")
+	done := make(map[int64]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version <= target || !done[mig.Version] {
+			continue
+		}
+		if mig.Down == "" {
+			return fmt.Errorf("migration %d_%s can't be undone", mig.Version, mig.Name)
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to undo migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		logger.InfoContext(ctx, "undid migration", "version", mig.Version, "name", mig.Name)
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version > target || done[mig.Version] {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		logger.InfoContext(ctx, "applied migration", "version", mig.Version, "name", mig.Name)
+	}
+	return nil
+}
+
+// checkKnown fails if a migration was applied that this binary doesn't have
+func (m *Migrator) checkKnown(applied []Applied) error {
+	for _, a := range applied {
+		if !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == a.Version }) {
+			return fmt.Errorf("%w: migration %d_%s is applied but unknown (latest known is %d)",
+				ErrUnknownVersion, a.Version, a.Name, m.Latest())
+		}
+	}
+	return nil
+}
+
+// querier is the pool or one of its connections
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// createTable creates the schema_migrations table if needed
+func (m *Migrator) createTable(ctx context.Context, q querier) error {
+	_, err := q.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// listApplied returns the applied migrations, oldest first; none if
+// schema_migrations doesn't exist yet
+func listApplied(ctx context.Context, q querier) ([]Applied, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to find schema_migrations: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := q.Query(ctx, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Applied])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	return applied, nil
+}
@@ -0,0 +1,41 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_email.up.sql":     {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"0002_add_email.down.sql":   {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+		"0001_create_users.up.sql":  {Data: []byte("CREATE TABLE users (id INT);")},
+		"0010_backfill.up.sql":      {Data: []byte("UPDATE users SET email = '';")},
+		"README.md":                 {Data: []byte("not a migration")},
+		"0003_orphan.down.sql.orig": {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN email;", migrations[1].Down)
+	assert.Equal(t, int64(10), migrations[2].Version)
+
+	m := New(nil, migrations)
+	assert.Equal(t, int64(10), m.Latest())
+	assert.ErrorIs(t, m.checkKnown([]Applied{{Version: 1}, {Version: 11, Name: "future"}}), ErrUnknownVersion)
+	assert.NoError(t, m.checkKnown([]Applied{{Version: 1}, {Version: 2}}))
+
+	_, err = Load(fstest.MapFS{"0001_drop.down.sql": {Data: []byte("DROP TABLE users;")}})
+	assert.ErrorContains(t, err, "has no up file")
+
+	_, err = Load(fstest.MapFS{
+		"0001_one.up.sql": {Data: []byte("SELECT 1;")},
+		"0001_two.up.sql": {Data: []byte("SELECT 2;")},
+	})
+	assert.ErrorContains(t, err, "two names")
+}
@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSchema is where the tests migrate, so they leave the rest of the
+// database alone
+const testSchema = "migrate_test"
+
+// testPool connects to TEST_DATABASE_URL with an empty testSchema first on
+// the search path, dropped when the test ends
+func testPool(t *testing.T) *pgxpool.Pool {
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, connString)
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	_, err = admin.Exec(ctx, `DROP SCHEMA IF EXISTS `+testSchema+` CASCADE; CREATE SCHEMA `+testSchema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), `DROP SCHEMA IF EXISTS `+testSchema+` CASCADE`); err != nil {
+			t.Error(err)
+		}
+	})
+
+	cfg, err := pgxpool.ParseConfig(connString)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = testSchema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func tableExists(t *testing.T, pool *pgxpool.Pool, table string) bool {
+	t.Helper()
+	var exists bool
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists))
+	return exists
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_a", Up: `CREATE TABLE a (id INT)`, Down: `DROP TABLE a`},
+	{Version: 2, Name: "create_b", Up: `CREATE TABLE b (id INT)`, Down: `DROP TABLE b`},
+	{Version: 3, Name: "seed_a", Up: `INSERT INTO a VALUES (1)`},
+}
+
+func TestMigratorPostgres(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+
+	m := New(pool, testMigrations[:2])
+	pending, err := m.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pending, "nothing applied before schema_migrations exists")
+
+	require.NoError(t, m.Up(ctx))
+	pending, err = m.Check(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+	assert.True(t, tableExists(t, pool, "a"))
+	assert.True(t, tableExists(t, pool, "b"))
+
+	require.NoError(t, m.Down(ctx, 1))
+	assert.True(t, tableExists(t, pool, "a"))
+	assert.False(t, tableExists(t, pool, "b"))
+	applied, unapplied, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, "create_a", applied[0].Name)
+	require.Len(t, unapplied, 1)
+	assert.Equal(t, int64(2), unapplied[0].Version)
+
+	require.NoError(t, m.To(ctx, 2))
+	assert.True(t, tableExists(t, pool, "b"))
+	require.NoError(t, m.To(ctx, 0))
+	assert.False(t, tableExists(t, pool, "a"))
+	assert.False(t, tableExists(t, pool, "b"))
+	assert.ErrorContains(t, m.To(ctx, 9), "unknown migration version")
+
+	// Migrations without a down file can't be undone
+	all := New(pool, testMigrations)
+	require.NoError(t, all.Up(ctx))
+	assert.ErrorContains(t, all.Down(ctx, 1), "can't be undone")
+
+	// A failed migration is rolled back and stays pending
+	broken := New(pool, append(testMigrations[:3:3], Migration{
+		Version: 4, Name: "broken", Up: `CREATE TABLE c (id INT); SELECT missing FROM a`,
+	}))
+	assert.ErrorContains(t, broken.Up(ctx), "4_broken")
+	assert.False(t, tableExists(t, pool, "c"))
+	pending, err = broken.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending)
+
+	// A binary older than the schema refuses to touch it
+	_, err = m.Check(ctx)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	assert.ErrorIs(t, m.Up(ctx), ErrUnknownVersion)
+	assert.True(t, tableExists(t, pool, "b"), "nothing undone")
+}
+
+func TestMigratorPostgresLock(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+
+	// Servers starting together apply each migration once; without the
+	// lock the second CREATE TABLE of a table would fail
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = New(pool, testMigrations[:2]).Up(ctx)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	applied, _, err := New(pool, testMigrations[:2]).Status(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+
+	// Migrating waits while another migrator holds the lock
+	conn, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+	defer conn.Release()
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey)
+	require.NoError(t, err)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	assert.Error(t, New(pool, testMigrations).Up(waitCtx))
+	var rows int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM a`).Scan(&rows))
+	assert.Zero(t, rows, "seed_a not applied")
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+	require.NoError(t, err)
+	require.NoError(t, New(pool, testMigrations).Up(ctx))
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM a`).Scan(&rows))
+	assert.Equal(t, 1, rows)
+}
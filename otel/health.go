@@ -0,0 +1,77 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportState remembers the outcome of the latest export of one signal
+type exportState struct {
+	mu      sync.Mutex
+	signal  string
+	lastErr error
+	at      time.Time
+}
+
+func (s *exportState) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	s.at = time.Now()
+}
+
+func (s *exportState) check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastErr != nil {
+		return fmt.Errorf("%s export failed at %s: %w", s.signal, s.at.Format(time.RFC3339), s.lastErr)
+	}
+	return nil
+}
+
+var (
+	traceExportState  = &exportState{signal: "trace"}
+	metricExportState = &exportState{signal: "metric"}
+)
+
+// ExporterHealth reports whether the most recent OTLP trace and metric
+// exports succeeded. It returns nil before the first export and when
+// OpenTelemetry is disabled.
+func ExporterHealth(context.Context) error {
+	if err := traceExportState.check(); err != nil {
+		return err
+	}
+	return metricExportState.check()
+}
+
+// trackedSpanExporter records the outcome of every span export
+type trackedSpanExporter struct {
+	sdktrace.SpanExporter
+	state *exportState
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *trackedSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.state.record(err)
+	return err
+}
+
+// trackedMetricExporter records the outcome of every metric export
+type trackedMetricExporter struct {
+	sdkmetric.Exporter
+	state *exportState
+}
+
+// Export implements sdkmetric.Exporter
+func (e *trackedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	e.state.record(err)
+	return err
+}
@@ -0,0 +1,54 @@
+package otel
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+var (
+	promMu      sync.RWMutex
+	promHandler http.Handler
+)
+
+// setupPrometheusReader creates a reader that serves the meter provider's
+// instruments to PrometheusHandler. A private registry is used so only
+// this service's metrics are exposed, not whatever else registered globally.
+func setupPrometheusReader() (sdkmetric.Reader, error) {
+	registry := prometheus.NewRegistry()
+	// Process metrics (CPU, RSS, open fds) aren't covered by OTel runtime metrics
+	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+
+	promMu.Lock()
+	promHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	promMu.Unlock()
+
+	return exporter, nil
+}
+
+// PrometheusHandler serves metrics in the Prometheus exposition format.
+// It responds 404 unless Setup was called with Prometheus enabled.
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promMu.RLock()
+		handler := promHandler
+		promMu.RUnlock()
+
+		if handler == nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
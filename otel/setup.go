@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -24,7 +25,11 @@ type Config struct {
 	ServiceVersion string
 	Environment    string
 	OTLPEndpoint   string
-	Enabled        bool
+	// Enabled turns on tracing and the OTLP metric exporter
+	Enabled bool
+	// Prometheus exposes metrics for scraping via PrometheusHandler,
+	// with or without Enabled
+	Prometheus bool
 }
 
 // Shutdown is a function that shuts down the OpenTelemetry providers
@@ -32,7 +37,7 @@ type Shutdown func(context.Context) error
 
 // Setup initializes OpenTelemetry with tracing and metrics
 func Setup(ctx context.Context, config Config) (Shutdown, error) {
-	if !config.Enabled {
+	if !config.Enabled && !config.Prometheus {
 		log.Println("OpenTelemetry is disabled")
 		return func(context.Context) error { return nil }, nil
 	}
@@ -50,21 +55,26 @@ func Setup(ctx context.Context, config Config) (Shutdown, error) {
 	}
 
 	// Setup trace provider
-	traceShutdown, err := setupTraceProvider(ctx, res, config.OTLPEndpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to setup trace provider: %w", err)
+	traceShutdown := Shutdown(func(context.Context) error { return nil })
+	if config.Enabled {
+		traceShutdown, err = setupTraceProvider(ctx, res, config.OTLPEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup trace provider: %w", err)
+		}
 	}
 
 	// Setup metric provider
-	metricShutdown, err := setupMetricProvider(ctx, res, config.OTLPEndpoint)
+	metricShutdown, err := setupMetricProvider(ctx, res, config)
 	if err != nil {
 		// Try to shutdown trace provider if metric setup fails
 		_ = traceShutdown(ctx)
 		return nil, fmt.Errorf("failed to setup metric provider: %w", err)
 	}
 
-	log.Printf("OpenTelemetry initialized: service=%s, version=%s, endpoint=%s",
-		config.ServiceName, config.ServiceVersion, config.OTLPEndpoint)
+	if config.Enabled {
+		log.Printf("OpenTelemetry initialized: service=%s, version=%s, endpoint=%s",
+			config.ServiceName, config.ServiceVersion, config.OTLPEndpoint)
+	}
 
 	// Return combined shutdown function
 	shutdown := func(ctx context.Context) error {
@@ -99,7 +109,7 @@ func setupTraceProvider(ctx context.Context, res *resource.Resource, endpoint st
 	// Create trace provider with batch span processor
 	provider := sdktrace.NewTracerProvider(
 		sdktrace.WithResource(res),
-		sdktrace.WithBatcher(exporter,
+		sdktrace.WithBatcher(&trackedSpanExporter{SpanExporter: exporter, state: traceExportState},
 			sdktrace.WithBatchTimeout(5*time.Second),
 			sdktrace.WithMaxExportBatchSize(512),
 		),
@@ -121,30 +131,46 @@ func setupTraceProvider(ctx context.Context, res *resource.Resource, endpoint st
 	return provider.Shutdown, nil
 }
 
-// setupMetricProvider creates and registers a metric provider
-func setupMetricProvider(ctx context.Context, res *resource.Resource, endpoint string) (Shutdown, error) {
-	// Create OTLP metric exporter
-	exporter, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithEndpoint(endpoint),
-		otlpmetricgrpc.WithInsecure(), // Use TLS in production
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
-	}
-
-	// Create metric provider with periodic reader
-	provider := sdkmetric.NewMeterProvider(
-		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(
-			sdkmetric.NewPeriodicReader(exporter,
+// setupMetricProvider creates and registers a metric provider with an OTLP
+// reader, a Prometheus reader, or both
+func setupMetricProvider(ctx context.Context, res *resource.Resource, config Config) (Shutdown, error) {
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+
+	if config.Enabled {
+		// Create OTLP metric exporter
+		exporter, err := otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpoint(config.OTLPEndpoint),
+			otlpmetricgrpc.WithInsecure(), // Use TLS in production
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(
+			sdkmetric.NewPeriodicReader(&trackedMetricExporter{Exporter: exporter, state: metricExportState},
 				sdkmetric.WithInterval(10*time.Second),
 			),
-		),
-	)
+		))
+	}
+
+	if config.Prometheus {
+		reader, err := setupPrometheusReader()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdkmetric.WithReader(reader))
+	}
+
+	provider := sdkmetric.NewMeterProvider(opts...)
 
 	// Set global meter provider
 	otel.SetMeterProvider(provider)
 
+	// Go runtime metrics: goroutines, heap, GC and scheduler
+	if err := runtime.Start(runtime.WithMeterProvider(provider)); err != nil {
+		_ = provider.Shutdown(ctx)
+		return nil, fmt.Errorf("failed to start runtime metrics: %w", err)
+	}
+
 	log.Println("Metric provider initialized")
 
 	return provider.Shutdown, nil
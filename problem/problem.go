@@ -0,0 +1,167 @@
+// Package problem renders gateway errors as RFC 7807 problem details
+// (application/problem+json). The gRPC status details the server attaches
+// become extension members: ErrorInfo gives the problem type, BadRequest
+// the field violations and RequestInfo the request ID.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/paulstuart/grpc-example/logging"
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+const loggerName = "problem"
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// DefaultTypeBase documents each ErrorInfo reason under an anchor named
+// after it, e.g. #user-not-found
+const DefaultTypeBase = "https://github.com/paulstuart/grpc-example/blob/main/docs/ERRORS.md#"
+
+// Details is an RFC 7807 problem details object with this API's extensions
+type Details struct {
+	// Type is a URI for the kind of problem; "about:blank" when the error
+	// has no ErrorInfo
+	Type string `json:"type"`
+	// Title summarizes the type and doesn't change between occurrences
+	Title string `json:"title"`
+	// Status is the HTTP status code
+	Status int `json:"status"`
+	// Detail explains this occurrence, it's the gRPC status message
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+
+	// Code is the gRPC status code name, e.g. "NOT_FOUND"
+	Code string `json:"code"`
+	// Reason, Domain and Metadata come from an ErrorInfo detail
+	Reason     string            `json:"reason,omitempty"`
+	Domain     string            `json:"domain,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Violations []Violation       `json:"violations,omitempty"`
+	RequestID  string            `json:"requestId,omitempty"`
+}
+
+// Violation is one invalid request field from a BadRequest detail
+type Violation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// FromStatus builds the problem details of st. typeBase prefixes the
+// kebab-cased ErrorInfo reason to form the type URI.
+func FromStatus(st *status.Status, httpStatus int, typeBase string) *Details {
+	p := &Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(httpStatus),
+		Status: httpStatus,
+		Detail: st.Message(),
+		Code:   codeName(st.Code()),
+	}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			p.Reason, p.Domain, p.Metadata = d.Reason, d.Domain, d.Metadata
+			p.Type = typeBase + strings.ToLower(strings.ReplaceAll(d.Reason, "_", "-"))
+			p.Title = title(d.Reason)
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				p.Violations = append(p.Violations, Violation{Field: v.Field, Description: v.Description, Reason: v.Reason})
+			}
+		case *errdetails.RequestInfo:
+			p.RequestID = d.RequestId
+		}
+	}
+	return p
+}
+
+// codeName returns the canonical name of a code, e.g. "NOT_FOUND"
+func codeName(code codes.Code) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range code.String() {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return strings.ToUpper(b.String())
+}
+
+// title turns a reason like "USER_NOT_FOUND" into "User not found"
+func title(reason string) string {
+	s := strings.ToLower(strings.ReplaceAll(reason, "_", " "))
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// Config configures the error handler
+type Config struct {
+	// TypeBase prefixes reasons to form type URIs; empty means DefaultTypeBase
+	TypeBase string
+	// HeaderMatcher maps gRPC response headers to HTTP headers, as given
+	// to runtime.WithOutgoingHeaderMatcher; nil drops them
+	HeaderMatcher runtime.HeaderMatcherFunc
+}
+
+// ErrorHandler returns a gateway error handler, for runtime.WithErrorHandler,
+// that writes problem details instead of the gateway's Status JSON
+func ErrorHandler(cfg Config) runtime.ErrorHandlerFunc {
+	if cfg.TypeBase == "" {
+		cfg.TypeBase = DefaultTypeBase
+	}
+	return func(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		var customStatus *runtime.HTTPStatusError
+		if errors.As(err, &customStatus) {
+			err = customStatus.Err
+		}
+		st := status.Convert(err)
+		httpStatus := runtime.HTTPStatusFromCode(st.Code())
+		if customStatus != nil {
+			httpStatus = customStatus.HTTPStatus
+		}
+
+		p := FromStatus(st, httpStatus, cfg.TypeBase)
+		p.Instance = r.URL.Path
+		if p.RequestID == "" {
+			p.RequestID = requestid.FromContext(r.Context())
+		}
+
+		h := w.Header()
+		h.Del("Trailer")
+		h.Del("Transfer-Encoding")
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok && cfg.HeaderMatcher != nil {
+			for key, values := range md.HeaderMD {
+				if name, ok := cfg.HeaderMatcher(key); ok {
+					for _, v := range values {
+						h.Add(name, v)
+					}
+				}
+			}
+		}
+		if st.Code() == codes.Unauthenticated {
+			h.Set("WWW-Authenticate", st.Message())
+		}
+		h.Set("Content-Type", ContentType)
+		w.WriteHeader(httpStatus)
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			logging.For(loggerName).DebugContext(ctx, "failed to write problem details", "error", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFromStatus(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "username is required").WithDetails(
+		&errdetails.ErrorInfo{Reason: "INVALID_FIELD", Domain: "example"},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "username", Description: "username is required"},
+		}},
+		&errdetails.RequestInfo{RequestId: "req-1"},
+	)
+	require.NoError(t, err)
+
+	p := FromStatus(st, http.StatusBadRequest, "https://example.com/errors#")
+	assert.Equal(t, "https://example.com/errors#invalid-field", p.Type)
+	assert.Equal(t, "Invalid field", p.Title)
+	assert.Equal(t, "INVALID_ARGUMENT", p.Code)
+	assert.Equal(t, []Violation{{Field: "username", Description: "username is required"}}, p.Violations)
+	assert.Equal(t, "req-1", p.RequestID)
+
+	// Without an ErrorInfo the type is about:blank, titled by the HTTP status
+	p = FromStatus(status.New(codes.DeadlineExceeded, "slow"), http.StatusGatewayTimeout, DefaultTypeBase)
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, "Gateway Timeout", p.Title)
+	assert.Equal(t, "DEADLINE_EXCEEDED", p.Code)
+	assert.Equal(t, "OK", codeName(codes.OK))
+}
+
+func TestErrorHandler(t *testing.T) {
+	handler := ErrorHandler(Config{})
+	mux := runtime.NewServeMux()
+	_, marshaler := runtime.MarshalerForRequest(mux, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/7", nil)
+	handler(context.Background(), mux, marshaler, rec, req, status.Error(codes.NotFound, "user not found"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+
+	var p Details
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "/api/v1/users/7", p.Instance)
+	assert.Equal(t, "user not found", p.Detail)
+
+	// Routing errors can carry their own HTTP status
+	rec = httptest.NewRecorder()
+	handler(context.Background(), mux, marshaler, rec, req, &runtime.HTTPStatusError{
+		HTTPStatus: http.StatusMethodNotAllowed,
+		Err:        status.Error(codes.Unimplemented, "Method Not Allowed"),
+	})
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -11,13 +11,17 @@
 package pkg
 
 import (
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
 	_ "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
+	status "google.golang.org/genproto/googleapis/rpc/status"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
 	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -238,7 +242,7 @@ func (x UserActivity_ActivityType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use UserActivity_ActivityType.Descriptor instead.
 func (UserActivity_ActivityType) EnumDescriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{9, 0}
+	return file_example_proto_rawDescGZIP(), []int{13, 0}
 }
 
 type SyncUserResponse_SyncStatus int32
@@ -287,18 +291,21 @@ func (x SyncUserResponse_SyncStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use SyncUserResponse_SyncStatus.Descriptor instead.
 func (SyncUserResponse_SyncStatus) EnumDescriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{11, 0}
+	return file_example_proto_rawDescGZIP(), []int{15, 0}
 }
 
 // User message with comprehensive protobuf features
 type User struct {
-	state      protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Assigned by the server when 0 in AddUser, BatchAddUsers and SyncUsers
 	Id         uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Role       Role                   `protobuf:"varint,2,opt,name=role,proto3,enum=proto.Role" json:"role,omitempty"`
 	CreateDate *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=create_date,json=createDate,proto3" json:"create_date,omitempty"`
-	Username   string                 `protobuf:"bytes,4,opt,name=username,proto3" json:"username,omitempty"`
+	// Unique, ignoring case
+	Username string `protobuf:"bytes,4,opt,name=username,proto3" json:"username,omitempty"`
 	// Contact information (both optional, real-world users typically have both)
 	Email string `protobuf:"bytes,5,opt,name=email,proto3" json:"email,omitempty"`
+	// Digits with optional leading + and separators, e.g. +1-555-0100
 	Phone string `protobuf:"bytes,6,opt,name=phone,proto3" json:"phone,omitempty"`
 	// Nested message
 	Profile *Profile `protobuf:"bytes,7,opt,name=profile,proto3" json:"profile,omitempty"`
@@ -309,7 +316,9 @@ type User struct {
 	Status    UserStatus             `protobuf:"varint,10,opt,name=status,proto3,enum=proto.UserStatus" json:"status,omitempty"`
 	LastLogin *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=last_login,json=lastLogin,proto3" json:"last_login,omitempty"`
 	// Repeated nested messages
-	Addresses     []*Address `protobuf:"bytes,12,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	Addresses []*Address `protobuf:"bytes,12,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	// When the user was deleted, set while status is DELETED
+	DeleteTime    *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=delete_time,json=deleteTime,proto3" json:"delete_time,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -428,6 +437,13 @@ func (x *User) GetAddresses() []*Address {
 	return nil
 }
 
+func (x *User) GetDeleteTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeleteTime
+	}
+	return nil
+}
+
 // Nested message example
 type Profile struct {
 	state       protoimpl.MessageState `protogen:"open.v1"`
@@ -508,14 +524,15 @@ func (x *Profile) GetPreferences() map[string]int32 {
 
 // Another nested message
 type Address struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          Address_AddressType    `protobuf:"varint,1,opt,name=type,proto3,enum=proto.Address_AddressType" json:"type,omitempty"`
-	Street        string                 `protobuf:"bytes,2,opt,name=street,proto3" json:"street,omitempty"`
-	City          string                 `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
-	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
-	PostalCode    string                 `protobuf:"bytes,5,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
-	Country       string                 `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
-	IsPrimary     bool                   `protobuf:"varint,7,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Type   Address_AddressType    `protobuf:"varint,1,opt,name=type,proto3,enum=proto.Address_AddressType" json:"type,omitempty"`
+	Street string                 `protobuf:"bytes,2,opt,name=street,proto3" json:"street,omitempty"`
+	City   string                 `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
+	State  string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	// Letters and digits, optionally split by a space or dash, e.g. 94102 or SW1A 1AA
+	PostalCode    string `protobuf:"bytes,5,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	Country       string `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
+	IsPrimary     bool   `protobuf:"varint,7,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -600,8 +617,10 @@ func (x *Address) GetIsPrimary() bool {
 }
 
 type UserRole struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Role          Role                   `protobuf:"varint,1,opt,name=role,proto3,enum=proto.Role" json:"role,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Role  Role                   `protobuf:"varint,1,opt,name=role,proto3,enum=proto.Role" json:"role,omitempty"`
+	// Include deleted users
+	ShowDeleted   bool `protobuf:"varint,2,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -643,6 +662,13 @@ func (x *UserRole) GetRole() Role {
 	return Role_GUEST
 }
 
+func (x *UserRole) GetShowDeleted() bool {
+	if x != nil {
+		return x.ShowDeleted
+	}
+	return false
+}
+
 type UpdateUserRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// The user resource which replaces the resource on the server.
@@ -706,8 +732,20 @@ type ListUsersRequest struct {
 	// Filter by status
 	Status UserStatus `protobuf:"varint,3,opt,name=status,proto3,enum=proto.UserStatus" json:"status,omitempty"`
 	// Pagination
-	PageSize      int32  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
-	PageToken     string `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	PageSize  int32  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// Include deleted users
+	ShowDeleted bool `protobuf:"varint,6,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
+	// Only list users with an address in this city, matched exactly
+	City string `protobuf:"bytes,7,opt,name=city,proto3" json:"city,omitempty"`
+	// Only list users with an address in this country, matched exactly; with
+	// city, both must be on the same address
+	Country string `protobuf:"bytes,8,opt,name=country,proto3" json:"country,omitempty"`
+	// Only list users having every one of these tags
+	Tags []string `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Only list users whose metadata holds every one of these pairs, given
+	// as metadata[key]=value in REST queries
+	Metadata      map[string]string `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -777,9 +815,53 @@ func (x *ListUsersRequest) GetPageToken() string {
 	return ""
 }
 
+func (x *ListUsersRequest) GetShowDeleted() bool {
+	if x != nil {
+		return x.ShowDeleted
+	}
+	return false
+}
+
+func (x *ListUsersRequest) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *ListUsersRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ListUsersRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ListUsersRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 type GetUserRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Return the user even if it's deleted
+	ShowDeleted bool `protobuf:"varint,2,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
+	// Read a past version of the user instead of the current one
+	//
+	// Types that are valid to be assigned to Version:
+	//
+	//	*GetUserRequest_AsOf
+	//	*GetUserRequest_Revision
+	Version       isGetUserRequest_Version `protobuf_oneof:"version"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -821,6 +903,154 @@ func (x *GetUserRequest) GetId() uint32 {
 	return 0
 }
 
+func (x *GetUserRequest) GetShowDeleted() bool {
+	if x != nil {
+		return x.ShowDeleted
+	}
+	return false
+}
+
+func (x *GetUserRequest) GetVersion() isGetUserRequest_Version {
+	if x != nil {
+		return x.Version
+	}
+	return nil
+}
+
+func (x *GetUserRequest) GetAsOf() *timestamppb.Timestamp {
+	if x != nil {
+		if x, ok := x.Version.(*GetUserRequest_AsOf); ok {
+			return x.AsOf
+		}
+	}
+	return nil
+}
+
+func (x *GetUserRequest) GetRevision() uint64 {
+	if x != nil {
+		if x, ok := x.Version.(*GetUserRequest_Revision); ok {
+			return x.Revision
+		}
+	}
+	return 0
+}
+
+type isGetUserRequest_Version interface {
+	isGetUserRequest_Version()
+}
+
+type GetUserRequest_AsOf struct {
+	// The revision current at this time
+	AsOf *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=as_of,json=asOf,proto3,oneof"`
+}
+
+type GetUserRequest_Revision struct {
+	// A revision number from ListUserRevisions
+	Revision uint64 `protobuf:"varint,4,opt,name=revision,proto3,oneof"`
+}
+
+func (*GetUserRequest_AsOf) isGetUserRequest_Version() {}
+
+func (*GetUserRequest_Revision) isGetUserRequest_Version() {}
+
+type AddUserResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The user as stored, with its ID and defaults filled in
+	User          *User `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddUserResponse) Reset() {
+	*x = AddUserResponse{}
+	mi := &file_example_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddUserResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddUserResponse) ProtoMessage() {}
+
+func (x *AddUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddUserResponse.ProtoReflect.Descriptor instead.
+func (*AddUserResponse) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AddUserResponse) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+type GetUserByUsernameRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// Return the user even if it's deleted
+	ShowDeleted   bool `protobuf:"varint,2,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserByUsernameRequest) Reset() {
+	*x = GetUserByUsernameRequest{}
+	mi := &file_example_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserByUsernameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserByUsernameRequest) ProtoMessage() {}
+
+func (x *GetUserByUsernameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserByUsernameRequest.ProtoReflect.Descriptor instead.
+func (*GetUserByUsernameRequest) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetUserByUsernameRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetUserByUsernameRequest) GetShowDeleted() bool {
+	if x != nil {
+		return x.ShowDeleted
+	}
+	return false
+}
+
 type DeleteUserRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -830,7 +1060,7 @@ type DeleteUserRequest struct {
 
 func (x *DeleteUserRequest) Reset() {
 	*x = DeleteUserRequest{}
-	mi := &file_example_proto_msgTypes[7]
+	mi := &file_example_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -842,7 +1072,7 @@ func (x *DeleteUserRequest) String() string {
 func (*DeleteUserRequest) ProtoMessage() {}
 
 func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_example_proto_msgTypes[7]
+	mi := &file_example_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -855,7 +1085,7 @@ func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
 func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{7}
+	return file_example_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DeleteUserRequest) GetId() uint32 {
@@ -865,21 +1095,72 @@ func (x *DeleteUserRequest) GetId() uint32 {
 	return 0
 }
 
+type UndeleteUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UndeleteUserRequest) Reset() {
+	*x = UndeleteUserRequest{}
+	mi := &file_example_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UndeleteUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UndeleteUserRequest) ProtoMessage() {}
+
+func (x *UndeleteUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UndeleteUserRequest.ProtoReflect.Descriptor instead.
+func (*UndeleteUserRequest) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UndeleteUserRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
 // Response for batch add operation
 type BatchAddUsersResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TotalReceived int32                  `protobuf:"varint,1,opt,name=total_received,json=totalReceived,proto3" json:"total_received,omitempty"`
 	TotalAdded    int32                  `protobuf:"varint,2,opt,name=total_added,json=totalAdded,proto3" json:"total_added,omitempty"`
 	TotalFailed   int32                  `protobuf:"varint,3,opt,name=total_failed,json=totalFailed,proto3" json:"total_failed,omitempty"`
-	Errors        []string               `protobuf:"bytes,4,rep,name=errors,proto3" json:"errors,omitempty"`
-	ProcessedAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=processed_at,json=processedAt,proto3" json:"processed_at,omitempty"`
+	// Use failures instead
+	//
+	// Deprecated: Marked as deprecated in example.proto.
+	Errors      []string               `protobuf:"bytes,4,rep,name=errors,proto3" json:"errors,omitempty"`
+	ProcessedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=processed_at,json=processedAt,proto3" json:"processed_at,omitempty"`
+	// Why each user that wasn't added failed
+	Failures []*BatchError `protobuf:"bytes,6,rep,name=failures,proto3" json:"failures,omitempty"`
+	// Set when an atomic batch had failures and no users were added
+	RolledBack    bool `protobuf:"varint,7,opt,name=rolled_back,json=rolledBack,proto3" json:"rolled_back,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *BatchAddUsersResponse) Reset() {
 	*x = BatchAddUsersResponse{}
-	mi := &file_example_proto_msgTypes[8]
+	mi := &file_example_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -891,7 +1172,7 @@ func (x *BatchAddUsersResponse) String() string {
 func (*BatchAddUsersResponse) ProtoMessage() {}
 
 func (x *BatchAddUsersResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_example_proto_msgTypes[8]
+	mi := &file_example_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -904,7 +1185,7 @@ func (x *BatchAddUsersResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchAddUsersResponse.ProtoReflect.Descriptor instead.
 func (*BatchAddUsersResponse) Descriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{8}
+	return file_example_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *BatchAddUsersResponse) GetTotalReceived() int32 {
@@ -928,6 +1209,7 @@ func (x *BatchAddUsersResponse) GetTotalFailed() int32 {
 	return 0
 }
 
+// Deprecated: Marked as deprecated in example.proto.
 func (x *BatchAddUsersResponse) GetErrors() []string {
 	if x != nil {
 		return x.Errors
@@ -942,6 +1224,83 @@ func (x *BatchAddUsersResponse) GetProcessedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *BatchAddUsersResponse) GetFailures() []*BatchError {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+func (x *BatchAddUsersResponse) GetRolledBack() bool {
+	if x != nil {
+		return x.RolledBack
+	}
+	return false
+}
+
+// A user in a batch that couldn't be added
+type BatchError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position of the user in the stream, from 0
+	Index  int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	UserId uint32 `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// The error, with its code and details
+	Status        *status.Status `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchError) Reset() {
+	*x = BatchError{}
+	mi := &file_example_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchError) ProtoMessage() {}
+
+func (x *BatchError) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchError.ProtoReflect.Descriptor instead.
+func (*BatchError) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BatchError) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BatchError) GetUserId() uint32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *BatchError) GetStatus() *status.Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
 // User activity for bidirectional streaming
 type UserActivity struct {
 	state         protoimpl.MessageState    `protogen:"open.v1"`
@@ -955,7 +1314,7 @@ type UserActivity struct {
 
 func (x *UserActivity) Reset() {
 	*x = UserActivity{}
-	mi := &file_example_proto_msgTypes[9]
+	mi := &file_example_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -967,7 +1326,7 @@ func (x *UserActivity) String() string {
 func (*UserActivity) ProtoMessage() {}
 
 func (x *UserActivity) ProtoReflect() protoreflect.Message {
-	mi := &file_example_proto_msgTypes[9]
+	mi := &file_example_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -980,7 +1339,7 @@ func (x *UserActivity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserActivity.ProtoReflect.Descriptor instead.
 func (*UserActivity) Descriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{9}
+	return file_example_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *UserActivity) GetUserId() uint32 {
@@ -1023,7 +1382,7 @@ type UserActivityResponse struct {
 
 func (x *UserActivityResponse) Reset() {
 	*x = UserActivityResponse{}
-	mi := &file_example_proto_msgTypes[10]
+	mi := &file_example_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1035,7 +1394,7 @@ func (x *UserActivityResponse) String() string {
 func (*UserActivityResponse) ProtoMessage() {}
 
 func (x *UserActivityResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_example_proto_msgTypes[10]
+	mi := &file_example_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1048,7 +1407,7 @@ func (x *UserActivityResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserActivityResponse.ProtoReflect.Descriptor instead.
 func (*UserActivityResponse) Descriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{10}
+	return file_example_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *UserActivityResponse) GetUserId() uint32 {
@@ -1092,7 +1451,7 @@ type SyncUserResponse struct {
 
 func (x *SyncUserResponse) Reset() {
 	*x = SyncUserResponse{}
-	mi := &file_example_proto_msgTypes[11]
+	mi := &file_example_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1104,7 +1463,7 @@ func (x *SyncUserResponse) String() string {
 func (*SyncUserResponse) ProtoMessage() {}
 
 func (x *SyncUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_example_proto_msgTypes[11]
+	mi := &file_example_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1117,7 +1476,7 @@ func (x *SyncUserResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SyncUserResponse.ProtoReflect.Descriptor instead.
 func (*SyncUserResponse) Descriptor() ([]byte, []int) {
-	return file_example_proto_rawDescGZIP(), []int{11}
+	return file_example_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *SyncUserResponse) GetUserId() uint32 {
@@ -1148,84 +1507,586 @@ func (x *SyncUserResponse) GetUpdatedFields() []string {
 	return nil
 }
 
+// A record of one change to a user. Events are chained: each hash covers the
+// event and the previous event's hash, so editing or removing an event
+// breaks every hash after it.
+type AuditEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position in the log, starting at 1
+	Sequence uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Time     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	// Who made the change, from the caller's token; empty if unauthenticated
+	Actor string `protobuf:"bytes,3,opt,name=actor,proto3" json:"actor,omitempty"`
+	// The RPC that made the change, e.g. UpdateUser
+	Method string `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+	// The user changed
+	TargetId  uint32         `protobuf:"varint,5,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
+	Changes   []*FieldChange `protobuf:"bytes,6,rep,name=changes,proto3" json:"changes,omitempty"`
+	RequestId string         `protobuf:"bytes,7,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Hex SHA-256 of the previous event, empty for the first
+	PrevHash string `protobuf:"bytes,8,opt,name=prev_hash,json=prevHash,proto3" json:"prev_hash,omitempty"`
+	// Hex SHA-256 of this event with hash unset
+	Hash          string `protobuf:"bytes,9,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditEvent) Reset() {
+	*x = AuditEvent{}
+	mi := &file_example_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEvent) ProtoMessage() {}
+
+func (x *AuditEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEvent.ProtoReflect.Descriptor instead.
+func (*AuditEvent) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *AuditEvent) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *AuditEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *AuditEvent) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetTargetId() uint32 {
+	if x != nil {
+		return x.TargetId
+	}
+	return 0
+}
+
+func (x *AuditEvent) GetChanges() []*FieldChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *AuditEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetPrevHash() string {
+	if x != nil {
+		return x.PrevHash
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+// A changed field; sensitive values are redacted
+type FieldChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path of the field, e.g. profile.display_name
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// Value before the change, unset if the field was empty
+	Before *structpb.Value `protobuf:"bytes,2,opt,name=before,proto3" json:"before,omitempty"`
+	// Value after the change, unset if the field is now empty
+	After         *structpb.Value `protobuf:"bytes,3,opt,name=after,proto3" json:"after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FieldChange) Reset() {
+	*x = FieldChange{}
+	mi := &file_example_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FieldChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldChange) ProtoMessage() {}
+
+func (x *FieldChange) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldChange.ProtoReflect.Descriptor instead.
+func (*FieldChange) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *FieldChange) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldChange) GetBefore() *structpb.Value {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *FieldChange) GetAfter() *structpb.Value {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+type ListAuditEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only events for this user
+	TargetId uint32 `protobuf:"varint,1,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
+	// Only events by this actor
+	Actor string `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`
+	// Only events from this method
+	Method string `protobuf:"bytes,3,opt,name=method,proto3" json:"method,omitempty"`
+	// Only events at or after this time
+	Since *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=since,proto3" json:"since,omitempty"`
+	// Only events before this time
+	Until *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=until,proto3" json:"until,omitempty"`
+	// Only events after this sequence number, to resume a listing
+	AfterSequence uint64 `protobuf:"varint,6,opt,name=after_sequence,json=afterSequence,proto3" json:"after_sequence,omitempty"`
+	PageSize      int32  `protobuf:"varint,7,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAuditEventsRequest) Reset() {
+	*x = ListAuditEventsRequest{}
+	mi := &file_example_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAuditEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAuditEventsRequest) ProtoMessage() {}
+
+func (x *ListAuditEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAuditEventsRequest.ProtoReflect.Descriptor instead.
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ListAuditEventsRequest) GetTargetId() uint32 {
+	if x != nil {
+		return x.TargetId
+	}
+	return 0
+}
+
+func (x *ListAuditEventsRequest) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *ListAuditEventsRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *ListAuditEventsRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *ListAuditEventsRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *ListAuditEventsRequest) GetAfterSequence() uint64 {
+	if x != nil {
+		return x.AfterSequence
+	}
+	return 0
+}
+
+func (x *ListAuditEventsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+// A stored version of a user. Every change to a user stores a new revision.
+type UserRevision struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId uint32                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Revisions of a user are numbered from 1
+	Revision uint64 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	// When this revision was written
+	RevisionTime  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=revision_time,json=revisionTime,proto3" json:"revision_time,omitempty"`
+	User          *User                  `protobuf:"bytes,4,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserRevision) Reset() {
+	*x = UserRevision{}
+	mi := &file_example_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserRevision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserRevision) ProtoMessage() {}
+
+func (x *UserRevision) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserRevision.ProtoReflect.Descriptor instead.
+func (*UserRevision) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *UserRevision) GetUserId() uint32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *UserRevision) GetRevision() uint64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *UserRevision) GetRevisionTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RevisionTime
+	}
+	return nil
+}
+
+func (x *UserRevision) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+type ListUserRevisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint32                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUserRevisionsRequest) Reset() {
+	*x = ListUserRevisionsRequest{}
+	mi := &file_example_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUserRevisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUserRevisionsRequest) ProtoMessage() {}
+
+func (x *ListUserRevisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUserRevisionsRequest.ProtoReflect.Descriptor instead.
+func (*ListUserRevisionsRequest) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ListUserRevisionsRequest) GetUserId() uint32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+type RestoreUserRevisionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint32                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Revision      uint64                 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreUserRevisionRequest) Reset() {
+	*x = RestoreUserRevisionRequest{}
+	mi := &file_example_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreUserRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreUserRevisionRequest) ProtoMessage() {}
+
+func (x *RestoreUserRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_example_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreUserRevisionRequest.ProtoReflect.Descriptor instead.
+func (*RestoreUserRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_example_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *RestoreUserRevisionRequest) GetUserId() uint32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *RestoreUserRevisionRequest) GetRevision() uint64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+var file_example_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50001,
+		Name:          "proto.sensitive",
+		Tag:           "varint,50001,opt,name=sensitive",
+		Filename:      "example.proto",
+	},
+}
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// Marks fields holding PII or secrets, whose values are redacted from logs
+	//
+	// optional bool sensitive = 50001;
+	E_Sensitive = &file_example_proto_extTypes[0]
+)
+
 var File_example_proto protoreflect.FileDescriptor
 
 const file_example_proto_rawDesc = "" +
 	"\n" +
-	"\rexample.proto\x12\x05proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/duration.proto\x1a google/protobuf/field_mask.proto\x1a\x1cgoogle/api/annotations.proto\x1a.protoc-gen-openapiv2/options/annotations.proto\"\x82\x04\n" +
+	"\rexample.proto\x12\x05proto\x1a google/protobuf/descriptor.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/duration.proto\x1a google/protobuf/field_mask.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1cgoogle/api/annotations.proto\x1a\x17google/rpc/status.proto\x1a\x1bbuf/validate/validate.proto\x1a.protoc-gen-openapiv2/options/annotations.proto\"\xf5\x05\n" +
 	"\x04User\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\rR\x02id\x12\x1f\n" +
-	"\x04role\x18\x02 \x01(\x0e2\v.proto.RoleR\x04role\x12;\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12)\n" +
+	"\x04role\x18\x02 \x01(\x0e2\v.proto.RoleB\b\xbaH\x05\x82\x01\x02\x10\x01R\x04role\x12;\n" +
 	"\vcreate_date\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
-	"createDate\x12\x1a\n" +
-	"\busername\x18\x04 \x01(\tR\busername\x12\x14\n" +
-	"\x05email\x18\x05 \x01(\tR\x05email\x12\x14\n" +
-	"\x05phone\x18\x06 \x01(\tR\x05phone\x12(\n" +
-	"\aprofile\x18\a \x01(\v2\x0e.proto.ProfileR\aprofile\x12\x12\n" +
-	"\x04tags\x18\b \x03(\tR\x04tags\x125\n" +
-	"\bmetadata\x18\t \x03(\v2\x19.proto.User.MetadataEntryR\bmetadata\x12)\n" +
+	"createDate\x12D\n" +
+	"\busername\x18\x04 \x01(\tB(\xbaH%\xd8\x01\x01r \x18@2\x1c^[A-Za-z0-9][A-Za-z0-9._-]*$R\busername\x12'\n" +
+	"\x05email\x18\x05 \x01(\tB\x11\xbaH\n" +
+	"\xd8\x01\x01r\x05\x18\xfe\x01`\x01\x88\xb5\x18\x01R\x05email\x12>\n" +
+	"\x05phone\x18\x06 \x01(\tB(\xbaH!\xd8\x01\x01r\x1c2\x1a^\\+?[0-9][0-9 ().-]{5,19}$\x88\xb5\x18\x01R\x05phone\x12(\n" +
+	"\aprofile\x18\a \x01(\v2\x0e.proto.ProfileR\aprofile\x12&\n" +
+	"\x04tags\x18\b \x03(\tB\x12\xbaH\x0f\x92\x01\f\x10\x14\x18\x01\"\x06r\x04\x10\x01\x18 R\x04tags\x12N\n" +
+	"\bmetadata\x18\t \x03(\v2\x19.proto.User.MetadataEntryB\x17\xbaH\x14\x9a\x01\x11\x10 \"\x06r\x04\x10\x01\x18@*\x05r\x03\x18\x80\x02R\bmetadata\x123\n" +
 	"\x06status\x18\n" +
-	" \x01(\x0e2\x11.proto.UserStatusR\x06status\x129\n" +
+	" \x01(\x0e2\x11.proto.UserStatusB\b\xbaH\x05\x82\x01\x02\x10\x01R\x06status\x129\n" +
 	"\n" +
-	"last_login\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tlastLogin\x12,\n" +
-	"\taddresses\x18\f \x03(\v2\x0e.proto.AddressR\taddresses\x1a;\n" +
+	"last_login\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tlastLogin\x12:\n" +
+	"\taddresses\x18\f \x03(\v2\x0e.proto.AddressB\f\xbaH\x05\x92\x01\x02\x10\n" +
+	"\x88\xb5\x18\x01R\taddresses\x12;\n" +
+	"\vdelete_time\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"deleteTime\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa0\x02\n" +
-	"\aProfile\x12!\n" +
-	"\fdisplay_name\x18\x01 \x01(\tR\vdisplayName\x12\x10\n" +
-	"\x03bio\x18\x02 \x01(\tR\x03bio\x12\x1d\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe0\x02\n" +
+	"\aProfile\x12*\n" +
+	"\fdisplay_name\x18\x01 \x01(\tB\a\xbaH\x04r\x02\x18dR\vdisplayName\x12\x1a\n" +
+	"\x03bio\x18\x02 \x01(\tB\b\xbaH\x05r\x03\x18\xe8\aR\x03bio\x12*\n" +
 	"\n" +
-	"avatar_url\x18\x03 \x01(\tR\tavatarUrl\x12>\n" +
-	"\rdate_of_birth\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\vdateOfBirth\x12A\n" +
-	"\vpreferences\x18\x05 \x03(\v2\x1f.proto.Profile.PreferencesEntryR\vpreferences\x1a>\n" +
+	"avatar_url\x18\x03 \x01(\tB\v\xbaH\b\xd8\x01\x01r\x03\x88\x01\x01R\tavatarUrl\x12L\n" +
+	"\rdate_of_birth\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampB\f\xbaH\x05\xb2\x01\x028\x01\x88\xb5\x18\x01R\vdateOfBirth\x12S\n" +
+	"\vpreferences\x18\x05 \x03(\v2\x1f.proto.Profile.PreferencesEntryB\x10\xbaH\r\x9a\x01\n" +
+	"\x10 \"\x06r\x04\x10\x01\x18@R\vpreferences\x1a>\n" +
 	"\x10PreferencesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"\x83\x02\n" +
-	"\aAddress\x12.\n" +
-	"\x04type\x18\x01 \x01(\x0e2\x1a.proto.Address.AddressTypeR\x04type\x12\x16\n" +
-	"\x06street\x18\x02 \x01(\tR\x06street\x12\x12\n" +
-	"\x04city\x18\x03 \x01(\tR\x04city\x12\x14\n" +
-	"\x05state\x18\x04 \x01(\tR\x05state\x12\x1f\n" +
-	"\vpostal_code\x18\x05 \x01(\tR\n" +
-	"postalCode\x12\x18\n" +
-	"\acountry\x18\x06 \x01(\tR\acountry\x12\x1d\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"\xe1\x02\n" +
+	"\aAddress\x128\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1a.proto.Address.AddressTypeB\b\xbaH\x05\x82\x01\x02\x10\x01R\x04type\x12 \n" +
+	"\x06street\x18\x02 \x01(\tB\b\xbaH\x05r\x03\x18\xc8\x01R\x06street\x12\x1b\n" +
+	"\x04city\x18\x03 \x01(\tB\a\xbaH\x04r\x02\x18dR\x04city\x12\x1d\n" +
+	"\x05state\x18\x04 \x01(\tB\a\xbaH\x04r\x02\x18dR\x05state\x12N\n" +
+	"\vpostal_code\x18\x05 \x01(\tB-\xbaH*\xd8\x01\x01r%\x18\f2!^[A-Za-z0-9]+([ -][A-Za-z0-9]+)?$R\n" +
+	"postalCode\x12!\n" +
+	"\acountry\x18\x06 \x01(\tB\a\xbaH\x04r\x02\x18@R\acountry\x12\x1d\n" +
 	"\n" +
 	"is_primary\x18\a \x01(\bR\tisPrimary\",\n" +
 	"\vAddressType\x12\b\n" +
 	"\x04HOME\x10\x00\x12\b\n" +
 	"\x04WORK\x10\x01\x12\t\n" +
-	"\x05OTHER\x10\x02\"+\n" +
-	"\bUserRole\x12\x1f\n" +
-	"\x04role\x18\x01 \x01(\x0e2\v.proto.RoleR\x04role\"q\n" +
-	"\x11UpdateUserRequest\x12\x1f\n" +
-	"\x04user\x18\x01 \x01(\v2\v.proto.UserR\x04user\x12;\n" +
+	"\x05OTHER\x10\x02\"X\n" +
+	"\bUserRole\x12)\n" +
+	"\x04role\x18\x01 \x01(\x0e2\v.proto.RoleB\b\xbaH\x05\x82\x01\x02\x10\x01R\x04role\x12!\n" +
+	"\fshow_deleted\x18\x02 \x01(\bR\vshowDeleted\"\xad\x01\n" +
+	"\x11UpdateUserRequest\x12[\n" +
+	"\x04user\x18\x01 \x01(\v2\v.proto.UserB:\xbaH7\xba\x011\n" +
+	"\auser.id\x12\x19id must be greater than 0\x1a\vthis.id > 0\xc8\x01\x01R\x04user\x12;\n" +
 	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
-	"updateMask\"\xf4\x01\n" +
+	"updateMask\"\xb6\x04\n" +
 	"\x10ListUsersRequest\x12?\n" +
-	"\rcreated_since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\fcreatedSince\x128\n" +
+	"\rcreated_since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\fcreatedSince\x12B\n" +
 	"\n" +
-	"older_than\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\tolderThan\x12)\n" +
-	"\x06status\x18\x03 \x01(\x0e2\x11.proto.UserStatusR\x06status\x12\x1b\n" +
-	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"older_than\x18\x02 \x01(\v2\x19.google.protobuf.DurationB\b\xbaH\x05\xaa\x01\x022\x00R\tolderThan\x123\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x11.proto.UserStatusB\b\xbaH\x05\x82\x01\x02\x10\x01R\x06status\x12'\n" +
+	"\tpage_size\x18\x04 \x01(\x05B\n" +
+	"\xbaH\a\x1a\x05\x18\xe8\a(\x00R\bpageSize\x12\x1d\n" +
 	"\n" +
-	"page_token\x18\x05 \x01(\tR\tpageToken\" \n" +
-	"\x0eGetUserRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\rR\x02id\"#\n" +
-	"\x11DeleteUserRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\rR\x02id\"\xd9\x01\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\x12!\n" +
+	"\fshow_deleted\x18\x06 \x01(\bR\vshowDeleted\x12\x1b\n" +
+	"\x04city\x18\a \x01(\tB\a\xbaH\x04r\x02\x18dR\x04city\x12!\n" +
+	"\acountry\x18\b \x01(\tB\a\xbaH\x04r\x02\x18@R\acountry\x12$\n" +
+	"\x04tags\x18\t \x03(\tB\x10\xbaH\r\x92\x01\n" +
+	"\x10\x14\"\x06r\x04\x10\x01\x18 R\x04tags\x12Z\n" +
+	"\bmetadata\x18\n" +
+	" \x03(\v2%.proto.ListUsersRequest.MetadataEntryB\x17\xbaH\x14\x9a\x01\x11\x10 \"\x06r\x04\x10\x01\x18@*\x05r\x03\x18\x80\x02R\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb1\x01\n" +
+	"\x0eGetUserRequest\x12\x17\n" +
+	"\x02id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x02id\x12!\n" +
+	"\fshow_deleted\x18\x02 \x01(\bR\vshowDeleted\x121\n" +
+	"\x05as_of\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampH\x00R\x04asOf\x12%\n" +
+	"\brevision\x18\x04 \x01(\x04B\a\xbaH\x042\x02 \x00H\x00R\brevisionB\t\n" +
+	"\aversion\"2\n" +
+	"\x0fAddUserResponse\x12\x1f\n" +
+	"\x04user\x18\x01 \x01(\v2\v.proto.UserR\x04user\"d\n" +
+	"\x18GetUserByUsernameRequest\x12%\n" +
+	"\busername\x18\x01 \x01(\tB\t\xbaH\x06r\x04\x10\x01\x18@R\busername\x12!\n" +
+	"\fshow_deleted\x18\x02 \x01(\bR\vshowDeleted\",\n" +
+	"\x11DeleteUserRequest\x12\x17\n" +
+	"\x02id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x02id\".\n" +
+	"\x13UndeleteUserRequest\x12\x17\n" +
+	"\x02id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x02id\"\xad\x02\n" +
 	"\x15BatchAddUsersResponse\x12%\n" +
 	"\x0etotal_received\x18\x01 \x01(\x05R\rtotalReceived\x12\x1f\n" +
 	"\vtotal_added\x18\x02 \x01(\x05R\n" +
 	"totalAdded\x12!\n" +
-	"\ftotal_failed\x18\x03 \x01(\x05R\vtotalFailed\x12\x16\n" +
-	"\x06errors\x18\x04 \x03(\tR\x06errors\x12=\n" +
-	"\fprocessed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vprocessedAt\"\xfc\x02\n" +
-	"\fUserActivity\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\rR\x06userId\x12E\n" +
-	"\ractivity_type\x18\x02 \x01(\x0e2 .proto.UserActivity.ActivityTypeR\factivityType\x128\n" +
-	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12:\n" +
-	"\adetails\x18\x04 \x03(\v2 .proto.UserActivity.DetailsEntryR\adetails\x1a:\n" +
+	"\ftotal_failed\x18\x03 \x01(\x05R\vtotalFailed\x12\x1a\n" +
+	"\x06errors\x18\x04 \x03(\tB\x02\x18\x01R\x06errors\x12=\n" +
+	"\fprocessed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vprocessedAt\x12-\n" +
+	"\bfailures\x18\x06 \x03(\v2\x11.proto.BatchErrorR\bfailures\x12\x1f\n" +
+	"\vrolled_back\x18\a \x01(\bR\n" +
+	"rolledBack\"g\n" +
+	"\n" +
+	"BatchError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\rR\x06userId\x12*\n" +
+	"\x06status\x18\x03 \x01(\v2\x12.google.rpc.StatusR\x06status\"\xa0\x03\n" +
+	"\fUserActivity\x12 \n" +
+	"\auser_id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x06userId\x12O\n" +
+	"\ractivity_type\x18\x02 \x01(\x0e2 .proto.UserActivity.ActivityTypeB\b\xbaH\x05\x82\x01\x02\x10\x01R\factivityType\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12K\n" +
+	"\adetails\x18\x04 \x03(\v2 .proto.UserActivity.DetailsEntryB\x0f\xbaH\f\x9a\x01\t\x10 *\x05r\x03\x18\x80\x02R\adetails\x1a:\n" +
 	"\fDetailsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"Z\n" +
@@ -1251,7 +2112,42 @@ const file_example_proto_rawDesc = "" +
 	"\aSUCCESS\x10\x00\x12\n" +
 	"\n" +
 	"\x06FAILED\x10\x01\x12\v\n" +
-	"\aPARTIAL\x10\x02*7\n" +
+	"\aPARTIAL\x10\x02\"\xa1\x02\n" +
+	"\n" +
+	"AuditEvent\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x14\n" +
+	"\x05actor\x18\x03 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06method\x18\x04 \x01(\tR\x06method\x12\x1b\n" +
+	"\ttarget_id\x18\x05 \x01(\rR\btargetId\x12,\n" +
+	"\achanges\x18\x06 \x03(\v2\x12.proto.FieldChangeR\achanges\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\a \x01(\tR\trequestId\x12\x1b\n" +
+	"\tprev_hash\x18\b \x01(\tR\bprevHash\x12\x12\n" +
+	"\x04hash\x18\t \x01(\tR\x04hash\"\x81\x01\n" +
+	"\vFieldChange\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12.\n" +
+	"\x06before\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x06before\x12,\n" +
+	"\x05after\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x05after\"\x97\x02\n" +
+	"\x16ListAuditEventsRequest\x12\x1b\n" +
+	"\ttarget_id\x18\x01 \x01(\rR\btargetId\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06method\x18\x03 \x01(\tR\x06method\x120\n" +
+	"\x05since\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12%\n" +
+	"\x0eafter_sequence\x18\x06 \x01(\x04R\rafterSequence\x12'\n" +
+	"\tpage_size\x18\a \x01(\x05B\n" +
+	"\xbaH\a\x1a\x05\x18\xe8\a(\x00R\bpageSize\"\xa5\x01\n" +
+	"\fUserRevision\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\rR\x06userId\x12\x1a\n" +
+	"\brevision\x18\x02 \x01(\x04R\brevision\x12?\n" +
+	"\rrevision_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\frevisionTime\x12\x1f\n" +
+	"\x04user\x18\x04 \x01(\v2\v.proto.UserR\x04user\"<\n" +
+	"\x18ListUserRevisionsRequest\x12 \n" +
+	"\auser_id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x06userId\"c\n" +
+	"\x1aRestoreUserRevisionRequest\x12 \n" +
+	"\auser_id\x18\x01 \x01(\rB\a\xbaH\x04*\x02 \x00R\x06userId\x12#\n" +
+	"\brevision\x18\x02 \x01(\x04B\a\xbaH\x042\x02 \x00R\brevision*7\n" +
 	"\x04Role\x12\t\n" +
 	"\x05GUEST\x10\x00\x12\n" +
 	"\n" +
@@ -1264,19 +2160,26 @@ const file_example_proto_rawDesc = "" +
 	"\n" +
 	"\x06ACTIVE\x10\x01\x12\r\n" +
 	"\tSUSPENDED\x10\x02\x12\v\n" +
-	"\aDELETED\x10\x032\xde\x05\n" +
+	"\aDELETED\x10\x032\x93\n" +
+	"\n" +
 	"\vUserService\x12H\n" +
-	"\aAddUser\x12\v.proto.User\x1a\x16.google.protobuf.Empty\"\x18\x82\xd3\xe4\x93\x02\x12:\x01*\"\r/api/v1/users\x12J\n" +
+	"\aAddUser\x12\v.proto.User\x1a\x16.proto.AddUserResponse\"\x18\x82\xd3\xe4\x93\x02\x12:\x01*\"\r/api/v1/users\x12J\n" +
 	"\tListUsers\x12\x17.proto.ListUsersRequest\x1a\v.proto.User\"\x15\x82\xd3\xe4\x93\x02\x0f\x12\r/api/v1/users0\x01\x12T\n" +
 	"\x0fListUsersByRole\x12\x0f.proto.UserRole\x1a\v.proto.User\"!\x82\xd3\xe4\x93\x02\x1b\x12\x19/api/v1/users/role/{role}0\x01\x12W\n" +
 	"\n" +
 	"UpdateUser\x12\x18.proto.UpdateUserRequest\x1a\v.proto.User\"\"\x82\xd3\xe4\x93\x02\x1c:\x01*2\x17/api/v1/users/{user.id}\x12I\n" +
-	"\aGetUser\x12\x15.proto.GetUserRequest\x1a\v.proto.User\"\x1a\x82\xd3\xe4\x93\x02\x14\x12\x12/api/v1/users/{id}\x12Z\n" +
+	"\aGetUser\x12\x15.proto.GetUserRequest\x1a\v.proto.User\"\x1a\x82\xd3\xe4\x93\x02\x14\x12\x12/api/v1/users/{id}\x12l\n" +
+	"\x11GetUserByUsername\x12\x1f.proto.GetUserByUsernameRequest\x1a\v.proto.User\")\x82\xd3\xe4\x93\x02#\x12!/api/v1/users/username/{username}\x12v\n" +
+	"\x11ListUserRevisions\x12\x1f.proto.ListUserRevisionsRequest\x1a\x13.proto.UserRevision\")\x82\xd3\xe4\x93\x02#\x12!/api/v1/users/{user_id}/revisions0\x01\x12\x86\x01\n" +
+	"\x13RestoreUserRevision\x12!.proto.RestoreUserRevisionRequest\x1a\v.proto.User\"?\x82\xd3\xe4\x93\x029:\x01*\"4/api/v1/users/{user_id}/revisions/{revision}:restore\x12Z\n" +
 	"\n" +
-	"DeleteUser\x12\x18.proto.DeleteUserRequest\x1a\x16.google.protobuf.Empty\"\x1a\x82\xd3\xe4\x93\x02\x14*\x12/api/v1/users/{id}\x12\\\n" +
-	"\rBatchAddUsers\x12\v.proto.User\x1a\x1c.proto.BatchAddUsersResponse\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/api/v1/users/batch(\x01\x12L\n" +
+	"DeleteUser\x12\x18.proto.DeleteUserRequest\x1a\x16.google.protobuf.Empty\"\x1a\x82\xd3\xe4\x93\x02\x14*\x12/api/v1/users/{id}\x12_\n" +
+	"\fUndeleteUser\x12\x1a.proto.UndeleteUserRequest\x1a\v.proto.User\"&\x82\xd3\xe4\x93\x02 :\x01*\"\x1b/api/v1/users/{id}:undelete\x12\\\n" +
+	"\rBatchAddUsers\x12\v.proto.User\x1a\x1c.proto.BatchAddUsersResponse\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/api/v1/users/batch(\x01\x12c\n" +
+	"\x0fListAuditEvents\x12\x1d.proto.ListAuditEventsRequest\x1a\x11.proto.AuditEvent\"\x1c\x82\xd3\xe4\x93\x02\x16\x12\x14/api/v1/audit/events0\x01\x12L\n" +
 	"\x12UserActivityStream\x12\x13.proto.UserActivity\x1a\x1b.proto.UserActivityResponse\"\x00(\x010\x01\x127\n" +
-	"\tSyncUsers\x12\v.proto.User\x1a\x17.proto.SyncUserResponse\"\x00(\x010\x01B\xfb\x01\x92A\xc9\x01\x12=\n" +
+	"\tSyncUsers\x12\v.proto.User\x1a\x17.proto.SyncUserResponse\"\x00(\x010\x01:=\n" +
+	"\tsensitive\x12\x1d.google.protobuf.FieldOptions\x18ц\x03 \x01(\bR\tsensitiveB\xfb\x01\x92A\xc9\x01\x12=\n" +
 	"\x10gRPC Example API\x12$gRPC Example with JWT Authentication2\x031.0*\x01\x022\x10application/json:\x10application/jsonZS\n" +
 	"Q\n" +
 	"\x06Bearer\x12G\b\x02\x122Enter your JWT token in the format: Bearer <token>\x1a\rAuthorization \x02b\f\n" +
@@ -1297,79 +2200,118 @@ func file_example_proto_rawDescGZIP() []byte {
 }
 
 var file_example_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
-var file_example_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_example_proto_msgTypes = make([]protoimpl.MessageInfo, 26)
 var file_example_proto_goTypes = []any{
-	(Role)(0),                        // 0: proto.Role
-	(UserStatus)(0),                  // 1: proto.UserStatus
-	(Address_AddressType)(0),         // 2: proto.Address.AddressType
-	(UserActivity_ActivityType)(0),   // 3: proto.UserActivity.ActivityType
-	(SyncUserResponse_SyncStatus)(0), // 4: proto.SyncUserResponse.SyncStatus
-	(*User)(nil),                     // 5: proto.User
-	(*Profile)(nil),                  // 6: proto.Profile
-	(*Address)(nil),                  // 7: proto.Address
-	(*UserRole)(nil),                 // 8: proto.UserRole
-	(*UpdateUserRequest)(nil),        // 9: proto.UpdateUserRequest
-	(*ListUsersRequest)(nil),         // 10: proto.ListUsersRequest
-	(*GetUserRequest)(nil),           // 11: proto.GetUserRequest
-	(*DeleteUserRequest)(nil),        // 12: proto.DeleteUserRequest
-	(*BatchAddUsersResponse)(nil),    // 13: proto.BatchAddUsersResponse
-	(*UserActivity)(nil),             // 14: proto.UserActivity
-	(*UserActivityResponse)(nil),     // 15: proto.UserActivityResponse
-	(*SyncUserResponse)(nil),         // 16: proto.SyncUserResponse
-	nil,                              // 17: proto.User.MetadataEntry
-	nil,                              // 18: proto.Profile.PreferencesEntry
-	nil,                              // 19: proto.UserActivity.DetailsEntry
-	(*timestamppb.Timestamp)(nil),    // 20: google.protobuf.Timestamp
-	(*fieldmaskpb.FieldMask)(nil),    // 21: google.protobuf.FieldMask
-	(*durationpb.Duration)(nil),      // 22: google.protobuf.Duration
-	(*emptypb.Empty)(nil),            // 23: google.protobuf.Empty
+	(Role)(0),                          // 0: proto.Role
+	(UserStatus)(0),                    // 1: proto.UserStatus
+	(Address_AddressType)(0),           // 2: proto.Address.AddressType
+	(UserActivity_ActivityType)(0),     // 3: proto.UserActivity.ActivityType
+	(SyncUserResponse_SyncStatus)(0),   // 4: proto.SyncUserResponse.SyncStatus
+	(*User)(nil),                       // 5: proto.User
+	(*Profile)(nil),                    // 6: proto.Profile
+	(*Address)(nil),                    // 7: proto.Address
+	(*UserRole)(nil),                   // 8: proto.UserRole
+	(*UpdateUserRequest)(nil),          // 9: proto.UpdateUserRequest
+	(*ListUsersRequest)(nil),           // 10: proto.ListUsersRequest
+	(*GetUserRequest)(nil),             // 11: proto.GetUserRequest
+	(*AddUserResponse)(nil),            // 12: proto.AddUserResponse
+	(*GetUserByUsernameRequest)(nil),   // 13: proto.GetUserByUsernameRequest
+	(*DeleteUserRequest)(nil),          // 14: proto.DeleteUserRequest
+	(*UndeleteUserRequest)(nil),        // 15: proto.UndeleteUserRequest
+	(*BatchAddUsersResponse)(nil),      // 16: proto.BatchAddUsersResponse
+	(*BatchError)(nil),                 // 17: proto.BatchError
+	(*UserActivity)(nil),               // 18: proto.UserActivity
+	(*UserActivityResponse)(nil),       // 19: proto.UserActivityResponse
+	(*SyncUserResponse)(nil),           // 20: proto.SyncUserResponse
+	(*AuditEvent)(nil),                 // 21: proto.AuditEvent
+	(*FieldChange)(nil),                // 22: proto.FieldChange
+	(*ListAuditEventsRequest)(nil),     // 23: proto.ListAuditEventsRequest
+	(*UserRevision)(nil),               // 24: proto.UserRevision
+	(*ListUserRevisionsRequest)(nil),   // 25: proto.ListUserRevisionsRequest
+	(*RestoreUserRevisionRequest)(nil), // 26: proto.RestoreUserRevisionRequest
+	nil,                                // 27: proto.User.MetadataEntry
+	nil,                                // 28: proto.Profile.PreferencesEntry
+	nil,                                // 29: proto.ListUsersRequest.MetadataEntry
+	nil,                                // 30: proto.UserActivity.DetailsEntry
+	(*timestamppb.Timestamp)(nil),      // 31: google.protobuf.Timestamp
+	(*fieldmaskpb.FieldMask)(nil),      // 32: google.protobuf.FieldMask
+	(*durationpb.Duration)(nil),        // 33: google.protobuf.Duration
+	(*status.Status)(nil),              // 34: google.rpc.Status
+	(*structpb.Value)(nil),             // 35: google.protobuf.Value
+	(*descriptorpb.FieldOptions)(nil),  // 36: google.protobuf.FieldOptions
+	(*emptypb.Empty)(nil),              // 37: google.protobuf.Empty
 }
 var file_example_proto_depIdxs = []int32{
 	0,  // 0: proto.User.role:type_name -> proto.Role
-	20, // 1: proto.User.create_date:type_name -> google.protobuf.Timestamp
+	31, // 1: proto.User.create_date:type_name -> google.protobuf.Timestamp
 	6,  // 2: proto.User.profile:type_name -> proto.Profile
-	17, // 3: proto.User.metadata:type_name -> proto.User.MetadataEntry
+	27, // 3: proto.User.metadata:type_name -> proto.User.MetadataEntry
 	1,  // 4: proto.User.status:type_name -> proto.UserStatus
-	20, // 5: proto.User.last_login:type_name -> google.protobuf.Timestamp
+	31, // 5: proto.User.last_login:type_name -> google.protobuf.Timestamp
 	7,  // 6: proto.User.addresses:type_name -> proto.Address
-	20, // 7: proto.Profile.date_of_birth:type_name -> google.protobuf.Timestamp
-	18, // 8: proto.Profile.preferences:type_name -> proto.Profile.PreferencesEntry
-	2,  // 9: proto.Address.type:type_name -> proto.Address.AddressType
-	0,  // 10: proto.UserRole.role:type_name -> proto.Role
-	5,  // 11: proto.UpdateUserRequest.user:type_name -> proto.User
-	21, // 12: proto.UpdateUserRequest.update_mask:type_name -> google.protobuf.FieldMask
-	20, // 13: proto.ListUsersRequest.created_since:type_name -> google.protobuf.Timestamp
-	22, // 14: proto.ListUsersRequest.older_than:type_name -> google.protobuf.Duration
-	1,  // 15: proto.ListUsersRequest.status:type_name -> proto.UserStatus
-	20, // 16: proto.BatchAddUsersResponse.processed_at:type_name -> google.protobuf.Timestamp
-	3,  // 17: proto.UserActivity.activity_type:type_name -> proto.UserActivity.ActivityType
-	20, // 18: proto.UserActivity.timestamp:type_name -> google.protobuf.Timestamp
-	19, // 19: proto.UserActivity.details:type_name -> proto.UserActivity.DetailsEntry
-	20, // 20: proto.UserActivityResponse.processed_at:type_name -> google.protobuf.Timestamp
-	4,  // 21: proto.SyncUserResponse.status:type_name -> proto.SyncUserResponse.SyncStatus
-	5,  // 22: proto.UserService.AddUser:input_type -> proto.User
-	10, // 23: proto.UserService.ListUsers:input_type -> proto.ListUsersRequest
-	8,  // 24: proto.UserService.ListUsersByRole:input_type -> proto.UserRole
-	9,  // 25: proto.UserService.UpdateUser:input_type -> proto.UpdateUserRequest
-	11, // 26: proto.UserService.GetUser:input_type -> proto.GetUserRequest
-	12, // 27: proto.UserService.DeleteUser:input_type -> proto.DeleteUserRequest
-	5,  // 28: proto.UserService.BatchAddUsers:input_type -> proto.User
-	14, // 29: proto.UserService.UserActivityStream:input_type -> proto.UserActivity
-	5,  // 30: proto.UserService.SyncUsers:input_type -> proto.User
-	23, // 31: proto.UserService.AddUser:output_type -> google.protobuf.Empty
-	5,  // 32: proto.UserService.ListUsers:output_type -> proto.User
-	5,  // 33: proto.UserService.ListUsersByRole:output_type -> proto.User
-	5,  // 34: proto.UserService.UpdateUser:output_type -> proto.User
-	5,  // 35: proto.UserService.GetUser:output_type -> proto.User
-	23, // 36: proto.UserService.DeleteUser:output_type -> google.protobuf.Empty
-	13, // 37: proto.UserService.BatchAddUsers:output_type -> proto.BatchAddUsersResponse
-	15, // 38: proto.UserService.UserActivityStream:output_type -> proto.UserActivityResponse
-	16, // 39: proto.UserService.SyncUsers:output_type -> proto.SyncUserResponse
-	31, // [31:40] is the sub-list for method output_type
-	22, // [22:31] is the sub-list for method input_type
-	22, // [22:22] is the sub-list for extension type_name
-	22, // [22:22] is the sub-list for extension extendee
-	0,  // [0:22] is the sub-list for field type_name
+	31, // 7: proto.User.delete_time:type_name -> google.protobuf.Timestamp
+	31, // 8: proto.Profile.date_of_birth:type_name -> google.protobuf.Timestamp
+	28, // 9: proto.Profile.preferences:type_name -> proto.Profile.PreferencesEntry
+	2,  // 10: proto.Address.type:type_name -> proto.Address.AddressType
+	0,  // 11: proto.UserRole.role:type_name -> proto.Role
+	5,  // 12: proto.UpdateUserRequest.user:type_name -> proto.User
+	32, // 13: proto.UpdateUserRequest.update_mask:type_name -> google.protobuf.FieldMask
+	31, // 14: proto.ListUsersRequest.created_since:type_name -> google.protobuf.Timestamp
+	33, // 15: proto.ListUsersRequest.older_than:type_name -> google.protobuf.Duration
+	1,  // 16: proto.ListUsersRequest.status:type_name -> proto.UserStatus
+	29, // 17: proto.ListUsersRequest.metadata:type_name -> proto.ListUsersRequest.MetadataEntry
+	31, // 18: proto.GetUserRequest.as_of:type_name -> google.protobuf.Timestamp
+	5,  // 19: proto.AddUserResponse.user:type_name -> proto.User
+	31, // 20: proto.BatchAddUsersResponse.processed_at:type_name -> google.protobuf.Timestamp
+	17, // 21: proto.BatchAddUsersResponse.failures:type_name -> proto.BatchError
+	34, // 22: proto.BatchError.status:type_name -> google.rpc.Status
+	3,  // 23: proto.UserActivity.activity_type:type_name -> proto.UserActivity.ActivityType
+	31, // 24: proto.UserActivity.timestamp:type_name -> google.protobuf.Timestamp
+	30, // 25: proto.UserActivity.details:type_name -> proto.UserActivity.DetailsEntry
+	31, // 26: proto.UserActivityResponse.processed_at:type_name -> google.protobuf.Timestamp
+	4,  // 27: proto.SyncUserResponse.status:type_name -> proto.SyncUserResponse.SyncStatus
+	31, // 28: proto.AuditEvent.time:type_name -> google.protobuf.Timestamp
+	22, // 29: proto.AuditEvent.changes:type_name -> proto.FieldChange
+	35, // 30: proto.FieldChange.before:type_name -> google.protobuf.Value
+	35, // 31: proto.FieldChange.after:type_name -> google.protobuf.Value
+	31, // 32: proto.ListAuditEventsRequest.since:type_name -> google.protobuf.Timestamp
+	31, // 33: proto.ListAuditEventsRequest.until:type_name -> google.protobuf.Timestamp
+	31, // 34: proto.UserRevision.revision_time:type_name -> google.protobuf.Timestamp
+	5,  // 35: proto.UserRevision.user:type_name -> proto.User
+	36, // 36: proto.sensitive:extendee -> google.protobuf.FieldOptions
+	5,  // 37: proto.UserService.AddUser:input_type -> proto.User
+	10, // 38: proto.UserService.ListUsers:input_type -> proto.ListUsersRequest
+	8,  // 39: proto.UserService.ListUsersByRole:input_type -> proto.UserRole
+	9,  // 40: proto.UserService.UpdateUser:input_type -> proto.UpdateUserRequest
+	11, // 41: proto.UserService.GetUser:input_type -> proto.GetUserRequest
+	13, // 42: proto.UserService.GetUserByUsername:input_type -> proto.GetUserByUsernameRequest
+	25, // 43: proto.UserService.ListUserRevisions:input_type -> proto.ListUserRevisionsRequest
+	26, // 44: proto.UserService.RestoreUserRevision:input_type -> proto.RestoreUserRevisionRequest
+	14, // 45: proto.UserService.DeleteUser:input_type -> proto.DeleteUserRequest
+	15, // 46: proto.UserService.UndeleteUser:input_type -> proto.UndeleteUserRequest
+	5,  // 47: proto.UserService.BatchAddUsers:input_type -> proto.User
+	23, // 48: proto.UserService.ListAuditEvents:input_type -> proto.ListAuditEventsRequest
+	18, // 49: proto.UserService.UserActivityStream:input_type -> proto.UserActivity
+	5,  // 50: proto.UserService.SyncUsers:input_type -> proto.User
+	12, // 51: proto.UserService.AddUser:output_type -> proto.AddUserResponse
+	5,  // 52: proto.UserService.ListUsers:output_type -> proto.User
+	5,  // 53: proto.UserService.ListUsersByRole:output_type -> proto.User
+	5,  // 54: proto.UserService.UpdateUser:output_type -> proto.User
+	5,  // 55: proto.UserService.GetUser:output_type -> proto.User
+	5,  // 56: proto.UserService.GetUserByUsername:output_type -> proto.User
+	24, // 57: proto.UserService.ListUserRevisions:output_type -> proto.UserRevision
+	5,  // 58: proto.UserService.RestoreUserRevision:output_type -> proto.User
+	37, // 59: proto.UserService.DeleteUser:output_type -> google.protobuf.Empty
+	5,  // 60: proto.UserService.UndeleteUser:output_type -> proto.User
+	16, // 61: proto.UserService.BatchAddUsers:output_type -> proto.BatchAddUsersResponse
+	21, // 62: proto.UserService.ListAuditEvents:output_type -> proto.AuditEvent
+	19, // 63: proto.UserService.UserActivityStream:output_type -> proto.UserActivityResponse
+	20, // 64: proto.UserService.SyncUsers:output_type -> proto.SyncUserResponse
+	51, // [51:65] is the sub-list for method output_type
+	37, // [37:51] is the sub-list for method input_type
+	37, // [37:37] is the sub-list for extension type_name
+	36, // [36:37] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
 }
 
 func init() { file_example_proto_init() }
@@ -1377,20 +2319,25 @@ func file_example_proto_init() {
 	if File_example_proto != nil {
 		return
 	}
+	file_example_proto_msgTypes[6].OneofWrappers = []any{
+		(*GetUserRequest_AsOf)(nil),
+		(*GetUserRequest_Revision)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_example_proto_rawDesc), len(file_example_proto_rawDesc)),
 			NumEnums:      5,
-			NumMessages:   15,
-			NumExtensions: 0,
+			NumMessages:   26,
+			NumExtensions: 1,
 			NumServices:   1,
 		},
 		GoTypes:           file_example_proto_goTypes,
 		DependencyIndexes: file_example_proto_depIdxs,
 		EnumInfos:         file_example_proto_enumTypes,
 		MessageInfos:      file_example_proto_msgTypes,
+		ExtensionInfos:    file_example_proto_extTypes,
 	}.Build()
 	File_example_proto = out.File
 	file_example_proto_goTypes = nil
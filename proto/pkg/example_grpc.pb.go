@@ -24,15 +24,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UserService_AddUser_FullMethodName            = "/proto.UserService/AddUser"
-	UserService_ListUsers_FullMethodName          = "/proto.UserService/ListUsers"
-	UserService_ListUsersByRole_FullMethodName    = "/proto.UserService/ListUsersByRole"
-	UserService_UpdateUser_FullMethodName         = "/proto.UserService/UpdateUser"
-	UserService_GetUser_FullMethodName            = "/proto.UserService/GetUser"
-	UserService_DeleteUser_FullMethodName         = "/proto.UserService/DeleteUser"
-	UserService_BatchAddUsers_FullMethodName      = "/proto.UserService/BatchAddUsers"
-	UserService_UserActivityStream_FullMethodName = "/proto.UserService/UserActivityStream"
-	UserService_SyncUsers_FullMethodName          = "/proto.UserService/SyncUsers"
+	UserService_AddUser_FullMethodName             = "/proto.UserService/AddUser"
+	UserService_ListUsers_FullMethodName           = "/proto.UserService/ListUsers"
+	UserService_ListUsersByRole_FullMethodName     = "/proto.UserService/ListUsersByRole"
+	UserService_UpdateUser_FullMethodName          = "/proto.UserService/UpdateUser"
+	UserService_GetUser_FullMethodName             = "/proto.UserService/GetUser"
+	UserService_GetUserByUsername_FullMethodName   = "/proto.UserService/GetUserByUsername"
+	UserService_ListUserRevisions_FullMethodName   = "/proto.UserService/ListUserRevisions"
+	UserService_RestoreUserRevision_FullMethodName = "/proto.UserService/RestoreUserRevision"
+	UserService_DeleteUser_FullMethodName          = "/proto.UserService/DeleteUser"
+	UserService_UndeleteUser_FullMethodName        = "/proto.UserService/UndeleteUser"
+	UserService_BatchAddUsers_FullMethodName       = "/proto.UserService/BatchAddUsers"
+	UserService_ListAuditEvents_FullMethodName     = "/proto.UserService/ListAuditEvents"
+	UserService_UserActivityStream_FullMethodName  = "/proto.UserService/UserActivityStream"
+	UserService_SyncUsers_FullMethodName           = "/proto.UserService/SyncUsers"
 )
 
 // UserServiceClient is the client API for UserService service.
@@ -46,20 +51,35 @@ const (
 // - Bidirectional Streaming RPC
 type UserServiceClient interface {
 	// Unary RPC: Add a single user
-	AddUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// The server assigns an ID when the user's id is 0
+	AddUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*AddUserResponse, error)
 	// Server Streaming RPC: List users with filters
 	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[User], error)
 	// Server Streaming RPC: List users by role
 	ListUsersByRole(ctx context.Context, in *UserRole, opts ...grpc.CallOption) (grpc.ServerStreamingClient[User], error)
 	// Unary RPC: Update a user with field mask
 	UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error)
-	// Unary RPC: Get a single user by ID
+	// Unary RPC: Get a single user by ID, as it is now or as it was at a
+	// past time or revision
 	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	// Unary RPC: Get a single user by username, ignoring case
+	GetUserByUsername(ctx context.Context, in *GetUserByUsernameRequest, opts ...grpc.CallOption) (*User, error)
+	// Server Streaming RPC: List the stored revisions of a user, oldest first
+	ListUserRevisions(ctx context.Context, in *ListUserRevisionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UserRevision], error)
+	// Unary RPC: Make a past revision of a user current again, as a new revision
+	RestoreUserRevision(ctx context.Context, in *RestoreUserRevisionRequest, opts ...grpc.CallOption) (*User, error)
 	// Unary RPC: Delete a user
+	// The user is marked DELETED and purged after the retention window
 	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Unary RPC: Restore a deleted user that hasn't been purged yet
+	UndeleteUser(ctx context.Context, in *UndeleteUserRequest, opts ...grpc.CallOption) (*User, error)
 	// Client Streaming RPC: Batch add multiple users
-	// Client sends stream of users, server responds with summary
+	// Client sends stream of users, server responds with summary. With the
+	// request header batch-mode: atomic (Grpc-Metadata-Batch-Mode over REST)
+	// either every user is added or none are.
 	BatchAddUsers(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[User, BatchAddUsersResponse], error)
+	// Server Streaming RPC: List audit events, oldest first
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AuditEvent], error)
 	// Bidirectional Streaming RPC: User activity stream
 	// Both client and server send streams of messages
 	UserActivityStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UserActivity, UserActivityResponse], error)
@@ -76,9 +96,9 @@ func NewUserServiceClient(cc grpc.ClientConnInterface) UserServiceClient {
 	return &userServiceClient{cc}
 }
 
-func (c *userServiceClient) AddUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *userServiceClient) AddUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*AddUserResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(emptypb.Empty)
+	out := new(AddUserResponse)
 	err := c.cc.Invoke(ctx, UserService_AddUser_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -144,6 +164,45 @@ func (c *userServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opt
 	return out, nil
 }
 
+func (c *userServiceClient) GetUserByUsername(ctx context.Context, in *GetUserByUsernameRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_GetUserByUsername_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) ListUserRevisions(ctx context.Context, in *ListUserRevisionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UserRevision], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[2], UserService_ListUserRevisions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListUserRevisionsRequest, UserRevision]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_ListUserRevisionsClient = grpc.ServerStreamingClient[UserRevision]
+
+func (c *userServiceClient) RestoreUserRevision(ctx context.Context, in *RestoreUserRevisionRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_RestoreUserRevision_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -154,9 +213,19 @@ func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserReques
 	return out, nil
 }
 
+func (c *userServiceClient) UndeleteUser(ctx context.Context, in *UndeleteUserRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_UndeleteUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) BatchAddUsers(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[User, BatchAddUsersResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[2], UserService_BatchAddUsers_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[3], UserService_BatchAddUsers_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -167,9 +236,28 @@ func (c *userServiceClient) BatchAddUsers(ctx context.Context, opts ...grpc.Call
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type UserService_BatchAddUsersClient = grpc.ClientStreamingClient[User, BatchAddUsersResponse]
 
+func (c *userServiceClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AuditEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[4], UserService_ListAuditEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListAuditEventsRequest, AuditEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_ListAuditEventsClient = grpc.ServerStreamingClient[AuditEvent]
+
 func (c *userServiceClient) UserActivityStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UserActivity, UserActivityResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[3], UserService_UserActivityStream_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[5], UserService_UserActivityStream_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +270,7 @@ type UserService_UserActivityStreamClient = grpc.BidiStreamingClient[UserActivit
 
 func (c *userServiceClient) SyncUsers(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[User, SyncUserResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[4], UserService_SyncUsers_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[6], UserService_SyncUsers_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -204,20 +292,35 @@ type UserService_SyncUsersClient = grpc.BidiStreamingClient[User, SyncUserRespon
 // - Bidirectional Streaming RPC
 type UserServiceServer interface {
 	// Unary RPC: Add a single user
-	AddUser(context.Context, *User) (*emptypb.Empty, error)
+	// The server assigns an ID when the user's id is 0
+	AddUser(context.Context, *User) (*AddUserResponse, error)
 	// Server Streaming RPC: List users with filters
 	ListUsers(*ListUsersRequest, grpc.ServerStreamingServer[User]) error
 	// Server Streaming RPC: List users by role
 	ListUsersByRole(*UserRole, grpc.ServerStreamingServer[User]) error
 	// Unary RPC: Update a user with field mask
 	UpdateUser(context.Context, *UpdateUserRequest) (*User, error)
-	// Unary RPC: Get a single user by ID
+	// Unary RPC: Get a single user by ID, as it is now or as it was at a
+	// past time or revision
 	GetUser(context.Context, *GetUserRequest) (*User, error)
+	// Unary RPC: Get a single user by username, ignoring case
+	GetUserByUsername(context.Context, *GetUserByUsernameRequest) (*User, error)
+	// Server Streaming RPC: List the stored revisions of a user, oldest first
+	ListUserRevisions(*ListUserRevisionsRequest, grpc.ServerStreamingServer[UserRevision]) error
+	// Unary RPC: Make a past revision of a user current again, as a new revision
+	RestoreUserRevision(context.Context, *RestoreUserRevisionRequest) (*User, error)
 	// Unary RPC: Delete a user
+	// The user is marked DELETED and purged after the retention window
 	DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error)
+	// Unary RPC: Restore a deleted user that hasn't been purged yet
+	UndeleteUser(context.Context, *UndeleteUserRequest) (*User, error)
 	// Client Streaming RPC: Batch add multiple users
-	// Client sends stream of users, server responds with summary
+	// Client sends stream of users, server responds with summary. With the
+	// request header batch-mode: atomic (Grpc-Metadata-Batch-Mode over REST)
+	// either every user is added or none are.
 	BatchAddUsers(grpc.ClientStreamingServer[User, BatchAddUsersResponse]) error
+	// Server Streaming RPC: List audit events, oldest first
+	ListAuditEvents(*ListAuditEventsRequest, grpc.ServerStreamingServer[AuditEvent]) error
 	// Bidirectional Streaming RPC: User activity stream
 	// Both client and server send streams of messages
 	UserActivityStream(grpc.BidiStreamingServer[UserActivity, UserActivityResponse]) error
@@ -234,7 +337,7 @@ type UserServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedUserServiceServer struct{}
 
-func (UnimplementedUserServiceServer) AddUser(context.Context, *User) (*emptypb.Empty, error) {
+func (UnimplementedUserServiceServer) AddUser(context.Context, *User) (*AddUserResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddUser not implemented")
 }
 func (UnimplementedUserServiceServer) ListUsers(*ListUsersRequest, grpc.ServerStreamingServer[User]) error {
@@ -249,12 +352,27 @@ func (UnimplementedUserServiceServer) UpdateUser(context.Context, *UpdateUserReq
 func (UnimplementedUserServiceServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
 }
+func (UnimplementedUserServiceServer) GetUserByUsername(context.Context, *GetUserByUsernameRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserByUsername not implemented")
+}
+func (UnimplementedUserServiceServer) ListUserRevisions(*ListUserRevisionsRequest, grpc.ServerStreamingServer[UserRevision]) error {
+	return status.Errorf(codes.Unimplemented, "method ListUserRevisions not implemented")
+}
+func (UnimplementedUserServiceServer) RestoreUserRevision(context.Context, *RestoreUserRevisionRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreUserRevision not implemented")
+}
 func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
 }
+func (UnimplementedUserServiceServer) UndeleteUser(context.Context, *UndeleteUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UndeleteUser not implemented")
+}
 func (UnimplementedUserServiceServer) BatchAddUsers(grpc.ClientStreamingServer[User, BatchAddUsersResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method BatchAddUsers not implemented")
 }
+func (UnimplementedUserServiceServer) ListAuditEvents(*ListAuditEventsRequest, grpc.ServerStreamingServer[AuditEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
 func (UnimplementedUserServiceServer) UserActivityStream(grpc.BidiStreamingServer[UserActivity, UserActivityResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method UserActivityStream not implemented")
 }
@@ -358,6 +476,53 @@ func _UserService_GetUser_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_GetUserByUsername_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByUsernameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUserByUsername(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUserByUsername_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUserByUsername(ctx, req.(*GetUserByUsernameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ListUserRevisions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListUserRevisionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserServiceServer).ListUserRevisions(m, &grpc.GenericServerStream[ListUserRevisionsRequest, UserRevision]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_ListUserRevisionsServer = grpc.ServerStreamingServer[UserRevision]
+
+func _UserService_RestoreUserRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreUserRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RestoreUserRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RestoreUserRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RestoreUserRevision(ctx, req.(*RestoreUserRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteUserRequest)
 	if err := dec(in); err != nil {
@@ -376,6 +541,24 @@ func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_UndeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UndeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UndeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_UndeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UndeleteUser(ctx, req.(*UndeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_BatchAddUsers_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(UserServiceServer).BatchAddUsers(&grpc.GenericServerStream[User, BatchAddUsersResponse]{ServerStream: stream})
 }
@@ -383,6 +566,17 @@ func _UserService_BatchAddUsers_Handler(srv interface{}, stream grpc.ServerStrea
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type UserService_BatchAddUsersServer = grpc.ClientStreamingServer[User, BatchAddUsersResponse]
 
+func _UserService_ListAuditEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListAuditEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserServiceServer).ListAuditEvents(m, &grpc.GenericServerStream[ListAuditEventsRequest, AuditEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_ListAuditEventsServer = grpc.ServerStreamingServer[AuditEvent]
+
 func _UserService_UserActivityStream_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(UserServiceServer).UserActivityStream(&grpc.GenericServerStream[UserActivity, UserActivityResponse]{ServerStream: stream})
 }
@@ -416,10 +610,22 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUser",
 			Handler:    _UserService_GetUser_Handler,
 		},
+		{
+			MethodName: "GetUserByUsername",
+			Handler:    _UserService_GetUserByUsername_Handler,
+		},
+		{
+			MethodName: "RestoreUserRevision",
+			Handler:    _UserService_RestoreUserRevision_Handler,
+		},
 		{
 			MethodName: "DeleteUser",
 			Handler:    _UserService_DeleteUser_Handler,
 		},
+		{
+			MethodName: "UndeleteUser",
+			Handler:    _UserService_UndeleteUser_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -432,11 +638,21 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _UserService_ListUsersByRole_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ListUserRevisions",
+			Handler:       _UserService_ListUserRevisions_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "BatchAddUsers",
 			Handler:       _UserService_BatchAddUsers_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "ListAuditEvents",
+			Handler:       _UserService_ListAuditEvents_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "UserActivityStream",
 			Handler:       _UserService_UserActivityStream_Handler,
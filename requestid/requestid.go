@@ -0,0 +1,114 @@
+// Package requestid carries a per-request correlation ID across the HTTP gateway,
+// the gRPC server and the logs and spans they produce.
+//
+// The ID travels as the x-request-id HTTP header and gRPC metadata key. It is
+// accepted from the caller when present and generated otherwise.
+package requestid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Header is the HTTP header and gRPC metadata key holding the request ID
+	Header = "x-request-id"
+
+	// LogKey is the slog attribute key used for the request ID
+	LogKey = "request_id"
+
+	// SpanAttribute is the span attribute key used for the request ID
+	SpanAttribute = "request.id"
+)
+
+// contextKey is a custom type for context keys to avoid collisions
+type contextKey struct{}
+
+// validID limits accepted IDs to a sane charset and length so callers
+// can't inject arbitrary content into logs and headers
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// New generates a new request ID
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether id is acceptable as an incoming request ID
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// NewContext returns a copy of ctx carrying the given request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// Ensure returns the request ID to use for an incoming request: the given one
+// if it is valid, otherwise a freshly generated ID
+func Ensure(id string) string {
+	if Valid(id) {
+		return id
+	}
+	return New()
+}
+
+// Attribute returns the request ID as a span attribute
+func Attribute(id string) attribute.KeyValue {
+	return attribute.String(SpanAttribute, id)
+}
+
+// Middleware accepts or generates a request ID for every HTTP request,
+// stores it in the request context, tags the active span and echoes it
+// back in the response headers
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := Ensure(r.Header.Get(Header))
+
+		ctx := NewContext(r.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(Attribute(id))
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Handler is a slog.Handler that adds the request ID from the record's
+// context to every log record
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h so that records logged with a context carry its request ID
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+// Handle implements slog.Handler
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(LogKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
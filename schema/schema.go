@@ -0,0 +1,118 @@
+// Package schema serves the API's protobuf descriptors over HTTP so
+// dynamic clients can discover services, messages and enums at runtime.
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Content types served by Handler
+const (
+	ContentTypeBinary = "application/x-protobuf"
+	ContentTypeJSON   = "application/json"
+)
+
+// FileDescriptorSet returns files and everything they import, dependencies
+// first, as protoc --include_imports would write them
+func FileDescriptorSet(files ...protoreflect.FileDescriptor) *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := range imports.Len() {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range files {
+		add(fd)
+	}
+	return set
+}
+
+// Handler serves set in the binary wire format, or as protojson when the
+// client asks for JSON with an Accept header or ?format=json. authorize,
+// if not nil, decides whether a request may see the schema.
+func Handler(set *descriptorpb.FileDescriptorSet, authorize func(*http.Request) error) (http.Handler, error) {
+	binary, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal descriptors: %w", err)
+	}
+	json, err := protojson.MarshalOptions{Multiline: true}.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal descriptors as JSON: %w", err)
+	}
+	digest := sha256.Sum256(binary)
+	sum := hex.EncodeToString(digest[:8])
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if authorize != nil {
+			if err := authorize(r); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		body, contentType, etag := binary, ContentTypeBinary, `"`+sum+`"`
+		if wantsJSON(r) {
+			body, contentType, etag = json, ContentTypeJSON, `"`+sum+`-json"`
+		}
+
+		w.Header().Set("Vary", "Accept")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if contentType == ContentTypeBinary {
+			w.Header().Set("Content-Disposition", `attachment; filename="descriptors.binpb"`)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	}), nil
+}
+
+// wantsJSON reports whether the request prefers JSON to the binary format
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ContentTypeJSON:
+			return true
+		case ContentTypeBinary, "application/protobuf", "application/vnd.google.protobuf":
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestFileDescriptorSet(t *testing.T) {
+	set := FileDescriptorSet(pb.File_example_proto, pb.File_example_proto)
+
+	last := set.File[len(set.File)-1]
+	assert.Equal(t, "example.proto", last.GetName(), "dependencies come first")
+
+	// The set is self-contained
+	files, err := protodesc.NewFiles(set)
+	require.NoError(t, err)
+	desc, err := files.FindDescriptorByName("proto.Role")
+	require.NoError(t, err)
+	assert.NotNil(t, desc)
+}
+
+func TestHandler(t *testing.T) {
+	set := FileDescriptorSet(pb.File_example_proto)
+	h, err := Handler(set, nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentTypeBinary, rec.Header().Get("Content-Type"))
+	var got descriptorpb.FileDescriptorSet
+	require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got.File, len(set.File))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, ContentTypeJSON, rec.Header().Get("Content-Type"))
+	require.NoError(t, protojson.Unmarshal(rec.Body.Bytes(), &got))
+
+	req = httptest.NewRequest(http.MethodGet, "/?format=json", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestHandlerAuthorize(t *testing.T) {
+	h, err := Handler(FileDescriptorSet(pb.File_example_proto), func(r *http.Request) error {
+		if r.Header.Get("Authorization") == "" {
+			return errors.New("missing bearer token")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// batchStream feeds users to BatchAddUsers and keeps its response
+type batchStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	users    []*pb.User
+	response *pb.BatchAddUsersResponse
+}
+
+func (b *batchStream) Context() context.Context { return b.ctx }
+
+func (b *batchStream) Recv() (*pb.User, error) {
+	if len(b.users) == 0 {
+		return nil, io.EOF
+	}
+	user := b.users[0]
+	b.users = b.users[1:]
+	return user, nil
+}
+
+func (b *batchStream) SendAndClose(resp *pb.BatchAddUsersResponse) error {
+	b.response = resp
+	return nil
+}
+
+func TestBatchAddUsersAtomic(t *testing.T) {
+	storage := NewMemoryStorage()
+	require.NoError(t, storage.AddUser(context.Background(), &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN}))
+	srv := New(storage)
+
+	batch := func(mode string, users ...*pb.User) *pb.BatchAddUsersResponse {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(BatchModeHeader, mode))
+		stream := &batchStream{ctx: ctx, users: users}
+		require.NoError(t, srv.BatchAddUsers(stream))
+		return stream.response
+	}
+
+	resp := batch(BatchModeAtomic,
+		&pb.User{Id: 2, Username: "bob"},
+		&pb.User{Id: 1, Username: "dup"},
+		&pb.User{Id: 3},
+	)
+	assert.True(t, resp.RolledBack)
+	assert.Equal(t, int32(0), resp.TotalAdded)
+	require.Len(t, resp.Failures, 2)
+	assert.Equal(t, int32(2), resp.Failures[0].Index, "username checked before the transaction")
+	assert.Equal(t, int32(codes.InvalidArgument), resp.Failures[0].Status.Code)
+	assert.Equal(t, int32(1), resp.Failures[1].Index)
+	assert.Equal(t, int32(codes.AlreadyExists), resp.Failures[1].Status.Code)
+
+	count, err := storage.Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "user 2 was rolled back")
+
+	resp = batch(BatchModeAtomic, &pb.User{Id: 2, Username: "bob"}, &pb.User{Id: 3, Username: "carol"})
+	assert.False(t, resp.RolledBack)
+	assert.Equal(t, int32(2), resp.TotalAdded)
+
+	resp = batch("", &pb.User{Id: 4, Username: "dave"}, &pb.User{Id: 1, Username: "dup"})
+	assert.False(t, resp.RolledBack)
+	assert.Equal(t, int32(1), resp.TotalAdded)
+	assert.Equal(t, int32(1), resp.TotalFailed)
+}
+
+func TestMemoryStorageWithTx(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	require.NoError(t, storage.AddUser(ctx, &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN}))
+
+	errAbort := errors.New("abort")
+	err := storage.WithTx(ctx, func(tx Storage) error {
+		require.NoError(t, tx.AddUser(ctx, &pb.User{Id: 2, Username: "bob"}))
+		require.NoError(t, tx.DeleteUser(ctx, 1))
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	user, err := storage.GetUser(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, pb.UserStatus_ACTIVE, user.Status, "delete was rolled back")
+	exists, err := storage.UserExists(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	revisions, err := storage.ListUserRevisions(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+
+	require.NoError(t, storage.WithTx(ctx, func(tx Storage) error {
+		return tx.AddUser(ctx, &pb.User{Id: 2, Username: "bob"})
+	}))
+	exists, err = storage.UserExists(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
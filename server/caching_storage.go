@@ -0,0 +1,372 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+const cacheMeterName = "github.com/paulstuart/grpc-example/server/cache"
+
+// ChangeNotifier reports users changed outside this process, such as by
+// other servers sharing a database
+type ChangeNotifier interface {
+	// WatchChanges calls changed with the ID of each user updated or
+	// deleted until ctx is done, and with 0 when changes may have been missed
+	WatchChanges(ctx context.Context, changed func(id uint32))
+}
+
+// CachingStorage is a read-through cache in front of the wrapped Storage.
+// GetUser, GetUserByUsername and UserExists are answered from the most
+// recently used users, each kept for up to the TTL. Writes made through it
+// drop the users they change; Watch drops users changed elsewhere. Users are
+// copied into and out of the cache, so callers can't change cached ones.
+type CachingStorage struct {
+	Storage
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu sync.Mutex
+	// lru holds *cacheEntry, most recently used first
+	lru    *list.List
+	byID   map[uint32]*list.Element
+	byName map[string]*list.Element
+	// gen counts invalidations, so a read that overlapped one doesn't cache
+	// what may be an old user
+	gen uint64
+
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+}
+
+type cacheEntry struct {
+	user    *pb.User
+	expires time.Time
+}
+
+// Lookup attributes of the cache metrics
+var (
+	lookupByID       = metric.WithAttributes(attribute.String("lookup", "id"))
+	lookupByUsername = metric.WithAttributes(attribute.String("lookup", "username"))
+)
+
+// NewCachingStorage wraps storage with a cache of up to size users, each
+// kept for ttl
+func NewCachingStorage(storage Storage, size int, ttl time.Duration) (*CachingStorage, error) {
+	meter := otel.Meter(cacheMeterName)
+
+	hits, err := meter.Int64Counter(
+		"users.cache.hits",
+		metric.WithDescription("User lookups answered from the cache"),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	misses, err := meter.Int64Counter(
+		"users.cache.misses",
+		metric.WithDescription("User lookups passed to storage"),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	evictions, err := meter.Int64Counter(
+		"users.cache.evictions",
+		metric.WithDescription("Users dropped from the cache to make room"),
+		metric.WithUnit("{user}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CachingStorage{
+		Storage:   storage,
+		size:      size,
+		ttl:       ttl,
+		now:       time.Now,
+		lru:       list.New(),
+		byID:      make(map[uint32]*list.Element),
+		byName:    make(map[string]*list.Element),
+		hits:      hits,
+		misses:    misses,
+		evictions: evictions,
+	}, nil
+}
+
+// Watch drops users from the cache as notifier reports them changed, until
+// ctx is done
+func (c *CachingStorage) Watch(ctx context.Context, notifier ChangeNotifier) {
+	notifier.WatchChanges(ctx, func(id uint32) {
+		if id == 0 {
+			c.invalidateAll()
+			return
+		}
+		c.invalidate(id)
+	})
+}
+
+// GetUser retrieves a user by ID, from the cache if it's there
+func (c *CachingStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+	if user := c.get(ctx, id); user != nil {
+		return user, nil
+	}
+	return c.load(ctx, func() (*pb.User, error) { return c.Storage.GetUser(ctx, id) })
+}
+
+// GetUserByUsername retrieves a user by username, ignoring case, from the
+// cache if it's there
+func (c *CachingStorage) GetUserByUsername(ctx context.Context, username string) (*pb.User, error) {
+	if user := c.getByName(ctx, username); user != nil {
+		return user, nil
+	}
+	return c.load(ctx, func() (*pb.User, error) { return c.Storage.GetUserByUsername(ctx, username) })
+}
+
+// UserExists checks if a user exists, reading the user into the cache
+func (c *CachingStorage) UserExists(ctx context.Context, id uint32) (bool, error) {
+	if user := c.get(ctx, id); user != nil {
+		return true, nil
+	}
+	_, err := c.load(ctx, func() (*pb.User, error) { return c.Storage.GetUser(ctx, id) })
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddUser adds a user
+func (c *CachingStorage) AddUser(ctx context.Context, user *pb.User) error {
+	err := c.Storage.AddUser(ctx, user)
+	c.invalidate(user.GetId())
+	return err
+}
+
+// AddUsers adds users in bulk
+func (c *CachingStorage) AddUsers(ctx context.Context, users []*pb.User) ([]error, error) {
+	errs, err := c.Storage.AddUsers(ctx, users)
+	c.invalidate(userIDs(users)...)
+	return errs, err
+}
+
+// UpdateUser updates a user and drops it from the cache
+func (c *CachingStorage) UpdateUser(ctx context.Context, user *pb.User) error {
+	err := c.Storage.UpdateUser(ctx, user)
+	c.invalidate(user.GetId())
+	return err
+}
+
+// DeleteUser deletes a user and drops it from the cache
+func (c *CachingStorage) DeleteUser(ctx context.Context, id uint32) error {
+	err := c.Storage.DeleteUser(ctx, id)
+	c.invalidate(id)
+	return err
+}
+
+// UndeleteUser restores a deleted user and drops it from the cache
+func (c *CachingStorage) UndeleteUser(ctx context.Context, id uint32) (*pb.User, error) {
+	user, err := c.Storage.UndeleteUser(ctx, id)
+	c.invalidate(id)
+	return user, err
+}
+
+// PurgeDeleted removes users deleted before the given time and drops them
+// from the cache
+func (c *CachingStorage) PurgeDeleted(ctx context.Context, before time.Time) ([]*pb.User, error) {
+	users, err := c.Storage.PurgeDeleted(ctx, before)
+	c.invalidate(userIDs(users)...)
+	return users, err
+}
+
+// WithTx runs fn in a transaction of the wrapped storage. Reads in it skip
+// the cache; the users it writes are dropped from the cache when it ends.
+func (c *CachingStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	var ids []uint32
+	err := c.Storage.WithTx(ctx, func(tx Storage) error {
+		return fn(&txWrites{Storage: tx, ids: &ids})
+	})
+	c.invalidate(ids...)
+	return err
+}
+
+// get returns a copy of the cached user with the ID, or nil
+func (c *CachingStorage) get(ctx context.Context, id uint32) *pb.User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user := c.lookup(c.byID[id])
+	c.count(ctx, user != nil, lookupByID)
+	return user
+}
+
+// getByName returns a copy of the cached user with the username, ignoring
+// case, or nil
+func (c *CachingStorage) getByName(ctx context.Context, username string) *pb.User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user := c.lookup(c.byName[usernameKey(username)])
+	c.count(ctx, user != nil, lookupByUsername)
+	return user
+}
+
+// lookup returns a copy of the user in elem, if it hasn't expired, and
+// marks it most recently used; the caller holds the lock
+func (c *CachingStorage) lookup(elem *list.Element) *pb.User {
+	if elem == nil {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return cloneUser(entry.user)
+}
+
+func (c *CachingStorage) count(ctx context.Context, hit bool, lookup metric.AddOption) {
+	if hit {
+		c.hits.Add(ctx, 1, lookup)
+	} else {
+		c.misses.Add(ctx, 1, lookup)
+	}
+}
+
+// load reads a user from the wrapped storage and caches a copy of it,
+// unless the cache was invalidated meanwhile
+func (c *CachingStorage) load(ctx context.Context, read func() (*pb.User, error)) (*pb.User, error) {
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+
+	user, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.put(ctx, user)
+	}
+	return user, nil
+}
+
+// put caches a copy of user, evicting the least recently used users past
+// the size limit; the caller holds the lock
+func (c *CachingStorage) put(ctx context.Context, user *pb.User) {
+	if elem, ok := c.byID[user.GetId()]; ok {
+		c.remove(elem)
+	}
+	if elem, ok := c.byName[usernameKey(user.GetUsername())]; ok {
+		c.remove(elem)
+	}
+
+	elem := c.lru.PushFront(&cacheEntry{user: cloneUser(user), expires: c.now().Add(c.ttl)})
+	c.byID[user.GetId()] = elem
+	c.byName[usernameKey(user.GetUsername())] = elem
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(ctx, 1)
+	}
+}
+
+// remove drops the user in elem; the caller holds the lock
+func (c *CachingStorage) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.byID, entry.user.GetId())
+	delete(c.byName, usernameKey(entry.user.GetUsername()))
+}
+
+// invalidate drops the users with the IDs
+func (c *CachingStorage) invalidate(ids ...uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, id := range ids {
+		if elem, ok := c.byID[id]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// invalidateAll empties the cache
+func (c *CachingStorage) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.lru.Init()
+	clear(c.byID)
+	clear(c.byName)
+}
+
+// txWrites is the Storage of a CachingStorage transaction, recording the
+// users written so they can be dropped from the cache when it ends
+type txWrites struct {
+	Storage
+	ids *[]uint32
+}
+
+func (t *txWrites) AddUser(ctx context.Context, user *pb.User) error {
+	err := t.Storage.AddUser(ctx, user)
+	*t.ids = append(*t.ids, user.GetId())
+	return err
+}
+
+func (t *txWrites) AddUsers(ctx context.Context, users []*pb.User) ([]error, error) {
+	errs, err := t.Storage.AddUsers(ctx, users)
+	*t.ids = append(*t.ids, userIDs(users)...)
+	return errs, err
+}
+
+func (t *txWrites) UpdateUser(ctx context.Context, user *pb.User) error {
+	*t.ids = append(*t.ids, user.GetId())
+	return t.Storage.UpdateUser(ctx, user)
+}
+
+func (t *txWrites) DeleteUser(ctx context.Context, id uint32) error {
+	*t.ids = append(*t.ids, id)
+	return t.Storage.DeleteUser(ctx, id)
+}
+
+func (t *txWrites) UndeleteUser(ctx context.Context, id uint32) (*pb.User, error) {
+	*t.ids = append(*t.ids, id)
+	return t.Storage.UndeleteUser(ctx, id)
+}
+
+func (t *txWrites) PurgeDeleted(ctx context.Context, before time.Time) ([]*pb.User, error) {
+	users, err := t.Storage.PurgeDeleted(ctx, before)
+	*t.ids = append(*t.ids, userIDs(users)...)
+	return users, err
+}
+
+func (t *txWrites) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return t.Storage.WithTx(ctx, func(tx Storage) error {
+		return fn(&txWrites{Storage: tx, ids: t.ids})
+	})
+}
+
+func userIDs(users []*pb.User) []uint32 {
+	ids := make([]uint32, len(users))
+	for i, user := range users {
+		ids[i] = user.GetId()
+	}
+	return ids
+}
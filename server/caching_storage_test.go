@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// countingStorage counts the user lookups that reach the storage
+type countingStorage struct {
+	*MemoryStorage
+	reads int
+}
+
+func (s *countingStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+	s.reads++
+	return s.MemoryStorage.GetUser(ctx, id)
+}
+
+func (s *countingStorage) GetUserByUsername(ctx context.Context, username string) (*pb.User, error) {
+	s.reads++
+	return s.MemoryStorage.GetUserByUsername(ctx, username)
+}
+
+// notifier reports the changes sent on it
+type notifier chan uint32
+
+func (n notifier) WatchChanges(ctx context.Context, changed func(id uint32)) {
+	for id := range n {
+		changed(id)
+	}
+}
+
+func TestCachingStorage(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStorage{MemoryStorage: NewMemoryStorage()}
+	cache, err := NewCachingStorage(backend, 2, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	bob := &pb.User{Username: "bob", Email: "bob@example.com", Tags: []string{"staff"}}
+	require.NoError(t, cache.AddUser(ctx, bob))
+
+	user, err := cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.reads)
+
+	// Cached users are copies
+	user.Email = "mallory@example.com"
+	user.Tags[0] = "admin"
+	user.CreateDate.Seconds = 0
+	user, err = cache.GetUserByUsername(ctx, "BOB")
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.reads, "found by username in the cache")
+	assert.Equal(t, "bob@example.com", user.Email)
+	assert.Equal(t, []string{"staff"}, user.Tags)
+	assert.NotZero(t, user.CreateDate.Seconds)
+
+	exists, err := cache.UserExists(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = cache.UserExists(ctx, 99)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 2, backend.reads)
+
+	// Writes drop the user, including its old username
+	user.Username = "robert"
+	require.NoError(t, cache.UpdateUser(ctx, user))
+	_, err = cache.GetUserByUsername(ctx, "bob")
+	assert.Error(t, err)
+	user, err = cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "robert", user.Username)
+	assert.Equal(t, 4, backend.reads)
+
+	// Writes in a transaction drop the user when it ends
+	err = cache.WithTx(ctx, func(tx Storage) error {
+		return tx.DeleteUser(ctx, bob.Id)
+	})
+	require.NoError(t, err)
+	user, err = cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, pb.UserStatus_DELETED, user.Status)
+	assert.Equal(t, 5, backend.reads)
+
+	// Users expire after the TTL
+	now = now.Add(time.Minute)
+	_, err = cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 6, backend.reads)
+
+	// The least recently used user is evicted past the size
+	for _, name := range []string{"carol", "dave"} {
+		user := &pb.User{Username: name}
+		require.NoError(t, cache.AddUser(ctx, user))
+		_, err = cache.GetUser(ctx, user.Id)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 8, backend.reads)
+	_, err = cache.GetUserByUsername(ctx, "dave")
+	require.NoError(t, err)
+	_, err = cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 9, backend.reads)
+
+	// Changes made elsewhere are dropped when notified
+	changes := make(notifier)
+	done := make(chan struct{})
+	go func() {
+		cache.Watch(ctx, changes)
+		close(done)
+	}()
+	require.NoError(t, backend.MemoryStorage.UpdateUser(ctx, &pb.User{Id: bob.Id, Username: "bobby"}))
+	changes <- bob.Id
+	close(changes)
+	<-done
+	user, err = cache.GetUser(ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "bobby", user.Username)
+	assert.Equal(t, 10, backend.reads)
+}
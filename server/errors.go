@@ -0,0 +1,90 @@
+package server
+
+import (
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/paulstuart/grpc-example/validation"
+)
+
+// ErrorDomain is the ErrorInfo domain of errors raised by this service
+const ErrorDomain = validation.ErrorDomain
+
+// ErrorInfo reasons, stable identifiers clients can match on
+const (
+	ReasonInvalidField      = validation.ReasonInvalidField
+	ReasonFirstUserNotAdmin = "FIRST_USER_NOT_ADMIN"
+	ReasonUserNotFound      = "USER_NOT_FOUND"
+	ReasonUserExists        = "USER_ALREADY_EXISTS"
+	ReasonUsernameTaken     = "USERNAME_TAKEN"
+	ReasonNoUsersFound      = "NO_USERS_FOUND"
+	ReasonUserNotDeleted    = "USER_NOT_DELETED"
+	ReasonRevisionNotFound  = "REVISION_NOT_FOUND"
+)
+
+// withDetails attaches details to st, falling back to the bare status if
+// they can't be encoded
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	if detailed, err := st.WithDetails(details...); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
+
+// errorInfo returns a status error with an ErrorInfo detail
+func errorInfo(code codes.Code, reason, msg string, metadata map[string]string) error {
+	return withDetails(status.New(code, msg), &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+}
+
+// violation describes one invalid request field; field is the path to it,
+// e.g. "user.id"
+func violation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+// invalidArgument returns an INVALID_ARGUMENT error listing the violations
+func invalidArgument(reason string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	return validation.NewError(reason, violations...)
+}
+
+func userNotFound(id uint32) error {
+	return errorInfo(codes.NotFound, ReasonUserNotFound, "user not found",
+		map[string]string{"id": strconv.FormatUint(uint64(id), 10)})
+}
+
+func userExists(id uint32) error {
+	return errorInfo(codes.AlreadyExists, ReasonUserExists, "user already exists",
+		map[string]string{"id": strconv.FormatUint(uint64(id), 10)})
+}
+
+func userNotFoundByName(username string) error {
+	return errorInfo(codes.NotFound, ReasonUserNotFound, "user not found",
+		map[string]string{"username": username})
+}
+
+// usernameTaken reports a username already held by another user, compared
+// ignoring case
+func usernameTaken(username string) error {
+	return errorInfo(codes.AlreadyExists, ReasonUsernameTaken, "username already taken",
+		map[string]string{"username": username})
+}
+
+func userNotDeleted(id uint32) error {
+	return errorInfo(codes.FailedPrecondition, ReasonUserNotDeleted, "user is not deleted",
+		map[string]string{"id": strconv.FormatUint(uint64(id), 10)})
+}
+
+// revisionNotFound reports a missing revision of a user; metadata says
+// which revision or time was asked for
+func revisionNotFound(id uint32, metadata map[string]string) error {
+	metadata["id"] = strconv.FormatUint(uint64(id), 10)
+	return errorInfo(codes.NotFound, ReasonRevisionNotFound, "user revision not found", metadata)
+}
@@ -1,27 +1,37 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // MemoryStorage implements the Storage interface using in-memory storage
 type MemoryStorage struct {
-	mu    sync.RWMutex
-	users map[uint32]*pb.User
+	mu        sync.RWMutex
+	users     map[uint32]*pb.User
+	revisions map[uint32][]*pb.UserRevision
+	// usernames maps lowercased usernames to user IDs
+	usernames map[string]uint32
+	// lastID is the highest ID stored so far; new IDs follow it
+	lastID uint32
 }
 
 // NewMemoryStorage creates a new in-memory storage backend
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		users: make(map[uint32]*pb.User),
+		users:     make(map[uint32]*pb.User),
+		revisions: make(map[uint32][]*pb.UserRevision),
+		usernames: make(map[string]uint32),
 	}
 }
 
@@ -33,10 +43,19 @@ func (m *MemoryStorage) AddUser(ctx context.Context, user *pb.User) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	id := user.Id
+	if id == 0 {
+		id = m.lastID + 1
+	}
+
 	// Check if user already exists
-	if _, exists := m.users[user.Id]; exists {
-		return status.Error(codes.AlreadyExists, "user already exists")
+	if _, exists := m.users[id]; exists {
+		return userExists(id)
+	}
+	if err := m.checkUsername(id, user.Username); err != nil {
+		return err
 	}
+	user.Id = id
 
 	// Set create date if not provided
 	if user.CreateDate == nil {
@@ -50,10 +69,36 @@ func (m *MemoryStorage) AddUser(ctx context.Context, user *pb.User) error {
 
 	// Clone the user to avoid external modifications
 	m.users[user.Id] = cloneUser(user)
+	m.usernames[usernameKey(user.Username)] = user.Id
+	m.lastID = max(m.lastID, user.Id)
+	m.addRevision(user)
+
+	return nil
+}
 
+// checkUsername fails if another user than id holds username; the caller
+// holds the lock
+func (m *MemoryStorage) checkUsername(id uint32, username string) error {
+	if owner, taken := m.usernames[usernameKey(username)]; taken && owner != id {
+		return usernameTaken(username)
+	}
 	return nil
 }
 
+// usernameKey folds case so usernames are unique ignoring it
+func usernameKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// AddUsers adds users one at a time; memory has no round trips to save
+func (m *MemoryStorage) AddUsers(ctx context.Context, users []*pb.User) ([]error, error) {
+	errs := make([]error, len(users))
+	for i, user := range users {
+		errs[i] = m.AddUser(ctx, user)
+	}
+	return errs, nil
+}
+
 // GetUser retrieves a user by ID
 func (m *MemoryStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
 	m.mu.RLock()
@@ -61,38 +106,100 @@ func (m *MemoryStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error
 
 	user, exists := m.users[id]
 	if !exists {
-		return nil, status.Error(codes.NotFound, "user not found")
+		return nil, userNotFound(id)
 	}
 
 	return cloneUser(user), nil
 }
 
+// GetUserByUsername retrieves a user by username, ignoring case
+func (m *MemoryStorage) GetUserByUsername(ctx context.Context, username string) (*pb.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, exists := m.usernames[usernameKey(username)]
+	if !exists {
+		return nil, userNotFoundByName(username)
+	}
+
+	return cloneUser(m.users[id]), nil
+}
+
 // UpdateUser updates an existing user
 func (m *MemoryStorage) UpdateUser(ctx context.Context, user *pb.User) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.users[user.Id]; !exists {
-		return status.Error(codes.NotFound, "user not found")
+	existing, exists := m.users[user.Id]
+	if !exists {
+		return userNotFound(user.Id)
+	}
+	if err := m.checkUsername(user.Id, user.Username); err != nil {
+		return err
 	}
 
+	delete(m.usernames, usernameKey(existing.Username))
+	m.usernames[usernameKey(user.Username)] = user.Id
 	m.users[user.Id] = cloneUser(user)
+	m.addRevision(user)
 	return nil
 }
 
-// DeleteUser deletes a user by ID
+// DeleteUser marks a user deleted
 func (m *MemoryStorage) DeleteUser(ctx context.Context, id uint32) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.users[id]; !exists {
-		return status.Error(codes.NotFound, "user not found")
+	user, exists := m.users[id]
+	if !exists || user.Status == pb.UserStatus_DELETED {
+		return userNotFound(id)
 	}
 
-	delete(m.users, id)
+	user.Status = pb.UserStatus_DELETED
+	user.DeleteTime = timestamppb.New(time.Now())
+	m.addRevision(user)
 	return nil
 }
 
+// UndeleteUser restores a deleted user
+func (m *MemoryStorage) UndeleteUser(ctx context.Context, id uint32) (*pb.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[id]
+	if !exists {
+		return nil, userNotFound(id)
+	}
+	if user.Status != pb.UserStatus_DELETED {
+		return nil, userNotDeleted(id)
+	}
+
+	user.Status = pb.UserStatus_ACTIVE
+	user.DeleteTime = nil
+	m.addRevision(user)
+	return cloneUser(user), nil
+}
+
+// PurgeDeleted removes users deleted before the given time
+func (m *MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) ([]*pb.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged []*pb.User
+	for id, user := range m.users {
+		// Like Postgres, users without a delete time are never purged
+		if user.Status == pb.UserStatus_DELETED && user.DeleteTime != nil && user.DeleteTime.AsTime().Before(before) {
+			delete(m.users, id)
+			delete(m.revisions, id)
+			delete(m.usernames, usernameKey(user.Username))
+			purged = append(purged, user)
+		}
+	}
+	slices.SortFunc(purged, func(a, b *pb.User) int { return cmp.Compare(a.Id, b.Id) })
+
+	return purged, nil
+}
+
 // ListUsers lists all users with optional filters
 func (m *MemoryStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]*pb.User, error) {
 	m.mu.RLock()
@@ -101,6 +208,10 @@ func (m *MemoryStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]*p
 	var result []*pb.User
 
 	for _, user := range m.users {
+		if user.Status == pb.UserStatus_DELETED && filter.skipDeleted() {
+			continue
+		}
+
 		// Apply filters
 		if filter != nil {
 			if filter.CreatedSince != nil {
@@ -120,10 +231,15 @@ func (m *MemoryStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]*p
 			if filter.Status != nil && user.Status != *filter.Status {
 				continue
 			}
+
+			if !filter.matchAttributes(user) {
+				continue
+			}
 		}
 
 		result = append(result, cloneUser(user))
 	}
+	sortByID(result)
 
 	return result, nil
 }
@@ -140,10 +256,17 @@ func (m *MemoryStorage) ListUsersByRole(ctx context.Context, role pb.Role) ([]*p
 			result = append(result, cloneUser(user))
 		}
 	}
+	sortByID(result)
 
 	return result, nil
 }
 
+// sortByID orders users by ID, matching the Postgres storage, so that
+// clients can page or resume through a list
+func sortByID(users []*pb.User) {
+	slices.SortFunc(users, func(a, b *pb.User) int { return cmp.Compare(a.Id, b.Id) })
+}
+
 // UserExists checks if a user with the given ID exists
 func (m *MemoryStorage) UserExists(ctx context.Context, id uint32) (bool, error) {
 	m.mu.RLock()
@@ -161,6 +284,108 @@ func (m *MemoryStorage) Count(ctx context.Context) (int, error) {
 	return len(m.users), nil
 }
 
+// WithTx runs fn against a snapshot of the storage and keeps the snapshot
+// only if fn succeeds. Other calls wait until the transaction ends.
+func (m *MemoryStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.snapshot()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.users, m.revisions, m.usernames, m.lastID = tx.users, tx.revisions, tx.usernames, tx.lastID
+	return nil
+}
+
+// snapshot copies the storage; the caller holds the lock
+func (m *MemoryStorage) snapshot() *MemoryStorage {
+	snap := &MemoryStorage{
+		users:     make(map[uint32]*pb.User, len(m.users)),
+		revisions: make(map[uint32][]*pb.UserRevision, len(m.revisions)),
+		usernames: maps.Clone(m.usernames),
+		lastID:    m.lastID,
+	}
+	// Users are updated in place so they're copied, revisions never change
+	for id, user := range m.users {
+		snap.users[id] = cloneUser(user)
+	}
+	for id, revisions := range m.revisions {
+		snap.revisions[id] = slices.Clone(revisions)
+	}
+	return snap
+}
+
+// addRevision stores a copy of user as its next revision; the caller holds
+// the write lock
+func (m *MemoryStorage) addRevision(user *pb.User) {
+	revisions := m.revisions[user.Id]
+	m.revisions[user.Id] = append(revisions, &pb.UserRevision{
+		UserId:       user.Id,
+		Revision:     uint64(len(revisions) + 1),
+		RevisionTime: timestamppb.New(time.Now()),
+		User:         cloneUser(user),
+	})
+}
+
+// ListUserRevisions lists the revisions of a user
+func (m *MemoryStorage) ListUserRevisions(ctx context.Context, id uint32) ([]*pb.UserRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions, exists := m.revisions[id]
+	if !exists {
+		return nil, userNotFound(id)
+	}
+
+	result := make([]*pb.UserRevision, len(revisions))
+	for i, rev := range revisions {
+		result[i] = cloneRevision(rev)
+	}
+	return result, nil
+}
+
+// GetUserRevision retrieves one revision of a user
+func (m *MemoryStorage) GetUserRevision(ctx context.Context, id uint32, revision uint64) (*pb.UserRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := m.revisions[id]
+	if revision == 0 || revision > uint64(len(revisions)) {
+		return nil, revisionNotFound(id, map[string]string{"revision": strconv.FormatUint(revision, 10)})
+	}
+	return cloneRevision(revisions[revision-1]), nil
+}
+
+// GetUserAsOf retrieves the revision of a user current at the given time
+func (m *MemoryStorage) GetUserAsOf(ctx context.Context, id uint32, asOf time.Time) (*pb.UserRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := m.revisions[id]
+	// Revisions are in time order, find the last one written by asOf
+	i, _ := slices.BinarySearchFunc(revisions, asOf, func(rev *pb.UserRevision, t time.Time) int {
+		if rev.RevisionTime.AsTime().After(t) {
+			return 1
+		}
+		return -1
+	})
+	if i == 0 {
+		return nil, revisionNotFound(id, map[string]string{"as_of": asOf.Format(time.RFC3339Nano)})
+	}
+	return cloneRevision(revisions[i-1]), nil
+}
+
+func cloneRevision(rev *pb.UserRevision) *pb.UserRevision {
+	return &pb.UserRevision{
+		UserId:       rev.UserId,
+		Revision:     rev.Revision,
+		RevisionTime: rev.RevisionTime,
+		User:         cloneUser(rev.User),
+	}
+}
+
 // cloneUser creates a deep copy of a user
 func cloneUser(user *pb.User) *pb.User {
 	if user == nil {
@@ -170,20 +395,30 @@ func cloneUser(user *pb.User) *pb.User {
 	clone := &pb.User{
 		Id:         user.Id,
 		Role:       user.Role,
-		CreateDate: user.CreateDate,
+		CreateDate: cloneTimestamp(user.CreateDate),
 		Username:   user.Username,
+		Email:      user.Email,
+		Phone:      user.Phone,
 		Profile:    cloneProfile(user.Profile),
 		Tags:       append([]string{}, user.Tags...),
 		Metadata:   cloneMap(user.Metadata),
 		Status:     user.Status,
-		LastLogin:  user.LastLogin,
+		LastLogin:  cloneTimestamp(user.LastLogin),
 		Addresses:  cloneAddresses(user.Addresses),
+		DeleteTime: cloneTimestamp(user.DeleteTime),
 	}
 
-	// No oneof anymore - email and phone are separate fields (already copied above)
 	return clone
 }
 
+func cloneTimestamp(ts *timestamppb.Timestamp) *timestamppb.Timestamp {
+	if ts == nil {
+		return nil
+	}
+
+	return &timestamppb.Timestamp{Seconds: ts.Seconds, Nanos: ts.Nanos}
+}
+
 func cloneProfile(profile *pb.Profile) *pb.Profile {
 	if profile == nil {
 		return nil
@@ -193,7 +428,7 @@ func cloneProfile(profile *pb.Profile) *pb.Profile {
 		DisplayName:  profile.DisplayName,
 		Bio:          profile.Bio,
 		AvatarUrl:    profile.AvatarUrl,
-		DateOfBirth:  profile.DateOfBirth,
+		DateOfBirth:  cloneTimestamp(profile.DateOfBirth),
 		Preferences:  cloneIntMap(profile.Preferences),
 	}
 }
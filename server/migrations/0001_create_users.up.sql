@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(255) NOT NULL UNIQUE,
+	role INTEGER NOT NULL DEFAULT 0,
+	email VARCHAR(255),
+	phone VARCHAR(50),
+	display_name VARCHAR(255),
+	bio TEXT,
+	avatar_url TEXT,
+	date_of_birth TIMESTAMPTZ,
+	preferences JSONB,
+	tags TEXT[],
+	metadata JSONB,
+	status INTEGER NOT NULL DEFAULT 0,
+	create_date TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_login TIMESTAMPTZ,
+	addresses JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
+CREATE INDEX IF NOT EXISTS idx_users_create_date ON users(create_date);
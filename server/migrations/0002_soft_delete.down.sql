@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_delete_time;
+ALTER TABLE users DROP COLUMN IF EXISTS delete_time;
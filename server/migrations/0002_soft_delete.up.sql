@@ -0,0 +1,4 @@
+-- Deleted users keep their row, marked with the time they were deleted
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delete_time TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_delete_time ON users(delete_time) WHERE delete_time IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Every write to a user stores the whole user, as a serialized proto
+CREATE TABLE IF NOT EXISTS user_revisions (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	revision BIGINT NOT NULL,
+	revision_time TIMESTAMPTZ NOT NULL,
+	data BYTEA NOT NULL,
+	PRIMARY KEY (user_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_revisions_time ON user_revisions(user_id, revision_time);
@@ -0,0 +1,2 @@
+-- Usernames are unique ignoring case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users(lower(username));
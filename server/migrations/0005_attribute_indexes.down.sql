@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_metadata;
+DROP INDEX IF EXISTS idx_users_tags;
+DROP INDEX IF EXISTS idx_users_addresses;
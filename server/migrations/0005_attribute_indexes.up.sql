@@ -0,0 +1,4 @@
+-- Containment (@>) queries filter users on addresses, tags and metadata
+CREATE INDEX IF NOT EXISTS idx_users_addresses ON users USING GIN (addresses jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_users_tags ON users USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_users_metadata ON users USING GIN (metadata jsonb_path_ops);
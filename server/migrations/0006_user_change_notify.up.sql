@@ -0,0 +1,12 @@
+-- Notify listeners, such as user caches, of updated and deleted users.
+-- Inserts aren't notified since nothing can have cached a new user.
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('user_changes', OLD.id::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_notify_change ON users;
+CREATE TRIGGER users_notify_change AFTER UPDATE OR DELETE ON users
+	FOR EACH ROW EXECUTE FUNCTION notify_user_change();
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+	sequence BIGINT PRIMARY KEY,
+	time TIMESTAMPTZ NOT NULL,
+	actor TEXT NOT NULL,
+	method TEXT NOT NULL,
+	target_id BIGINT NOT NULL,
+	changes JSONB NOT NULL,
+	request_id TEXT NOT NULL,
+	prev_hash TEXT NOT NULL,
+	hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events(target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_events_time ON audit_events(time);
+
+-- Events can only be appended
+CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_immutable ON audit_events;
+CREATE TRIGGER audit_events_immutable
+	BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+// benchBatchSize is how many users each benchmark iteration adds
+const benchBatchSize = 1000
+
+// testPostgres connects to the scratch database in TEST_DATABASE_URL,
+// skipping the test or benchmark when it isn't set
+func testPostgres(tb testing.TB) *PostgresStorage {
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		tb.Skip("TEST_DATABASE_URL not set")
+	}
+	storage, err := NewPostgresStorage(context.Background(), connString)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(storage.Close)
+	return storage
+}
+
+func benchUsers(start int) []*pb.User {
+	users := make([]*pb.User, benchBatchSize)
+	for i := range users {
+		id := uint32(start + i + 1)
+		users[i] = &pb.User{Id: id, Username: fmt.Sprintf("user%d", id), Email: fmt.Sprintf("user%d@example.com", id)}
+	}
+	return users
+}
+
+func truncateUsers(tb testing.TB, storage *PostgresStorage) {
+	if _, err := storage.Pool().Exec(context.Background(), "TRUNCATE users CASCADE"); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// BenchmarkPostgresAddUser adds a batch one INSERT at a time, as
+// BatchAddUsers did before bulk writes
+func BenchmarkPostgresAddUser(b *testing.B) {
+	ctx := context.Background()
+	storage := testPostgres(b)
+	truncateUsers(b, storage)
+
+	for i := 0; b.Loop(); i++ {
+		for _, user := range benchUsers(i * benchBatchSize) {
+			if err := storage.AddUser(ctx, user); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*benchBatchSize)/b.Elapsed().Seconds(), "users/s")
+}
+
+// BenchmarkPostgresAddUsers adds a batch with COPY through the staging table
+func BenchmarkPostgresAddUsers(b *testing.B) {
+	ctx := context.Background()
+	storage := testPostgres(b)
+	truncateUsers(b, storage)
+
+	for i := 0; b.Loop(); i++ {
+		errs, err := storage.AddUsers(ctx, benchUsers(i*benchBatchSize))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, err := range errs {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*benchBatchSize)/b.Elapsed().Seconds(), "users/s")
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/paulstuart/grpc-example/migrate"
+)
+
+// migrationFiles holds the PostgresStorage schema, one numbered change per
+// file. Applied migrations must never be edited; add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the PostgresStorage schema migrations, oldest first
+func Migrations() ([]migrate.Migration, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Load(files)
+}
+
+// NewMigrator returns a migrator for the PostgresStorage schema of the
+// database pool connects to
+func NewMigrator(pool *pgxpool.Pool) (*migrate.Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(pool, migrations), nil
+}
@@ -1,32 +1,72 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/paulstuart/grpc-example/logging"
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
 )
 
 const (
 	postgresTracerName = "github.com/paulstuart/grpc-example/server/postgres"
+
+	// uniqueViolation is the SQLSTATE of a duplicate key
+	uniqueViolation = "23505"
+
+	// userChangesChannel is notified with the ID of each updated or deleted
+	// user, see migration 0006_user_change_notify
+	userChangesChannel = "user_changes"
+
+	// changeRetryDelay is how long WatchChanges waits to reconnect
+	changeRetryDelay = 5 * time.Second
 )
 
 // PostgresStorage implements Storage interface using PostgreSQL
 type PostgresStorage struct {
 	pool *pgxpool.Pool
+	// db runs statements: the pool, or the transaction of WithTx
+	db querier
 }
 
-// NewPostgresStorage creates a new PostgreSQL storage backend
-func NewPostgresStorage(ctx context.Context, connString string) (*PostgresStorage, error) {
+// PostgresOption configures NewPostgresStorage
+type PostgresOption func(*postgresOptions)
+
+type postgresOptions struct {
+	skipMigrations bool
+}
+
+// WithoutMigrations stops NewPostgresStorage applying pending schema
+// migrations; it fails instead if any are pending
+func WithoutMigrations() PostgresOption {
+	return func(o *postgresOptions) { o.skipMigrations = true }
+}
+
+// NewPostgresStorage creates a new PostgreSQL storage backend, first
+// migrating the database schema to the latest version
+func NewPostgresStorage(ctx context.Context, connString string, opts ...PostgresOption) (*PostgresStorage, error) {
+	var options postgresOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	tracer := otel.Tracer(postgresTracerName)
 	ctx, span := tracer.Start(ctx, "NewPostgresStorage")
 	span.SetAttributes(attribute.String("db.system", "postgresql"))
@@ -47,10 +87,10 @@ func NewPostgresStorage(ctx context.Context, connString string) (*PostgresStorag
 		return nil, fmt.Errorf("unable to connect to database: %w", err)
 	}
 
-	storage := &PostgresStorage{pool: pool}
+	storage := &PostgresStorage{pool: pool, db: pool}
 
-	// Initialize schema
-	if err := storage.initSchema(ctx); err != nil {
+	// Bring the schema up to date
+	if err := storage.migrateSchema(ctx, !options.skipMigrations); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to initialize schema")
 		pool.Close()
@@ -61,54 +101,113 @@ func NewPostgresStorage(ctx context.Context, connString string) (*PostgresStorag
 	return storage, nil
 }
 
-// initSchema creates the necessary tables
-func (s *PostgresStorage) initSchema(ctx context.Context) error {
+// migrateSchema refuses a schema newer than this server knows and, unless
+// disabled, applies pending migrations
+func (s *PostgresStorage) migrateSchema(ctx context.Context, autoMigrate bool) error {
 	tracer := otel.Tracer(postgresTracerName)
-	ctx, span := tracer.Start(ctx, "initSchema")
+	ctx, span := tracer.Start(ctx, "migrateSchema")
 	defer span.End()
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) NOT NULL UNIQUE,
-		role INTEGER NOT NULL DEFAULT 0,
-		email VARCHAR(255),
-		phone VARCHAR(50),
-		display_name VARCHAR(255),
-		bio TEXT,
-		avatar_url TEXT,
-		date_of_birth TIMESTAMPTZ,
-		preferences JSONB,
-		tags TEXT[],
-		metadata JSONB,
-		status INTEGER NOT NULL DEFAULT 0,
-		create_date TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		last_login TIMESTAMPTZ,
-		addresses JSONB
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-	CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
-	CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
-	CREATE INDEX IF NOT EXISTS idx_users_create_date ON users(create_date);
-	`
+	migrator, err := NewMigrator(s.pool)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to load migrations")
+		return err
+	}
 
-	_, err := s.pool.Exec(ctx, schema)
+	pending, err := migrator.Check(ctx)
+	if err == nil && pending > 0 {
+		if !autoMigrate {
+			err = fmt.Errorf("%d schema migrations are pending, apply them with the migrate up command", pending)
+		} else {
+			err = migrator.Up(ctx)
+		}
+	}
 	if err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to create schema")
+		span.SetStatus(codes.Error, "failed to migrate schema")
 		return err
 	}
 
-	span.SetStatus(codes.Ok, "Schema initialized")
+	span.SetAttributes(attribute.Int("migrations.applied", pending))
+	span.SetStatus(codes.Ok, "Schema up to date")
 	return nil
 }
 
+// Pool returns the connection pool, for other tables in the same database
+func (s *PostgresStorage) Pool() *pgxpool.Pool {
+	return s.pool
+}
+
 // Close closes the database connection pool
 func (s *PostgresStorage) Close() {
 	s.pool.Close()
 }
 
+// Ping verifies a connection to the database can be acquired and used
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "Ping")
+	defer span.End()
+
+	if err := s.pool.Ping(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Ping failed")
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// WatchChanges calls changed with the ID of each user updated or deleted,
+// through any server, until ctx is done. The notifications come from a
+// trigger on users; after connecting, including reconnecting when the
+// connection is lost, changed is called with 0 since changes may have been
+// missed.
+func (s *PostgresStorage) WatchChanges(ctx context.Context, changed func(id uint32)) {
+	for {
+		err := s.listenChanges(ctx, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.For("server").WarnContext(ctx, "lost user change notifications, reconnecting", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(changeRetryDelay):
+		}
+	}
+}
+
+// listenChanges listens for user changes on a connection taken from the
+// pool until it fails or ctx is done
+func (s *PostgresStorage) listenChanges(ctx context.Context, changed func(id uint32)) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is closed rather than returned to the pool, since it
+	// keeps listening
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+userChangesChannel); err != nil {
+		return err
+	}
+	changed(0)
+	for {
+		n, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(n.Payload, 10, 32)
+		if err != nil {
+			logging.For("server").WarnContext(ctx, "invalid user change notification", "payload", n.Payload)
+			continue
+		}
+		changed(uint32(id))
+	}
+}
+
 // AddUser adds a new user to storage
 func (s *PostgresStorage) AddUser(ctx context.Context, user *pb.User) error {
 	tracer := otel.Tracer(postgresTracerName)
@@ -120,26 +219,289 @@ func (s *PostgresStorage) AddUser(ctx context.Context, user *pb.User) error {
 	)
 	defer span.End()
 
+	// Take an ID from the users sequence, or move the sequence past the
+	// ID given so it won't hand that one out
+	assigned := user.Id == 0
+	if assigned {
+		id, err := nextUserID(ctx, s.db)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to assign user ID")
+			return err
+		}
+		user.Id = id
+		span.SetAttributes(attribute.Int("user.id", int(id)))
+	}
+
+	if user.CreateDate == nil {
+		user.CreateDate = timestamppb.New(time.Now().Truncate(time.Microsecond))
+	}
+
+	row, err := userRow(user)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to serialize user")
+		return err
+	}
+
+	query := `
+		INSERT INTO users (
+			id, username, role, email, phone,
+			display_name, bio, avatar_url, date_of_birth, preferences,
+			tags, metadata, status, create_date, last_login, addresses
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	err = s.withRevision(ctx, user.Id, span, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, row...); err != nil || assigned {
+			return err
+		}
+		return claimUserID(ctx, tx, user.Id)
+	})
+	if err != nil && assigned {
+		user.Id = 0
+	}
+
+	if usernameConflict(err) {
+		span.SetStatus(codes.Error, "username taken")
+		return usernameTaken(user.Username)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		span.SetStatus(codes.Error, "user already exists")
+		return userExists(user.Id)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to insert user")
+		return fmt.Errorf("failed to add user: %w", err)
+	}
+
+	span.SetStatus(codes.Ok, "User added")
+	return nil
+}
+
+// nextUserID takes the next ID from the users sequence
+func nextUserID(ctx context.Context, q querier) (uint32, error) {
+	var id int64
+	if err := q.QueryRow(ctx, `SELECT nextval('users_id_seq')`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to assign user ID: %w", err)
+	}
+	return uint32(id), nil
+}
+
+// claimUserID moves the users sequence past an ID chosen by a client, so
+// IDs assigned later don't collide with it
+func claimUserID(ctx context.Context, q querier, id uint32) error {
+	_, err := q.Exec(ctx, `
+		SELECT setval('users_id_seq', $1) FROM users_id_seq
+		WHERE last_value < $1 OR NOT is_called
+	`, int64(id))
+	if err != nil {
+		return fmt.Errorf("failed to advance user ID sequence: %w", err)
+	}
+	return nil
+}
+
+// usernameConflict reports whether err is a write that broke username
+// uniqueness
+func usernameConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation &&
+		(pgErr.ConstraintName == "users_username_key" || pgErr.ConstraintName == "idx_users_username_lower")
+}
+
+// AddUsers adds users in bulk. The users are copied into a staging table
+// with COPY and merged into users by a single INSERT, with their first
+// revisions copied the same way, so a batch costs a few round trips rather
+// than one per user. Users that conflict with an existing user, or repeat
+// an ID or username earlier in the batch, fail with ALREADY_EXISTS.
+func (s *PostgresStorage) AddUsers(ctx context.Context, users []*pb.User) ([]error, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "AddUsers")
+	span.SetAttributes(
+		attribute.String("db.operation", "COPY"),
+		attribute.String("db.table", "users"),
+		attribute.Int("batch.size", len(users)),
+	)
+	defer span.End()
+
+	errs := make([]error, len(users))
+	if len(users) == 0 {
+		return errs, nil
+	}
+	// Users without an ID get one from the sequence up front
+	var unassigned []int
+	for i, user := range users {
+		if user.Id == 0 {
+			unassigned = append(unassigned, i)
+		}
+	}
+	committed := false
+	if len(unassigned) > 0 {
+		ids, err := s.db.Query(ctx, `SELECT nextval('users_id_seq') FROM generate_series(1, $1)`, len(unassigned))
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign user IDs: %w", err)
+		}
+		assigned, err := pgx.CollectRows(ids, pgx.RowTo[int64])
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign user IDs: %w", err)
+		}
+		for n, i := range unassigned {
+			users[i].Id = uint32(assigned[n])
+		}
+		// Users that aren't added go back to having no ID
+		defer func() {
+			for _, i := range unassigned {
+				if !committed || errs[i] != nil {
+					users[i].Id = 0
+				}
+			}
+		}()
+	}
+
+	rows := make([][]any, 0, len(users))
+	batch := make(map[uint32]int, len(users))
+	names := make(map[string]bool, len(users))
+	var maxID uint32
+	now := time.Now().Truncate(time.Microsecond)
+	for i, user := range users {
+		if _, dup := batch[user.Id]; dup {
+			errs[i] = userExists(user.Id)
+			continue
+		}
+		if names[usernameKey(user.Username)] {
+			errs[i] = usernameTaken(user.Username)
+			continue
+		}
+		if user.CreateDate == nil {
+			user.CreateDate = timestamppb.New(now)
+		}
+		row, err := userRow(user)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batch[user.Id] = i
+		names[usernameKey(user.Username)] = true
+		maxID = max(maxID, user.Id)
+		rows = append(rows, row)
+	}
+
+	columns := strings.Join(userInsertColumns, ", ")
+	var added []uint32
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// The staging table has the users columns without their constraints
+		_, err := tx.Exec(ctx, `
+			DROP TABLE IF EXISTS users_staging;
+			CREATE TEMP TABLE users_staging (LIKE users INCLUDING DEFAULTS) ON COMMIT DROP;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create staging table: %w", err)
+		}
+
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"users_staging"}, userInsertColumns, pgx.CopyFromRows(rows)); err != nil {
+			return fmt.Errorf("failed to copy users: %w", err)
+		}
+
+		merged, err := tx.Query(ctx, `
+			INSERT INTO users (`+columns+`)
+			SELECT `+columns+` FROM users_staging
+			ON CONFLICT DO NOTHING
+			RETURNING id
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to merge users: %w", err)
+		}
+		added, err = pgx.CollectRows(merged, pgx.RowTo[uint32])
+		if err != nil {
+			return fmt.Errorf("failed to merge users: %w", err)
+		}
+		if err := claimUserID(ctx, tx, maxID); err != nil {
+			return err
+		}
+
+		// Rows the merge skipped conflicted with an existing user's ID or
+		// username
+		inserted := make(map[uint32]bool, len(added))
+		for _, id := range added {
+			inserted[id] = true
+		}
+		var skipped []int64
+		for id := range batch {
+			if !inserted[id] {
+				skipped = append(skipped, int64(id))
+			}
+		}
+		if len(skipped) > 0 {
+			taken, err := tx.Query(ctx, `SELECT id FROM users WHERE id = ANY($1)`, skipped)
+			if err != nil {
+				return fmt.Errorf("failed to check skipped users: %w", err)
+			}
+			existing, err := pgx.CollectRows(taken, pgx.RowTo[int64])
+			if err != nil {
+				return fmt.Errorf("failed to check skipped users: %w", err)
+			}
+			for _, id := range skipped {
+				i := batch[uint32(id)]
+				if slices.Contains(existing, id) {
+					errs[i] = userExists(uint32(id))
+				} else {
+					errs[i] = usernameTaken(users[i].Username)
+				}
+			}
+		}
+
+		revisions := make([][]any, 0, len(added))
+		for _, id := range added {
+			data, err := proto.Marshal(users[batch[id]])
+			if err != nil {
+				return fmt.Errorf("failed to serialize revision: %w", err)
+			}
+			revisions = append(revisions, []any{id, int64(1), now, data})
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"user_revisions"},
+			[]string{"user_id", "revision", "revision_time", "data"}, pgx.CopyFromRows(revisions))
+		if err != nil {
+			return fmt.Errorf("failed to copy revisions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to add users")
+		return nil, err
+	}
+	committed = true
+
+	span.SetAttributes(attribute.Int("result.count", len(added)))
+	span.SetStatus(codes.Ok, "Users added")
+	return errs, nil
+}
+
+// userInsertColumns are the users columns userRow fills, in order
+var userInsertColumns = []string{
+	"id", "username", "role", "email", "phone",
+	"display_name", "bio", "avatar_url", "date_of_birth", "preferences",
+	"tags", "metadata", "status", "create_date", "last_login", "addresses",
+}
+
+// userRow returns the values of userInsertColumns for user
+func userRow(user *pb.User) ([]any, error) {
 	// Serialize complex fields
 	preferencesJSON, err := serializePreferences(user.GetProfile().GetPreferences())
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to serialize preferences")
-		return fmt.Errorf("failed to serialize preferences: %w", err)
+		return nil, fmt.Errorf("failed to serialize preferences: %w", err)
 	}
 
 	metadataJSON, err := serializeMetadata(user.GetMetadata())
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to serialize metadata")
-		return fmt.Errorf("failed to serialize metadata: %w", err)
+		return nil, fmt.Errorf("failed to serialize metadata: %w", err)
 	}
 
 	addressesJSON, err := serializeAddresses(user.GetAddresses())
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to serialize addresses")
-		return fmt.Errorf("failed to serialize addresses: %w", err)
+		return nil, fmt.Errorf("failed to serialize addresses: %w", err)
 	}
 
 	profile := user.GetProfile()
@@ -155,35 +517,12 @@ func (s *PostgresStorage) AddUser(ctx context.Context, user *pb.User) error {
 		lastLogin = &ll
 	}
 
-	query := `
-		INSERT INTO users (
-			id, username, role, email, phone,
-			display_name, bio, avatar_url, date_of_birth, preferences,
-			tags, metadata, status, create_date, last_login, addresses
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-		ON CONFLICT (id) DO UPDATE SET
-			username = EXCLUDED.username,
-			role = EXCLUDED.role,
-			email = EXCLUDED.email,
-			phone = EXCLUDED.phone,
-			display_name = EXCLUDED.display_name,
-			bio = EXCLUDED.bio,
-			avatar_url = EXCLUDED.avatar_url,
-			date_of_birth = EXCLUDED.date_of_birth,
-			preferences = EXCLUDED.preferences,
-			tags = EXCLUDED.tags,
-			metadata = EXCLUDED.metadata,
-			status = EXCLUDED.status,
-			last_login = EXCLUDED.last_login,
-			addresses = EXCLUDED.addresses
-	`
-
-	_, err = s.pool.Exec(ctx, query,
+	return []any{
 		user.Id,
 		user.Username,
-		user.Role,
-		user.GetEmail(),      // Handle oneof
-		user.GetPhone(),      // Handle oneof
+		int32(user.Role),
+		user.GetEmail(),
+		user.GetPhone(),
 		profile.GetDisplayName(),
 		profile.GetBio(),
 		profile.GetAvatarUrl(),
@@ -191,60 +530,106 @@ func (s *PostgresStorage) AddUser(ctx context.Context, user *pb.User) error {
 		preferencesJSON,
 		user.Tags,
 		metadataJSON,
-		user.Status,
+		int32(user.Status),
 		user.CreateDate.AsTime(),
 		lastLogin,
 		addressesJSON,
+	}, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *PostgresStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "GetUser")
+	span.SetAttributes(
+		attribute.String("db.operation", "SELECT"),
+		attribute.String("db.table", "users"),
+		attribute.Int("user.id", int(id)),
 	)
+	defer span.End()
 
+	user, err := getUser(ctx, s.db, id, span)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to insert user")
-		return fmt.Errorf("failed to add user: %w", err)
+		return nil, err
 	}
 
-	span.SetStatus(codes.Ok, "User added")
-	return nil
+	span.SetStatus(codes.Ok, "User retrieved")
+	return user, nil
 }
 
-// GetUser retrieves a user by ID
-func (s *PostgresStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+// GetUserByUsername retrieves a user by username, ignoring case
+func (s *PostgresStorage) GetUserByUsername(ctx context.Context, username string) (*pb.User, error) {
 	tracer := otel.Tracer(postgresTracerName)
-	ctx, span := tracer.Start(ctx, "GetUser")
+	ctx, span := tracer.Start(ctx, "GetUserByUsername")
 	span.SetAttributes(
 		attribute.String("db.operation", "SELECT"),
 		attribute.String("db.table", "users"),
-		attribute.Int("user.id", int(id)),
+		attribute.String("user.username", username),
 	)
 	defer span.End()
 
-	query := `
-		SELECT id, username, role, email, phone,
+	row := s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE lower(username) = lower($1)`, username)
+	user, err := scanUser(row, span)
+	if err == pgx.ErrNoRows {
+		span.SetStatus(codes.Error, "user not found")
+		return nil, userNotFoundByName(username)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	span.SetStatus(codes.Ok, "User retrieved")
+	return user, nil
+}
+
+// querier runs statements on the pool or in a transaction; Begin starts a
+// transaction on the pool and a savepoint in a transaction
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// userColumns are the users columns scanUser reads, in order
+const userColumns = `id, username, role, email, phone,
 		       display_name, bio, avatar_url, date_of_birth, preferences,
-		       tags, metadata, status, create_date, last_login, addresses
-		FROM users WHERE id = $1
-	`
+		       tags, metadata, status, create_date, last_login, addresses, delete_time`
 
+// getUser reads a user with q, recording failures on span
+func getUser(ctx context.Context, q querier, id uint32, span trace.Span) (*pb.User, error) {
+	row := q.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	user, err := scanUser(row, span)
+	if err == pgx.ErrNoRows {
+		span.SetStatus(codes.Error, "user not found")
+		return nil, userNotFound(id)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// scanUser reads a row of userColumns. Stored JSON that can't be decoded is
+// recorded on span and left out of the user.
+func scanUser(row pgx.Row, span trace.Span) (*pb.User, error) {
 	var user pb.User
 	var email, phone, displayName, bio, avatarURL *string
-	var dateOfBirth, createDate, lastLogin *time.Time
+	var dateOfBirth, createDate, lastLogin, deleteTime *time.Time
 	var preferences, metadata, addresses []byte
 	var tags []string
 
-	err := s.pool.QueryRow(ctx, query, id).Scan(
+	err := row.Scan(
 		&user.Id, &user.Username, &user.Role, &email, &phone,
 		&displayName, &bio, &avatarURL, &dateOfBirth, &preferences,
-		&tags, &metadata, &user.Status, &createDate, &lastLogin, &addresses,
+		&tags, &metadata, &user.Status, &createDate, &lastLogin, &addresses, &deleteTime,
 	)
-
-	if err == pgx.ErrNoRows {
-		span.SetStatus(codes.Error, "user not found")
-		return nil, fmt.Errorf("user not found: %w", err)
-	}
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to query user")
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
 
 	// Populate contact info (no longer oneof)
@@ -293,6 +678,9 @@ func (s *PostgresStorage) GetUser(ctx context.Context, id uint32) (*pb.User, err
 	if lastLogin != nil {
 		user.LastLogin = timestamppb.New(*lastLogin)
 	}
+	if deleteTime != nil {
+		user.DeleteTime = timestamppb.New(*deleteTime)
+	}
 
 	// Populate addresses
 	if len(addresses) > 0 {
@@ -301,7 +689,6 @@ func (s *PostgresStorage) GetUser(ctx context.Context, id uint32) (*pb.User, err
 		}
 	}
 
-	span.SetStatus(codes.Ok, "User retrieved")
 	return &user, nil
 }
 
@@ -325,7 +712,7 @@ func (s *PostgresStorage) UpdateUser(ctx context.Context, user *pb.User) error {
 	}
 	if !exists {
 		span.SetStatus(codes.Error, "user not found")
-		return fmt.Errorf("user with ID %d not found", user.Id)
+		return userNotFound(user.Id)
 	}
 
 	// Serialize complex fields
@@ -360,33 +747,47 @@ func (s *PostgresStorage) UpdateUser(ctx context.Context, user *pb.User) error {
 		lastLogin = &ll
 	}
 
+	var deleteTime *time.Time
+	if user.DeleteTime != nil {
+		dt := user.DeleteTime.AsTime()
+		deleteTime = &dt
+	}
+
 	query := `
 		UPDATE users SET
 			username = $2, role = $3, email = $4, phone = $5,
 			display_name = $6, bio = $7, avatar_url = $8, date_of_birth = $9,
 			preferences = $10, tags = $11, metadata = $12, status = $13,
-			last_login = $14, addresses = $15
+			last_login = $14, addresses = $15, delete_time = $16
 		WHERE id = $1
 	`
 
-	_, err = s.pool.Exec(ctx, query,
-		user.Id,
-		user.Username,
-		user.Role,
-		user.GetEmail(),
-		user.GetPhone(),
-		profile.GetDisplayName(),
-		profile.GetBio(),
-		profile.GetAvatarUrl(),
-		dateOfBirth,
-		preferencesJSON,
-		user.Tags,
-		metadataJSON,
-		user.Status,
-		lastLogin,
-		addressesJSON,
-	)
+	err = s.withRevision(ctx, user.Id, span, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query,
+			user.Id,
+			user.Username,
+			user.Role,
+			user.GetEmail(),
+			user.GetPhone(),
+			profile.GetDisplayName(),
+			profile.GetBio(),
+			profile.GetAvatarUrl(),
+			dateOfBirth,
+			preferencesJSON,
+			user.Tags,
+			metadataJSON,
+			user.Status,
+			lastLogin,
+			addressesJSON,
+			deleteTime,
+		)
+		return err
+	})
 
+	if usernameConflict(err) {
+		span.SetStatus(codes.Error, "username taken")
+		return usernameTaken(user.Username)
+	}
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to update user")
@@ -397,34 +798,299 @@ func (s *PostgresStorage) UpdateUser(ctx context.Context, user *pb.User) error {
 	return nil
 }
 
-// DeleteUser deletes a user by ID
+// DeleteUser marks a user deleted
 func (s *PostgresStorage) DeleteUser(ctx context.Context, id uint32) error {
 	tracer := otel.Tracer(postgresTracerName)
 	ctx, span := tracer.Start(ctx, "DeleteUser")
 	span.SetAttributes(
-		attribute.String("db.operation", "DELETE"),
+		attribute.String("db.operation", "UPDATE"),
 		attribute.String("db.table", "users"),
 		attribute.Int("user.id", int(id)),
 	)
 	defer span.End()
 
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := s.pool.Exec(ctx, query, id)
+	query := `UPDATE users SET status = $2, delete_time = NOW() WHERE id = $1 AND status <> $2`
+	err := s.withRevision(ctx, id, span, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query, id, pb.UserStatus_DELETED)
+		if err == nil && result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		span.SetStatus(codes.Error, "user not found")
+		return userNotFound(id)
+	}
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to delete user")
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-		span.SetStatus(codes.Error, "user not found")
-		return fmt.Errorf("user with ID %d not found", id)
+	span.SetStatus(codes.Ok, "User deleted")
+	return nil
+}
+
+// UndeleteUser restores a deleted user
+func (s *PostgresStorage) UndeleteUser(ctx context.Context, id uint32) (*pb.User, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "UndeleteUser")
+	span.SetAttributes(
+		attribute.String("db.operation", "UPDATE"),
+		attribute.String("db.table", "users"),
+		attribute.Int("user.id", int(id)),
+	)
+	defer span.End()
+
+	query := `UPDATE users SET status = $2, delete_time = NULL WHERE id = $1 AND status = $3`
+	err := s.withRevision(ctx, id, span, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query, id, pb.UserStatus_ACTIVE, pb.UserStatus_DELETED)
+		if err == nil && result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to undelete user")
+		return nil, fmt.Errorf("failed to undelete user: %w", err)
 	}
 
-	span.SetStatus(codes.Ok, "User deleted")
+	if err != nil {
+		exists, err := s.UserExists(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if !exists {
+			span.SetStatus(codes.Error, "user not found")
+			return nil, userNotFound(id)
+		}
+		span.SetStatus(codes.Error, "user not deleted")
+		return nil, userNotDeleted(id)
+	}
+
+	span.SetStatus(codes.Ok, "User undeleted")
+	return s.GetUser(ctx, id)
+}
+
+// PurgeDeleted removes users deleted before the given time
+func (s *PostgresStorage) PurgeDeleted(ctx context.Context, before time.Time) ([]*pb.User, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "PurgeDeleted")
+	span.SetAttributes(
+		attribute.String("db.operation", "DELETE"),
+		attribute.String("db.table", "users"),
+	)
+	defer span.End()
+
+	query := `DELETE FROM users WHERE status = $1 AND delete_time < $2 RETURNING ` + userColumns
+	rows, err := s.db.Query(ctx, query, pb.UserStatus_DELETED, before)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to purge users")
+		return nil, fmt.Errorf("failed to purge users: %w", err)
+	}
+	defer rows.Close()
+
+	var purged []*pb.User
+	for rows.Next() {
+		user, err := scanUser(rows, span)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to purge users")
+			return nil, fmt.Errorf("failed to purge users: %w", err)
+		}
+		purged = append(purged, user)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to purge users")
+		return nil, fmt.Errorf("failed to purge users: %w", err)
+	}
+	slices.SortFunc(purged, func(a, b *pb.User) int { return cmp.Compare(a.Id, b.Id) })
+
+	span.SetAttributes(attribute.Int("result.count", len(purged)))
+	span.SetStatus(codes.Ok, "Users purged")
+	return purged, nil
+}
+
+// WithTx runs fn with a storage whose statements run in one transaction,
+// committed if fn returns nil and rolled back otherwise. Each write in the
+// transaction has its own savepoint, so a failed write doesn't abort the
+// others.
+func (s *PostgresStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	ctx, span := otel.Tracer(postgresTracerName).Start(ctx, "WithTx")
+	defer span.End()
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		return fn(&PostgresStorage{pool: s.pool, db: tx})
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "transaction rolled back")
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "Transaction committed")
 	return nil
 }
 
+// withRevision runs write in a transaction and stores the user it changed
+// as a new revision in the same transaction. Writes to a user lock its row,
+// so its revisions are numbered in order.
+func (s *PostgresStorage) withRevision(ctx context.Context, id uint32, span trace.Span, write func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+
+		user, err := getUser(ctx, tx, id, span)
+		if err != nil {
+			return err
+		}
+		data, err := proto.Marshal(user)
+		if err != nil {
+			return fmt.Errorf("failed to serialize revision: %w", err)
+		}
+
+		// Postgres keeps microseconds, so the time reads back unchanged
+		_, err = tx.Exec(ctx, `
+			INSERT INTO user_revisions (user_id, revision, revision_time, data)
+			SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3
+			FROM user_revisions WHERE user_id = $1
+		`, id, time.Now().Truncate(time.Microsecond), data)
+		if err != nil {
+			return fmt.Errorf("failed to add revision: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListUserRevisions lists the revisions of a user
+func (s *PostgresStorage) ListUserRevisions(ctx context.Context, id uint32) ([]*pb.UserRevision, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "ListUserRevisions")
+	span.SetAttributes(
+		attribute.String("db.operation", "SELECT"),
+		attribute.String("db.table", "user_revisions"),
+		attribute.Int("user.id", int(id)),
+	)
+	defer span.End()
+
+	query := `
+		SELECT revision, revision_time, data FROM user_revisions
+		WHERE user_id = $1 ORDER BY revision
+	`
+	rows, err := s.db.Query(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query revisions")
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*pb.UserRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows, id)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to read revision")
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	if len(revisions) == 0 {
+		span.SetStatus(codes.Error, "user not found")
+		return nil, userNotFound(id)
+	}
+
+	span.SetAttributes(attribute.Int("result.count", len(revisions)))
+	span.SetStatus(codes.Ok, "Revisions listed")
+	return revisions, nil
+}
+
+// GetUserRevision retrieves one revision of a user
+func (s *PostgresStorage) GetUserRevision(ctx context.Context, id uint32, revision uint64) (*pb.UserRevision, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "GetUserRevision")
+	span.SetAttributes(
+		attribute.String("db.operation", "SELECT"),
+		attribute.String("db.table", "user_revisions"),
+		attribute.Int("user.id", int(id)),
+		attribute.Int64("user.revision", int64(revision)),
+	)
+	defer span.End()
+
+	query := `
+		SELECT revision, revision_time, data FROM user_revisions
+		WHERE user_id = $1 AND revision = $2
+	`
+	rev, err := scanRevision(s.db.QueryRow(ctx, query, id, revision), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		span.SetStatus(codes.Error, "revision not found")
+		return nil, revisionNotFound(id, map[string]string{"revision": strconv.FormatUint(revision, 10)})
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query revision")
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "Revision retrieved")
+	return rev, nil
+}
+
+// GetUserAsOf retrieves the revision of a user current at the given time
+func (s *PostgresStorage) GetUserAsOf(ctx context.Context, id uint32, asOf time.Time) (*pb.UserRevision, error) {
+	tracer := otel.Tracer(postgresTracerName)
+	ctx, span := tracer.Start(ctx, "GetUserAsOf")
+	span.SetAttributes(
+		attribute.String("db.operation", "SELECT"),
+		attribute.String("db.table", "user_revisions"),
+		attribute.Int("user.id", int(id)),
+	)
+	defer span.End()
+
+	query := `
+		SELECT revision, revision_time, data FROM user_revisions
+		WHERE user_id = $1 AND revision_time <= $2
+		ORDER BY revision DESC LIMIT 1
+	`
+	rev, err := scanRevision(s.db.QueryRow(ctx, query, id, asOf), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		span.SetStatus(codes.Error, "revision not found")
+		return nil, revisionNotFound(id, map[string]string{"as_of": asOf.Format(time.RFC3339Nano)})
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query revision")
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "Revision retrieved")
+	return rev, nil
+}
+
+// scanRevision reads a row of revision, revision_time and data
+func scanRevision(row pgx.Row, id uint32) (*pb.UserRevision, error) {
+	rev := &pb.UserRevision{UserId: id, User: &pb.User{}}
+	var revisionTime time.Time
+	var data []byte
+	if err := row.Scan(&rev.Revision, &revisionTime, &data); err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(data, rev.User); err != nil {
+		return nil, fmt.Errorf("failed to deserialize revision %d: %w", rev.Revision, err)
+	}
+	rev.RevisionTime = timestamppb.New(revisionTime)
+	return rev, nil
+}
+
 // ListUsers lists all users with optional filters
 func (s *PostgresStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]*pb.User, error) {
 	tracer := otel.Tracer(postgresTracerName)
@@ -436,9 +1102,7 @@ func (s *PostgresStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]
 	defer span.End()
 
 	query := `
-		SELECT id, username, role, email, phone,
-		       display_name, bio, avatar_url, date_of_birth, preferences,
-		       tags, metadata, status, create_date, last_login, addresses
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE 1=1
 	`
@@ -461,16 +1125,45 @@ func (s *PostgresStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]
 			args = append(args, *filter.Status)
 			argIdx++
 		}
-		if filter.PageSize > 0 {
-			query += fmt.Sprintf(" LIMIT $%d", argIdx)
-			args = append(args, filter.PageSize)
+		// Containment tests, so the GIN indexes on these columns are used
+		if filter.City != "" || filter.Country != "" {
+			addr, err := json.Marshal([]*pb.Address{{City: filter.City, Country: filter.Country}})
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize address filter: %w", err)
+			}
+			query += fmt.Sprintf(" AND addresses @> $%d::jsonb", argIdx)
+			args = append(args, addr)
+			argIdx++
+		}
+		if len(filter.Tags) > 0 {
+			query += fmt.Sprintf(" AND tags @> $%d", argIdx)
+			args = append(args, filter.Tags)
+			argIdx++
+		}
+		if len(filter.Metadata) > 0 {
+			meta, err := json.Marshal(filter.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize metadata filter: %w", err)
+			}
+			query += fmt.Sprintf(" AND metadata @> $%d::jsonb", argIdx)
+			args = append(args, meta)
 			argIdx++
 		}
 	}
+	if filter.skipDeleted() {
+		query += fmt.Sprintf(" AND status <> $%d", argIdx)
+		args = append(args, pb.UserStatus_DELETED)
+		argIdx++
+	}
 
 	query += " ORDER BY id"
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	if filter != nil && filter.PageSize > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argIdx)
+		args = append(args, filter.PageSize)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to query users")
@@ -480,63 +1173,12 @@ func (s *PostgresStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]
 
 	users := []*pb.User{}
 	for rows.Next() {
-		var user pb.User
-		var email, phone, displayName, bio, avatarURL *string
-		var dateOfBirth, createDate, lastLogin *time.Time
-		var preferences, metadata, addresses []byte
-		var tags []string
-
-		err := rows.Scan(
-			&user.Id, &user.Username, &user.Role, &email, &phone,
-			&displayName, &bio, &avatarURL, &dateOfBirth, &preferences,
-			&tags, &metadata, &user.Status, &createDate, &lastLogin, &addresses,
-		)
+		user, err := scanUser(rows, span)
 		if err != nil {
 			span.RecordError(err)
 			continue
 		}
-
-		// Populate contact info (no longer oneof)
-		if email != nil {
-			user.Email = *email
-		}
-		if phone != nil {
-			user.Phone = *phone
-		}
-
-		profile := &pb.Profile{}
-		if displayName != nil {
-			profile.DisplayName = *displayName
-		}
-		if bio != nil {
-			profile.Bio = *bio
-		}
-		if avatarURL != nil {
-			profile.AvatarUrl = *avatarURL
-		}
-		if dateOfBirth != nil {
-			profile.DateOfBirth = timestamppb.New(*dateOfBirth)
-		}
-		if len(preferences) > 0 {
-			deserializePreferences(preferences, &profile.Preferences)
-		}
-		user.Profile = profile
-
-		user.Tags = tags
-		if len(metadata) > 0 {
-			deserializeMetadata(metadata, &user.Metadata)
-		}
-		if createDate != nil {
-			user.CreateDate = timestamppb.New(*createDate)
-		}
-		if lastLogin != nil {
-			user.LastLogin = timestamppb.New(*lastLogin)
-		}
-		if len(addresses) > 0 {
-			deserializeAddresses(addresses, &user.Addresses)
-		}
-
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	span.SetAttributes(attribute.Int("result.count", len(users)))
@@ -556,13 +1198,11 @@ func (s *PostgresStorage) ListUsersByRole(ctx context.Context, role pb.Role) ([]
 	defer span.End()
 
 	query := `
-		SELECT id, username, role, email, phone,
-		       display_name, bio, avatar_url, date_of_birth, preferences,
-		       tags, metadata, status, create_date, last_login, addresses
+		SELECT ` + userColumns + `
 		FROM users WHERE role = $1 ORDER BY id
 	`
 
-	rows, err := s.pool.Query(ctx, query, role)
+	rows, err := s.db.Query(ctx, query, role)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to query users by role")
@@ -572,63 +1212,12 @@ func (s *PostgresStorage) ListUsersByRole(ctx context.Context, role pb.Role) ([]
 
 	users := []*pb.User{}
 	for rows.Next() {
-		var user pb.User
-		var email, phone, displayName, bio, avatarURL *string
-		var dateOfBirth, createDate, lastLogin *time.Time
-		var preferences, metadata, addresses []byte
-		var tags []string
-
-		err := rows.Scan(
-			&user.Id, &user.Username, &user.Role, &email, &phone,
-			&displayName, &bio, &avatarURL, &dateOfBirth, &preferences,
-			&tags, &metadata, &user.Status, &createDate, &lastLogin, &addresses,
-		)
+		user, err := scanUser(rows, span)
 		if err != nil {
 			span.RecordError(err)
 			continue
 		}
-
-		// Populate contact info (no longer oneof)
-		if email != nil {
-			user.Email = *email
-		}
-		if phone != nil {
-			user.Phone = *phone
-		}
-
-		profile := &pb.Profile{}
-		if displayName != nil {
-			profile.DisplayName = *displayName
-		}
-		if bio != nil {
-			profile.Bio = *bio
-		}
-		if avatarURL != nil {
-			profile.AvatarUrl = *avatarURL
-		}
-		if dateOfBirth != nil {
-			profile.DateOfBirth = timestamppb.New(*dateOfBirth)
-		}
-		if len(preferences) > 0 {
-			deserializePreferences(preferences, &profile.Preferences)
-		}
-		user.Profile = profile
-
-		user.Tags = tags
-		if len(metadata) > 0 {
-			deserializeMetadata(metadata, &user.Metadata)
-		}
-		if createDate != nil {
-			user.CreateDate = timestamppb.New(*createDate)
-		}
-		if lastLogin != nil {
-			user.LastLogin = timestamppb.New(*lastLogin)
-		}
-		if len(addresses) > 0 {
-			deserializeAddresses(addresses, &user.Addresses)
-		}
-
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	span.SetAttributes(attribute.Int("result.count", len(users)))
@@ -649,7 +1238,7 @@ func (s *PostgresStorage) UserExists(ctx context.Context, id uint32) (bool, erro
 
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`
-	err := s.pool.QueryRow(ctx, query, id).Scan(&exists)
+	err := s.db.QueryRow(ctx, query, id).Scan(&exists)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to check user existence")
@@ -673,7 +1262,7 @@ func (s *PostgresStorage) Count(ctx context.Context) (int, error) {
 
 	var count int
 	query := `SELECT COUNT(*) FROM users`
-	err := s.pool.QueryRow(ctx, query).Scan(&count)
+	err := s.db.QueryRow(ctx, query).Scan(&count)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to count users")
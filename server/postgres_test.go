@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestPostgresAddUserExists(t *testing.T) {
+	ctx := context.Background()
+	storage := testPostgres(t)
+	truncateUsers(t, storage)
+
+	require.NoError(t, storage.AddUser(ctx, &pb.User{Id: 1, Username: "alice"}))
+	require.NoError(t, storage.DeleteUser(ctx, 1))
+
+	// An existing ID fails rather than overwriting the user, deleted or not
+	err := storage.AddUser(ctx, &pb.User{Id: 1, Username: "bob"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	user, err := storage.GetUser(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, pb.UserStatus_DELETED, user.Status)
+
+	revisions, err := storage.ListUserRevisions(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2, "no revision for the failed add")
+}
+
+func TestPostgresAddUsersConflicts(t *testing.T) {
+	ctx := context.Background()
+	storage := testPostgres(t)
+	truncateUsers(t, storage)
+
+	require.NoError(t, storage.AddUser(ctx, &pb.User{Id: 1, Username: "alice"}))
+
+	users := []*pb.User{
+		{Id: 1, Username: "new"},  // existing ID
+		{Username: "ALICE"},       // existing username, differing in case
+		{Id: 5, Username: "bob"},  // added
+		{Id: 5, Username: "bert"}, // ID repeated in the batch
+		{Username: "Bob"},         // username repeated in the batch
+		{Username: "carol"},       // added with an assigned ID
+	}
+	errs, err := storage.AddUsers(ctx, users)
+	require.NoError(t, err)
+	require.Len(t, errs, len(users))
+
+	for i, want := range []codes.Code{codes.AlreadyExists, codes.AlreadyExists, codes.OK, codes.AlreadyExists, codes.AlreadyExists, codes.OK} {
+		assert.Equal(t, want, status.Code(errs[i]), "user %d", i)
+	}
+	assert.Equal(t, ReasonUserExists, reason(errs[0]))
+	assert.Equal(t, ReasonUsernameTaken, reason(errs[1]))
+	assert.Equal(t, ReasonUserExists, reason(errs[3]))
+	assert.Equal(t, ReasonUsernameTaken, reason(errs[4]))
+
+	// Users that weren't added get their assigned IDs back
+	assert.Zero(t, users[1].Id)
+	assert.Zero(t, users[4].Id)
+	assert.NotZero(t, users[5].Id)
+
+	count, err := storage.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	user, err := storage.GetUser(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+}
+
+// reason returns the ErrorInfo reason of err, or ""
+func reason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// TestPostgresMigrations applies the schema migrations to a scratch schema,
+// undoes them all and applies them again
+func TestPostgresMigrations(t *testing.T) {
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	const schema = "server_migrations_test"
+
+	admin, err := pgxpool.New(ctx, connString)
+	require.NoError(t, err)
+	defer admin.Close()
+	_, err = admin.Exec(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE; CREATE SCHEMA `+schema)
+	require.NoError(t, err)
+	defer admin.Exec(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE`)
+
+	cfg, err := pgxpool.ParseConfig(connString)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	tables := []string{"users", "user_revisions", "audit_events", "idempotency_keys"}
+	exists := func(table string) bool {
+		var found bool
+		require.NoError(t, pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&found))
+		return found
+	}
+
+	m, err := NewMigrator(pool)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+	for _, table := range tables {
+		assert.True(t, exists(table), table)
+	}
+
+	// The audit log is append-only
+	_, err = pool.Exec(ctx, `INSERT INTO audit_events VALUES (1, now(), 'a', 'AddUser', 1, '[]', '', '', 'h')`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `UPDATE audit_events SET actor = 'b'`)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = pool.Exec(ctx, `DELETE FROM audit_events`)
+	assert.ErrorContains(t, err, "append-only")
+
+	require.NoError(t, m.To(ctx, 6))
+	assert.False(t, exists("audit_events"))
+	assert.False(t, exists("idempotency_keys"))
+	var functions int
+	require.NoError(t, pool.QueryRow(ctx, `
+		SELECT count(*) FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1 AND p.proname = 'audit_events_immutable'
+	`, schema).Scan(&functions))
+	assert.Zero(t, functions, "the down migration drops the trigger function")
+
+	require.NoError(t, m.To(ctx, 0))
+	for _, table := range tables {
+		assert.False(t, exists(table), table)
+	}
+
+	require.NoError(t, m.Up(ctx))
+	pending, err := m.Check(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
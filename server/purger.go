@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/paulstuart/grpc-example/audit"
+	"github.com/paulstuart/grpc-example/logging"
+)
+
+const purgerMeterName = "github.com/paulstuart/grpc-example/server/purger"
+
+// Purger permanently removes users that have been deleted for longer than
+// the retention window
+type Purger struct {
+	storage   Storage
+	audit     *audit.Log
+	retention time.Duration
+	interval  time.Duration
+
+	purged        metric.Int64Counter
+	runs          metric.Int64Counter
+	errors        metric.Int64Counter
+	auditFailures metric.Int64Counter
+}
+
+// NewPurger creates a purger that checks storage every interval for users
+// deleted more than retention ago, recording each purge in auditLog, which
+// may be nil
+func NewPurger(storage Storage, auditLog *audit.Log, retention, interval time.Duration) (*Purger, error) {
+	meter := otel.Meter(purgerMeterName)
+
+	purged, err := meter.Int64Counter(
+		"users.purged",
+		metric.WithDescription("Deleted users permanently removed by the purger"),
+		metric.WithUnit("{user}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := meter.Int64Counter(
+		"users.purge.runs",
+		metric.WithDescription("Purger runs"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	errors, err := meter.Int64Counter(
+		"users.purge.errors",
+		metric.WithDescription("Purger runs that failed"),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Purger{
+		storage:       storage,
+		audit:         auditLog,
+		retention:     retention,
+		interval:      interval,
+		purged:        purged,
+		runs:          runs,
+		errors:        errors,
+		auditFailures: auditFailureCounter(),
+	}, nil
+}
+
+// Run purges every interval until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(ctx, time.Now()); err != nil {
+			logging.For("server").ErrorContext(ctx, "failed to purge deleted users", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes users deleted before now minus the retention window and
+// returns their IDs
+func (p *Purger) Purge(ctx context.Context, now time.Time) ([]uint32, error) {
+	p.runs.Add(ctx, 1)
+	users, err := p.storage.PurgeDeleted(ctx, now.Add(-p.retention))
+	if err != nil {
+		p.errors.Add(ctx, 1)
+		return nil, err
+	}
+
+	p.purged.Add(ctx, int64(len(users)))
+	for _, user := range users {
+		recordAudit(ctx, p.audit, p.auditFailures, "PurgeDeleted", user.Id, user, nil)
+	}
+	return userIDs(users), nil
+}
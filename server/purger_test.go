@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/paulstuart/grpc-example/audit"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	srv := New(storage)
+
+	_, err := srv.AddUser(ctx, &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN})
+	require.NoError(t, err)
+	_, err = srv.AddUser(ctx, &pb.User{Id: 2, Username: "bob"})
+	require.NoError(t, err)
+
+	_, err = srv.DeleteUser(ctx, &pb.DeleteUserRequest{Id: 2})
+	require.NoError(t, err)
+
+	_, err = srv.GetUser(ctx, &pb.GetUserRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	deleted, err := srv.GetUser(ctx, &pb.GetUserRequest{Id: 2, ShowDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, pb.UserStatus_DELETED, deleted.Status)
+	assert.NotNil(t, deleted.DeleteTime)
+
+	users, err := storage.ListUsers(ctx, &ListFilter{})
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+	users, err = storage.ListUsers(ctx, &ListFilter{ShowDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	_, err = srv.DeleteUser(ctx, &pb.DeleteUserRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err), "deleting twice")
+
+	restored, err := srv.UndeleteUser(ctx, &pb.UndeleteUserRequest{Id: 2})
+	require.NoError(t, err)
+	assert.Equal(t, pb.UserStatus_ACTIVE, restored.Status)
+	assert.Nil(t, restored.DeleteTime)
+
+	_, err = srv.UndeleteUser(ctx, &pb.UndeleteUserRequest{Id: 2})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "undeleting an active user")
+
+	// Only DeleteUser deletes
+	_, err = srv.UpdateUser(ctx, &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 2, Status: pb.UserStatus_DELETED},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"status"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = srv.AddUser(ctx, &pb.User{Username: "carol", Status: pb.UserStatus_DELETED})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	stream := &batchStream{ctx: ctx, users: []*pb.User{
+		{Username: "dave", Status: pb.UserStatus_DELETED},
+		{Username: "erin"},
+	}}
+	require.NoError(t, srv.BatchAddUsers(stream))
+	assert.Equal(t, int32(1), stream.response.TotalAdded)
+	require.Len(t, stream.response.Failures, 1)
+	assert.Equal(t, int32(0), stream.response.Failures[0].Index)
+	assert.Equal(t, int32(codes.InvalidArgument), stream.response.Failures[0].Status.Code)
+
+	// A deleted user without a delete time, written by older servers, is
+	// never purged
+	require.NoError(t, storage.UpdateUser(ctx, &pb.User{Id: 2, Username: "bob", Status: pb.UserStatus_DELETED}))
+	purged, err := storage.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, purged)
+}
+
+func TestPurger(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	require.NoError(t, storage.AddUser(ctx, &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN}))
+	require.NoError(t, storage.AddUser(ctx, &pb.User{Id: 2, Username: "bob"}))
+	require.NoError(t, storage.DeleteUser(ctx, 2))
+
+	log := audit.New(audit.NewMemorySink(), audit.Config{})
+	purger, err := NewPurger(storage, log, time.Hour, time.Minute)
+	require.NoError(t, err)
+
+	ids, err := purger.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, ids, "still within retention")
+
+	ids, err = purger.Purge(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2}, ids)
+
+	// The audit event shows what was purged
+	events, err := log.List(ctx, &audit.Filter{Method: "PurgeDeleted"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint32(2), events[0].TargetId)
+	require.NotEmpty(t, events[0].Changes)
+	assert.Equal(t, "username", events[0].Changes[len(events[0].Changes)-1].Field)
+	assert.Equal(t, "bob", events[0].Changes[len(events[0].Changes)-1].Before.GetStringValue())
+
+	exists, err := storage.UserExists(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = storage.UserExists(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
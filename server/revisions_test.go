@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestUserRevisions(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	srv := New(storage)
+
+	_, err := srv.AddUser(ctx, &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN})
+	require.NoError(t, err)
+	added := time.Now()
+	time.Sleep(time.Millisecond)
+
+	_, err = srv.UpdateUser(ctx, &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 1, Username: "root"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"username"}},
+	})
+	require.NoError(t, err)
+
+	revisions, err := storage.ListUserRevisions(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, uint64(2), revisions[1].Revision)
+	assert.Equal(t, "root", revisions[1].User.Username)
+
+	user, err := srv.GetUser(ctx, &pb.GetUserRequest{Id: 1, Version: &pb.GetUserRequest_AsOf{AsOf: timestamppb.New(added)}})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", user.Username)
+
+	_, err = srv.GetUser(ctx, &pb.GetUserRequest{Id: 1, Version: &pb.GetUserRequest_Revision{Revision: 3}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	restored, err := srv.RestoreUserRevision(ctx, &pb.RestoreUserRevisionRequest{UserId: 1, Revision: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", restored.Username)
+
+	revisions, err = storage.ListUserRevisions(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3, "restoring adds a revision")
+	assert.Equal(t, "admin", revisions[2].User.Username)
+
+	user, err = srv.GetUser(ctx, &pb.GetUserRequest{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", user.Username)
+}
+
+func TestRestoreUserRevisionDeleted(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	srv := New(storage)
+
+	_, err := srv.AddUser(ctx, &pb.User{Id: 1, Username: "admin", Role: pb.Role_ADMIN})
+	require.NoError(t, err)
+	_, err = srv.AddUser(ctx, &pb.User{Id: 2, Username: "bob"})
+	require.NoError(t, err)
+	_, err = srv.DeleteUser(ctx, &pb.DeleteUserRequest{Id: 2})
+	require.NoError(t, err)
+
+	// Restoring an active revision doesn't bring back a deleted user
+	_, err = srv.RestoreUserRevision(ctx, &pb.RestoreUserRevisionRequest{UserId: 2, Revision: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Restoring a deleted revision doesn't delete the user again
+	_, err = srv.UndeleteUser(ctx, &pb.UndeleteUserRequest{Id: 2})
+	require.NoError(t, err)
+	_, err = srv.RestoreUserRevision(ctx, &pb.RestoreUserRevisionRequest{UserId: 2, Revision: 2})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	user, err := srv.GetUser(ctx, &pb.GetUserRequest{Id: 2})
+	require.NoError(t, err)
+	assert.Equal(t, pb.UserStatus_ACTIVE, user.Status)
+}
@@ -2,29 +2,93 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/paulstuart/grpc-example/audit"
+	"github.com/paulstuart/grpc-example/logging"
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/validation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// auditMeterName is the meter of the audit failures counter
+const auditMeterName = "github.com/paulstuart/grpc-example/server/audit"
+
 // Server implements the UserService gRPC server
 type Server struct {
 	pb.UnimplementedUserServiceServer
 	storage Storage
+	audit   *audit.Log
+
+	// auditFailures counts changes made without an audit event
+	auditFailures metric.Int64Counter
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAuditLog records changes to users in log instead of the default
+// in-memory audit log
+func WithAuditLog(log *audit.Log) Option {
+	return func(s *Server) {
+		s.audit = log
+	}
 }
 
 // New creates a new gRPC server with the given storage backend
-func New(storage Storage) *Server {
-	return &Server{
+func New(storage Storage, opts ...Option) *Server {
+	s := &Server{
 		storage: storage,
+		audit:   audit.New(audit.NewMemorySink(), audit.Config{}),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.auditFailures = auditFailureCounter()
+	return s
+}
+
+// auditFailureCounter returns the counter of changes made without an audit
+// event, or a no-op counter if it can't be created
+func auditFailureCounter() metric.Int64Counter {
+	counter, err := otel.Meter(auditMeterName).Int64Counter(
+		"audit.record.failures",
+		metric.WithDescription("Changes to users made without an audit event, since recording it failed"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		logging.For("server").Error("failed to create audit failures counter", "error", err)
+		return noop.Int64Counter{}
+	}
+	return counter
+}
+
+// recordAudit adds a change to log. The change has already been made, so a
+// failure to record it is logged and counted in failures rather than returned.
+func recordAudit(ctx context.Context, log *audit.Log, failures metric.Int64Counter, method string, id uint32, before, after proto.Message) {
+	if err := log.Record(ctx, method, id, before, after); err != nil {
+		failures.Add(ctx, 1, metric.WithAttributes(attribute.String("method", method)))
+		logging.For("server").ErrorContext(ctx, "failed to record audit event", "method", method, "user_id", id, "error", err)
+	}
+}
+
+// record adds a change to the audit log, see recordAudit
+func (s *Server) record(ctx context.Context, method string, id uint32, before, after proto.Message) {
+	recordAudit(ctx, s.audit, s.auditFailures, method, id, before, after)
 }
 
 // NewWithDefaultStorage creates a new gRPC server with in-memory storage
@@ -33,7 +97,7 @@ func NewWithDefaultStorage() *Server {
 }
 
 // AddUser implements the Unary RPC for adding a single user
-func (s *Server) AddUser(ctx context.Context, user *pb.User) (*emptypb.Empty, error) {
+func (s *Server) AddUser(ctx context.Context, user *pb.User) (*pb.AddUserResponse, error) {
 	// Validate first user must be admin
 	count, err := s.storage.Count(ctx)
 	if err != nil {
@@ -41,61 +105,148 @@ func (s *Server) AddUser(ctx context.Context, user *pb.User) (*emptypb.Empty, er
 	}
 
 	if count == 0 && user.Role != pb.Role_ADMIN {
-		return nil, status.Error(codes.InvalidArgument, "first user created must be an admin")
-	}
-
-	// Validate required fields
-	if user.Id == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user ID must be greater than 0")
+		return nil, invalidArgument(ReasonFirstUserNotAdmin, violation("role", "first user created must be an admin"))
 	}
 
+	// The proto rules check field formats, but can't require a username
+	// since partial users are valid in updates
 	if user.Username == "" {
-		return nil, status.Error(codes.InvalidArgument, "username is required")
+		return nil, invalidArgument(ReasonInvalidField, violation("username", "username is required"))
+	}
+	if err := notDeleting(user); err != nil {
+		return nil, err
 	}
 
+	// Storage assigns the ID if there isn't one and fills in defaults
 	err = s.storage.AddUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	s.record(ctx, "AddUser", user.Id, nil, user)
 
-	return &emptypb.Empty{}, nil
+	return &pb.AddUserResponse{User: user}, nil
+}
+
+// GetUserByUsername implements the Unary RPC for retrieving a user by
+// username, ignoring case
+func (s *Server) GetUserByUsername(ctx context.Context, req *pb.GetUserByUsernameRequest) (*pb.User, error) {
+	user, err := s.storage.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if isDeleted(user) && !req.ShowDeleted {
+		return nil, userNotFoundByName(req.Username)
+	}
+	return user, nil
 }
 
 // GetUser implements the Unary RPC for retrieving a user by ID
 func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
-	if req.Id == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user ID must be greater than 0")
+	var user *pb.User
+	switch version := req.Version.(type) {
+	case *pb.GetUserRequest_AsOf:
+		rev, err := s.storage.GetUserAsOf(ctx, req.Id, version.AsOf.AsTime())
+		if err != nil {
+			return nil, err
+		}
+		user = rev.User
+	case *pb.GetUserRequest_Revision:
+		rev, err := s.storage.GetUserRevision(ctx, req.Id, version.Revision)
+		if err != nil {
+			return nil, err
+		}
+		user = rev.User
+	default:
+		var err error
+		user, err = s.storage.GetUser(ctx, req.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isDeleted(user) && !req.ShowDeleted {
+		return nil, userNotFound(req.Id)
 	}
 
-	user, err := s.storage.GetUser(ctx, req.Id)
+	return user, nil
+}
+
+// ListUserRevisions implements the Server Streaming RPC for listing the
+// revisions of a user
+func (s *Server) ListUserRevisions(req *pb.ListUserRevisionsRequest, stream pb.UserService_ListUserRevisionsServer) error {
+	revisions, err := s.storage.ListUserRevisions(stream.Context(), req.UserId)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return user, nil
+	for _, rev := range revisions {
+		if err := stream.Send(rev); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// UpdateUser implements the Unary RPC for updating a user with field mask
-func (s *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
-	if req.User == nil {
-		return nil, status.Error(codes.InvalidArgument, "user is required")
+// RestoreUserRevision implements the Unary RPC for restoring a past revision
+// of a user. The restored user is written as a new revision, so the
+// revisions since stay in the history.
+func (s *Server) RestoreUserRevision(ctx context.Context, req *pb.RestoreUserRevisionRequest) (*pb.User, error) {
+	rev, err := s.storage.GetUserRevision(ctx, req.UserId, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+	// A purged user has no row left to restore into, and a deleted one must
+	// be undeleted first, since only DeleteUser and UndeleteUser change that
+	before, err := s.storage.GetUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if isDeleted(before) {
+		return nil, userNotFound(req.UserId)
+	}
+	if err := notDeleting(rev.User); err != nil {
+		return nil, err
 	}
 
-	if req.User.Id == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user ID must be greater than 0")
+	if err := s.storage.UpdateUser(ctx, rev.User); err != nil {
+		return nil, err
 	}
+	s.record(ctx, "RestoreUserRevision", req.UserId, before, rev.User)
+
+	return rev.User, nil
+}
+
+// isDeleted reports whether a user has been soft deleted
+func isDeleted(user *pb.User) bool {
+	return user.GetStatus() == pb.UserStatus_DELETED
+}
 
+// notDeleting rejects writes that would mark a user DELETED, which only
+// DeleteUser may do since it also stamps the delete time
+func notDeleting(user *pb.User) error {
+	if isDeleted(user) {
+		return invalidArgument(ReasonInvalidField, violation("user.status", "use DeleteUser to delete a user"))
+	}
+	return nil
+}
+
+// UpdateUser implements the Unary RPC for updating a user with field mask
+func (s *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
 	// Get existing user
 	existingUser, err := s.storage.GetUser(ctx, req.User.Id)
 	if err != nil {
 		return nil, err
 	}
+	if isDeleted(existingUser) {
+		return nil, userNotFound(req.User.Id)
+	}
+	before := proto.Clone(existingUser)
 
 	// Apply field mask if provided
 	if req.UpdateMask != nil && len(req.UpdateMask.Paths) > 0 {
 		for _, path := range req.UpdateMask.Paths {
 			if path == "id" {
-				return nil, status.Error(codes.InvalidArgument, "cannot update id field")
+				return nil, invalidArgument(ReasonInvalidField, violation("update_mask", "cannot update id field"))
 			}
 
 			switch path {
@@ -120,7 +271,7 @@ func (s *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb
 			case "addresses":
 				existingUser.Addresses = req.User.Addresses
 			default:
-				return nil, status.Errorf(codes.InvalidArgument, "invalid field path: %s", path)
+				return nil, invalidArgument(ReasonInvalidField, violation("update_mask", fmt.Sprintf("invalid field path: %s", path)))
 			}
 		}
 	} else {
@@ -136,29 +287,53 @@ func (s *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb
 		existingUser.LastLogin = req.User.LastLogin
 		existingUser.Addresses = req.User.Addresses
 	}
+	if err := notDeleting(existingUser); err != nil {
+		return nil, err
+	}
 
 	err = s.storage.UpdateUser(ctx, existingUser)
 	if err != nil {
 		return nil, err
 	}
+	s.record(ctx, "UpdateUser", existingUser.Id, before, existingUser)
 
 	return existingUser, nil
 }
 
 // DeleteUser implements the Unary RPC for deleting a user
 func (s *Server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
-	if req.Id == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user ID must be greater than 0")
+	before, err := s.storage.GetUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
 	}
-
-	err := s.storage.DeleteUser(ctx, req.Id)
+	err = s.storage.DeleteUser(ctx, req.Id)
 	if err != nil {
 		return nil, err
 	}
+	after, err := s.storage.GetUser(ctx, req.Id)
+	if err != nil {
+		after = nil
+	}
+	s.record(ctx, "DeleteUser", req.Id, before, after)
 
 	return &emptypb.Empty{}, nil
 }
 
+// UndeleteUser implements the Unary RPC for restoring a deleted user
+func (s *Server) UndeleteUser(ctx context.Context, req *pb.UndeleteUserRequest) (*pb.User, error) {
+	before, err := s.storage.GetUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.storage.UndeleteUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "UndeleteUser", req.Id, before, user)
+
+	return user, nil
+}
+
 // ListUsers implements the Server Streaming RPC for listing users with filters
 func (s *Server) ListUsers(req *pb.ListUsersRequest, stream pb.UserService_ListUsersServer) error {
 	filter := &ListFilter{}
@@ -181,6 +356,11 @@ func (s *Server) ListUsers(req *pb.ListUsersRequest, stream pb.UserService_ListU
 
 	filter.PageSize = req.PageSize
 	filter.PageToken = req.PageToken
+	filter.ShowDeleted = req.ShowDeleted
+	filter.City = req.City
+	filter.Country = req.Country
+	filter.Tags = req.Tags
+	filter.Metadata = req.Metadata
 
 	users, err := s.storage.ListUsers(stream.Context(), filter)
 	if err != nil {
@@ -188,7 +368,7 @@ func (s *Server) ListUsers(req *pb.ListUsersRequest, stream pb.UserService_ListU
 	}
 
 	if len(users) == 0 {
-		return status.Error(codes.NotFound, "no users found")
+		return errorInfo(codes.NotFound, ReasonNoUsersFound, "no users found", nil)
 	}
 
 	// Stream users to client
@@ -207,9 +387,13 @@ func (s *Server) ListUsersByRole(req *pb.UserRole, stream pb.UserService_ListUse
 	if err != nil {
 		return err
 	}
+	if !req.ShowDeleted {
+		users = slices.DeleteFunc(users, isDeleted)
+	}
 
 	if len(users) == 0 {
-		return status.Error(codes.NotFound, fmt.Sprintf("no users found with role %s", req.Role))
+		return errorInfo(codes.NotFound, ReasonNoUsersFound, fmt.Sprintf("no users found with role %s", req.Role),
+			map[string]string{"role": req.Role.String()})
 	}
 
 	// Stream users to client
@@ -222,52 +406,170 @@ func (s *Server) ListUsersByRole(req *pb.UserRole, stream pb.UserService_ListUse
 	return nil
 }
 
-// BatchAddUsers implements the Client Streaming RPC for batch adding users
+// BatchModeHeader is the request metadata key that selects how
+// BatchAddUsers treats failures
+const BatchModeHeader = "batch-mode"
+
+// BatchModeAtomic adds every user in a batch or, if any fails, none of them
+const BatchModeAtomic = "atomic"
+
+// maxAtomicBatch caps how many users an atomic batch holds before adding them
+const maxAtomicBatch = 10000
+
+// errBatchFailed rolls back an atomic batch
+var errBatchFailed = errors.New("batch had failures")
+
+// batchMode returns the mode requested in the metadata of ctx, if any
+func batchMode(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if mode := md.Get(BatchModeHeader); len(mode) > 0 {
+		return mode[0]
+	}
+	return ""
+}
+
+// batchFailed reports the user at index as not added
+func batchFailed(resp *pb.BatchAddUsersResponse, index int32, user *pb.User, err error) {
+	resp.TotalFailed++
+	resp.Failures = append(resp.Failures, &pb.BatchError{
+		Index:  index,
+		UserId: user.GetId(),
+		Status: status.Convert(err).Proto(),
+	})
+	resp.Errors = append(resp.Errors, fmt.Sprintf("user %d: %v", index+1, err))
+}
+
+// batchFlushSize is how many users BatchAddUsers buffers before writing
+// them to storage together
+const batchFlushSize = 1000
+
+// pendingUsers are batched users waiting to be written
+type pendingUsers struct {
+	users   []*pb.User
+	indexes []int32
+}
+
+func (p *pendingUsers) add(index int32, user *pb.User) {
+	p.users = append(p.users, user)
+	p.indexes = append(p.indexes, index)
+}
+
+// write adds the pending users to storage, reporting failures in resp, and
+// returns the users added
+func (p *pendingUsers) write(ctx context.Context, storage Storage, resp *pb.BatchAddUsersResponse) ([]*pb.User, error) {
+	errs, err := storage.AddUsers(ctx, p.users)
+	if err != nil {
+		return nil, err
+	}
+	var added []*pb.User
+	for i, user := range p.users {
+		if errs[i] != nil {
+			batchFailed(resp, p.indexes[i], user, errs[i])
+			continue
+		}
+		added = append(added, user)
+	}
+	p.users, p.indexes = nil, nil
+	return added, nil
+}
+
+// BatchAddUsers implements the Client Streaming RPC for batch adding users.
+// Users are buffered and written in bulk. By default each user succeeds or
+// fails on its own; in atomic mode the whole batch is held until the client
+// finishes and then written in one transaction.
 func (s *Server) BatchAddUsers(stream pb.UserService_BatchAddUsersServer) error {
-	var totalReceived, totalAdded, totalFailed int32
-	var errors []string
+	ctx := stream.Context()
+	atomic := batchMode(ctx) == BatchModeAtomic
+	response := &pb.BatchAddUsersResponse{}
+	var added []*pb.User
+	var pending pendingUsers
+
+	// flush writes the pending users outside a transaction; if the write
+	// fails as a whole, each of them fails with its error
+	flush := func() {
+		users, indexes := pending.users, pending.indexes
+		written, err := pending.write(ctx, s.storage, response)
+		if err != nil {
+			for i, user := range users {
+				batchFailed(response, indexes[i], user, err)
+			}
+			pending = pendingUsers{}
+			return
+		}
+		added = append(added, written...)
+	}
 
 	for {
 		user, err := stream.Recv()
 		if err == io.EOF {
 			// Client finished sending
-			response := &pb.BatchAddUsersResponse{
-				TotalReceived: totalReceived,
-				TotalAdded:    totalAdded,
-				TotalFailed:   totalFailed,
-				Errors:        errors,
-				ProcessedAt:   timestamppb.New(time.Now()),
-			}
-			return stream.SendAndClose(response)
+			break
+		}
+		index := response.TotalReceived
+		if verr, ok := validation.FromError(err); ok {
+			// One invalid user fails alone, the batch carries on
+			response.TotalReceived++
+			invalid, _ := verr.Message.(*pb.User)
+			batchFailed(response, index, invalid, verr)
+			continue
 		}
 		if err != nil {
 			return err
 		}
 
-		totalReceived++
+		response.TotalReceived++
 
-		// Validate and add user
-		if user.Id == 0 {
-			totalFailed++
-			errors = append(errors, fmt.Sprintf("user %d: ID must be greater than 0", totalReceived))
+		if user.Username == "" {
+			batchFailed(response, index, user, invalidArgument(ReasonInvalidField, violation("username", "username is required")))
 			continue
 		}
-
-		if user.Username == "" {
-			totalFailed++
-			errors = append(errors, fmt.Sprintf("user %d: username is required", totalReceived))
+		if err := notDeleting(user); err != nil {
+			batchFailed(response, index, user, err)
 			continue
 		}
 
-		err = s.storage.AddUser(stream.Context(), user)
-		if err != nil {
-			totalFailed++
-			errors = append(errors, fmt.Sprintf("user %d: %v", totalReceived, err))
-			continue
+		if atomic && len(pending.users) == maxAtomicBatch {
+			return status.Errorf(codes.ResourceExhausted, "atomic batches are limited to %d users", maxAtomicBatch)
+		}
+		pending.add(index, user)
+		if !atomic && len(pending.users) == batchFlushSize {
+			flush()
+		}
+	}
+
+	if !atomic {
+		flush()
+	} else {
+		// Every user is tried, so the response reports all failures, then
+		// the batch rolls back if there were any
+		var written []*pb.User
+		err := s.storage.WithTx(ctx, func(tx Storage) error {
+			var err error
+			written, err = pending.write(ctx, tx, response)
+			if err != nil {
+				return err
+			}
+			if response.TotalFailed > 0 {
+				return errBatchFailed
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, errBatchFailed):
+			response.RolledBack = true
+		case err != nil:
+			return err
+		default:
+			added = written
 		}
+	}
 
-		totalAdded++
+	for _, user := range added {
+		s.record(ctx, "BatchAddUsers", user.Id, nil, user)
 	}
+	response.TotalAdded = int32(len(added))
+	response.ProcessedAt = timestamppb.New(time.Now())
+	return stream.SendAndClose(response)
 }
 
 // UserActivityStream implements the Bidirectional Streaming RPC for user activity
@@ -277,15 +579,28 @@ func (s *Server) UserActivityStream(stream pb.UserService_UserActivityStreamServ
 		if err == io.EOF {
 			return nil
 		}
+		if verr, ok := validation.FromError(err); ok {
+			activity, _ := verr.Message.(*pb.UserActivity)
+			response := &pb.UserActivityResponse{
+				UserId:      activity.GetUserId(),
+				Message:     verr.Error(),
+				ProcessedAt: timestamppb.New(time.Now()),
+			}
+			if err := stream.Send(response); err != nil {
+				return err
+			}
+			continue
+		}
 		if err != nil {
 			return err
 		}
 
-		// Validate user exists
-		exists, err := s.storage.UserExists(stream.Context(), activity.UserId)
-		if err != nil {
+		// Validate user exists; deleted users can't record activity
+		user, err := s.storage.GetUser(stream.Context(), activity.UserId)
+		if err != nil && status.Code(err) != codes.NotFound {
 			return err
 		}
+		exists := err == nil && !isDeleted(user)
 
 		response := &pb.UserActivityResponse{
 			UserId:       activity.UserId,
@@ -300,12 +615,12 @@ func (s *Server) UserActivityStream(stream pb.UserService_UserActivityStreamServ
 
 			// Update last login for LOGIN activities
 			if activity.ActivityType == pb.UserActivity_LOGIN {
-				user, err := s.storage.GetUser(stream.Context(), activity.UserId)
-				if err == nil {
-					user.LastLogin = activity.Timestamp
-					if err := s.storage.UpdateUser(stream.Context(), user); err != nil {
-						slog.Error("failed to update last login", "user_id", activity.UserId, "error", err)
-					}
+				before := proto.Clone(user)
+				user.LastLogin = activity.Timestamp
+				if err := s.storage.UpdateUser(stream.Context(), user); err != nil {
+					logging.For("server").ErrorContext(stream.Context(), "failed to update last login", "user_id", activity.UserId, "error", err)
+				} else {
+					s.record(stream.Context(), "UserActivityStream", user.Id, before, user)
 				}
 			}
 		}
@@ -323,6 +638,18 @@ func (s *Server) SyncUsers(stream pb.UserService_SyncUsersServer) error {
 		if err == io.EOF {
 			return nil
 		}
+		if verr, ok := validation.FromError(err); ok {
+			user, _ := verr.Message.(*pb.User)
+			response := &pb.SyncUserResponse{
+				UserId:       user.GetId(),
+				Status:       pb.SyncUserResponse_FAILED,
+				ErrorMessage: verr.Error(),
+			}
+			if err := stream.Send(response); err != nil {
+				return err
+			}
+			continue
+		}
 		if err != nil {
 			return err
 		}
@@ -331,28 +658,33 @@ func (s *Server) SyncUsers(stream pb.UserService_SyncUsersServer) error {
 			UserId: user.Id,
 		}
 
-		// Validate user
-		if user.Id == 0 {
+		// Check if user exists; users without an ID are always new
+		existing, err := s.storage.GetUser(stream.Context(), user.Id)
+		if err != nil && status.Code(err) != codes.NotFound {
 			response.Status = pb.SyncUserResponse_FAILED
-			response.ErrorMessage = "user ID must be greater than 0"
+			response.ErrorMessage = err.Error()
 			if err := stream.Send(response); err != nil {
 				return err
 			}
 			continue
 		}
 
-		// Check if user exists
-		exists, err := s.storage.UserExists(stream.Context(), user.Id)
-		if err != nil {
+		// Deleted users can't be synced, like they can't be updated, and
+		// only DeleteUser deletes
+		rejected := notDeleting(user)
+		if err == nil && isDeleted(existing) {
+			rejected = userNotFound(user.Id)
+		}
+		if rejected != nil {
 			response.Status = pb.SyncUserResponse_FAILED
-			response.ErrorMessage = err.Error()
+			response.ErrorMessage = rejected.Error()
 			if err := stream.Send(response); err != nil {
 				return err
 			}
 			continue
 		}
 
-		if exists {
+		if err == nil {
 			// Update existing user
 			err = s.storage.UpdateUser(stream.Context(), user)
 			if err != nil {
@@ -361,6 +693,7 @@ func (s *Server) SyncUsers(stream pb.UserService_SyncUsersServer) error {
 			} else {
 				response.Status = pb.SyncUserResponse_SUCCESS
 				response.UpdatedFields = []string{"role", "username", "profile", "status"}
+				s.record(stream.Context(), "SyncUsers", user.Id, existing, user)
 			}
 		} else {
 			// Add new user
@@ -369,8 +702,10 @@ func (s *Server) SyncUsers(stream pb.UserService_SyncUsersServer) error {
 				response.Status = pb.SyncUserResponse_FAILED
 				response.ErrorMessage = err.Error()
 			} else {
+				response.UserId = user.Id
 				response.Status = pb.SyncUserResponse_SUCCESS
 				response.UpdatedFields = []string{"created"}
+				s.record(stream.Context(), "SyncUsers", user.Id, nil, user)
 			}
 		}
 
@@ -379,3 +714,33 @@ func (s *Server) SyncUsers(stream pb.UserService_SyncUsersServer) error {
 		}
 	}
 }
+
+// ListAuditEvents implements the Server Streaming RPC for listing audit events
+func (s *Server) ListAuditEvents(req *pb.ListAuditEventsRequest, stream pb.UserService_ListAuditEventsServer) error {
+	filter := &audit.Filter{
+		TargetID:      req.TargetId,
+		Actor:         req.Actor,
+		Method:        req.Method,
+		AfterSequence: req.AfterSequence,
+		Limit:         int(req.PageSize),
+	}
+	if req.Since != nil {
+		filter.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		filter.Until = req.Until.AsTime()
+	}
+
+	events, err := s.audit.List(stream.Context(), filter)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list audit events: %v", err)
+	}
+
+	for _, event := range events {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
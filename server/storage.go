@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"slices"
+	"time"
 
 	pb "github.com/paulstuart/grpc-example/proto/pkg"
 )
@@ -10,18 +12,35 @@ import (
 // This abstraction allows for multiple backend implementations
 // (e.g., in-memory, SQL database, NoSQL database, etc.)
 type Storage interface {
-	// AddUser adds a new user to storage
+	// AddUser adds a new user to storage. A user with ID 0 is assigned the
+	// next free ID, which is set on user. Usernames are unique, ignoring case.
 	AddUser(ctx context.Context, user *pb.User) error
 
+	// AddUsers adds users in bulk, assigning IDs like AddUser. errs[i] is
+	// why users[i] wasn't added, or nil; err is a failure of the whole
+	// write, when none were added.
+	AddUsers(ctx context.Context, users []*pb.User) (errs []error, err error)
+
 	// GetUser retrieves a user by ID
 	GetUser(ctx context.Context, id uint32) (*pb.User, error)
 
+	// GetUserByUsername retrieves a user by username, ignoring case
+	GetUserByUsername(ctx context.Context, username string) (*pb.User, error)
+
 	// UpdateUser updates an existing user
 	UpdateUser(ctx context.Context, user *pb.User) error
 
-	// DeleteUser deletes a user by ID
+	// DeleteUser marks a user DELETED and stamps its delete time; the
+	// user is kept until purged
 	DeleteUser(ctx context.Context, id uint32) error
 
+	// UndeleteUser makes a deleted user ACTIVE again
+	UndeleteUser(ctx context.Context, id uint32) (*pb.User, error)
+
+	// PurgeDeleted permanently removes users deleted before the given time
+	// and returns them as they were, in ID order
+	PurgeDeleted(ctx context.Context, before time.Time) ([]*pb.User, error)
+
 	// ListUsers lists all users with optional filters
 	ListUsers(ctx context.Context, filter *ListFilter) ([]*pb.User, error)
 
@@ -33,6 +52,22 @@ type Storage interface {
 
 	// Count returns the total number of users
 	Count(ctx context.Context) (int, error)
+
+	// ListUserRevisions lists the stored revisions of a user, oldest first.
+	// Every write to a user stores a revision; purging a user removes them.
+	ListUserRevisions(ctx context.Context, id uint32) ([]*pb.UserRevision, error)
+
+	// GetUserRevision retrieves one revision of a user
+	GetUserRevision(ctx context.Context, id uint32, revision uint64) (*pb.UserRevision, error)
+
+	// GetUserAsOf retrieves the revision of a user that was current at the
+	// given time
+	GetUserAsOf(ctx context.Context, id uint32, asOf time.Time) (*pb.UserRevision, error)
+
+	// WithTx runs fn with a Storage whose writes all take effect if fn
+	// returns nil, and none of them if it returns an error, which WithTx
+	// returns
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 }
 
 // ListFilter defines filters for listing users
@@ -42,4 +77,51 @@ type ListFilter struct {
 	Status       *pb.UserStatus
 	PageSize     int32
 	PageToken    string
+	// ShowDeleted includes deleted users, which are otherwise skipped
+	// unless Status asks for them
+	ShowDeleted bool
+	// City and Country, when set, match users with an address in them; with
+	// both, the same address must match
+	City    string
+	Country string
+	// Tags matches users having every tag
+	Tags []string
+	// Metadata matches users whose metadata holds every pair
+	Metadata map[string]string
+}
+
+// skipDeleted reports whether the filter hides deleted users
+func (f *ListFilter) skipDeleted() bool {
+	if f == nil {
+		return true
+	}
+	return !f.ShowDeleted && (f.Status == nil || *f.Status != pb.UserStatus_DELETED)
+}
+
+// matchAttributes reports whether user matches the filter's address, tag and
+// metadata conditions
+func (f *ListFilter) matchAttributes(user *pb.User) bool {
+	if f == nil {
+		return true
+	}
+	if (f.City != "" || f.Country != "") && !slices.ContainsFunc(user.GetAddresses(), f.matchAddress) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(user.GetTags(), tag) {
+			return false
+		}
+	}
+	for key, value := range f.Metadata {
+		if v, ok := user.GetMetadata()[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAddress reports whether addr is in the filter's city and country
+func (f *ListFilter) matchAddress(addr *pb.Address) bool {
+	return (f.City == "" || addr.GetCity() == f.City) &&
+		(f.Country == "" || addr.GetCountry() == f.Country)
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestAddUserAssignsIDs(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	srv := New(storage)
+
+	resp, err := srv.AddUser(ctx, &pb.User{Username: "admin", Role: pb.Role_ADMIN})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), resp.User.Id)
+	assert.Equal(t, pb.UserStatus_ACTIVE, resp.User.Status)
+	assert.NotNil(t, resp.User.CreateDate)
+
+	_, err = srv.AddUser(ctx, &pb.User{Id: 7, Username: "bob"})
+	require.NoError(t, err)
+	_, err = srv.AddUser(ctx, &pb.User{Id: 7, Username: "robert"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err), "existing IDs aren't overwritten")
+	resp, err = srv.AddUser(ctx, &pb.User{Username: "carol"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(8), resp.User.Id, "assigned IDs follow the highest one")
+
+	// Usernames are unique ignoring case
+	_, err = srv.AddUser(ctx, &pb.User{Username: "BOB"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = srv.UpdateUser(ctx, &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 8, Username: "Admin"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"username"}},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	user, err := srv.GetUserByUsername(ctx, &pb.GetUserByUsernameRequest{Username: "Carol"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(8), user.Id)
+
+	// Renaming frees the old username
+	_, err = srv.UpdateUser(ctx, &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 8, Username: "caroline"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"username"}},
+	})
+	require.NoError(t, err)
+	_, err = srv.GetUserByUsername(ctx, &pb.GetUserByUsernameRequest{Username: "carol"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = srv.AddUser(ctx, &pb.User{Username: "carol"})
+	require.NoError(t, err)
+
+	errs, err := storage.AddUsers(ctx, []*pb.User{{Username: "dave"}, {Username: "Dave"}})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, codes.AlreadyExists, status.Code(errs[1]))
+}
+
+func TestListUsersAttributeFilters(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	for _, user := range []*pb.User{
+		{Username: "ann", Tags: []string{"staff", "oncall"}, Metadata: map[string]string{"team": "infra"},
+			Addresses: []*pb.Address{{City: "Paris", Country: "FR"}, {City: "Portland", Country: "US"}}},
+		{Username: "ben", Tags: []string{"staff"}, Metadata: map[string]string{"team": "web"},
+			Addresses: []*pb.Address{{City: "Paris", Country: "US"}}},
+		{Username: "cat"},
+	} {
+		require.NoError(t, storage.AddUser(ctx, user))
+	}
+
+	usernames := func(filter *ListFilter) []string {
+		users, err := storage.ListUsers(ctx, filter)
+		require.NoError(t, err)
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"ann", "ben"}, usernames(&ListFilter{City: "Paris"}))
+	assert.Equal(t, []string{"ben"}, usernames(&ListFilter{City: "Paris", Country: "US"}),
+		"city and country match the same address")
+	assert.Equal(t, []string{"ann", "ben"}, usernames(&ListFilter{Country: "US"}))
+	assert.Equal(t, []string{"ann"}, usernames(&ListFilter{Tags: []string{"staff", "oncall"}}))
+	assert.Equal(t, []string{"ben"}, usernames(&ListFilter{Metadata: map[string]string{"team": "web"}}))
+	assert.Empty(t, usernames(&ListFilter{Tags: []string{"staff"}, Metadata: map[string]string{"team": "ops"}}))
+}
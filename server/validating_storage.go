@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+
+	"github.com/paulstuart/grpc-example/logging"
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/validation"
+)
+
+// ValidatingStorage checks users read from the wrapped Storage against the
+// proto validation rules and logs any that break them. It's a debugging aid
+// for finding rows written before a rule existed, or by other tools;
+// invalid users are still returned.
+type ValidatingStorage struct {
+	Storage
+}
+
+// NewValidatingStorage wraps storage to validate the users it returns
+func NewValidatingStorage(storage Storage) *ValidatingStorage {
+	return &ValidatingStorage{Storage: storage}
+}
+
+// GetUser retrieves a user by ID and validates it
+func (v *ValidatingStorage) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+	user, err := v.Storage.GetUser(ctx, id)
+	if err == nil {
+		v.check(ctx, user)
+	}
+	return user, err
+}
+
+// GetUserByUsername retrieves a user by username and validates it
+func (v *ValidatingStorage) GetUserByUsername(ctx context.Context, username string) (*pb.User, error) {
+	user, err := v.Storage.GetUserByUsername(ctx, username)
+	if err == nil {
+		v.check(ctx, user)
+	}
+	return user, err
+}
+
+// ListUsers lists users and validates each one
+func (v *ValidatingStorage) ListUsers(ctx context.Context, filter *ListFilter) ([]*pb.User, error) {
+	users, err := v.Storage.ListUsers(ctx, filter)
+	for _, user := range users {
+		v.check(ctx, user)
+	}
+	return users, err
+}
+
+// ListUsersByRole lists users by role and validates each one
+func (v *ValidatingStorage) ListUsersByRole(ctx context.Context, role pb.Role) ([]*pb.User, error) {
+	users, err := v.Storage.ListUsersByRole(ctx, role)
+	for _, user := range users {
+		v.check(ctx, user)
+	}
+	return users, err
+}
+
+// WithTx runs fn in a transaction of the wrapped storage, validating the
+// users read in it too
+func (v *ValidatingStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return v.Storage.WithTx(ctx, func(tx Storage) error {
+		return fn(NewValidatingStorage(tx))
+	})
+}
+
+func (v *ValidatingStorage) check(ctx context.Context, user *pb.User) {
+	if err := validation.Validate(user); err != nil {
+		logging.For("server").WarnContext(ctx, "stored user fails validation", "user_id", user.GetId(), "error", err)
+	}
+}
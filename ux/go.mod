@@ -4,7 +4,10 @@ go 1.23.0
 
 replace github.com/paulstuart/grpc-example => ../
 
-require go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.63.0
+require (
+	github.com/paulstuart/grpc-example v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.63.0
+)
 
 require (
 	github.com/felixge/httpsnoop v1.0.4 // indirect
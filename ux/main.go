@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/paulstuart/grpc-example/requestid"
 	"github.com/paulstuart/grpc-example/ux/client"
 	"github.com/paulstuart/grpc-example/ux/handlers"
 	"go.opentelemetry.io/otel"
@@ -125,7 +126,8 @@ func main() {
 		log.Printf("Warning: No JWT token provided, API requests may fail if authentication is required")
 	}
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	// Tag each request with an x-request-id so frontend logs can be joined with the gateway's
+	if err := http.ListenAndServe(addr, requestid.Middleware(mux)); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
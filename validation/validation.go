@@ -0,0 +1,97 @@
+// Package validation enforces the protovalidate rules declared on the API's
+// messages (the buf.validate options in example.proto) and reports
+// violations as INVALID_ARGUMENT errors with ErrorInfo and BadRequest details.
+package validation
+
+import (
+	"errors"
+	"strings"
+
+	"buf.build/go/protovalidate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrorDomain is the ErrorInfo domain of errors raised by this service
+const ErrorDomain = "grpc-example.paulstuart.github.io"
+
+// ReasonInvalidField is the ErrorInfo reason of a request with invalid fields
+const ReasonInvalidField = "INVALID_FIELD"
+
+// Error reports the invalid fields of a message. It converts to a gRPC
+// status, so handlers can return it as is.
+type Error struct {
+	// Message is the message that failed validation, if known
+	Message proto.Message
+	// Reason is the ErrorInfo reason, ReasonInvalidField if empty
+	Reason     string
+	Violations []*errdetails.BadRequest_FieldViolation
+}
+
+// NewError returns an Error for violations found outside the proto rules,
+// such as checks that need storage
+func NewError(reason string, violations ...*errdetails.BadRequest_FieldViolation) *Error {
+	return &Error{Reason: reason, Violations: violations}
+}
+
+// Error lists the violations as "field: description"
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		if v.Field == "" {
+			parts[i] = v.Description
+			continue
+		}
+		parts[i] = v.Field + ": " + v.Description
+	}
+	return strings.Join(parts, "; ")
+}
+
+// GRPCStatus implements the interface status.FromError looks for
+func (e *Error) GRPCStatus() *status.Status {
+	reason := e.Reason
+	if reason == "" {
+		reason = ReasonInvalidField
+	}
+	st := status.New(codes.InvalidArgument, e.Error())
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain},
+		&errdetails.BadRequest{FieldViolations: e.Violations},
+	)
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// Validate checks msg against its rules, returning nil or an *Error. A rule
+// that can't be evaluated is reported as an INTERNAL error.
+func Validate(msg proto.Message) error {
+	err := protovalidate.Validate(msg)
+	if err == nil {
+		return nil
+	}
+	var verr *protovalidate.ValidationError
+	if !errors.As(err, &verr) {
+		return status.Errorf(codes.Internal, "validate %s: %v", msg.ProtoReflect().Descriptor().FullName(), err)
+	}
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(verr.Violations))
+	for i, v := range verr.Violations {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       protovalidate.FieldPathString(v.Proto.GetField()),
+			Description: v.Proto.GetMessage(),
+			Reason:      v.Proto.GetRuleId(),
+		}
+	}
+	return &Error{Message: msg, Violations: violations}
+}
+
+// FromError returns the *Error in err's chain, if any. Streaming handlers use
+// it to reject one invalid message and carry on receiving.
+func FromError(err error) (*Error, bool) {
+	var verr *Error
+	ok := errors.As(err, &verr)
+	return verr, ok
+}
@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+)
+
+func TestValidate(t *testing.T) {
+	valid := &pb.User{
+		Id:        1,
+		Username:  "alice_admin",
+		Email:     "alice@example.com",
+		Phone:     "+1-555-0100",
+		Tags:      []string{"admin"},
+		Addresses: []*pb.Address{{PostalCode: "SW1A 1AA", Country: "United Kingdom"}},
+	}
+	require.NoError(t, Validate(valid))
+
+	// Partial users, as sent in updates, are valid
+	require.NoError(t, Validate(&pb.User{Id: 1}))
+
+	err := Validate(&pb.User{
+		Username:  "bad name",
+		Email:     "nope",
+		Metadata:  map[string]string{"": "empty key"},
+		Addresses: []*pb.Address{{PostalCode: "!!"}},
+	})
+	verr, ok := FromError(err)
+	require.True(t, ok)
+	fields := map[string]string{}
+	for _, v := range verr.Violations {
+		fields[v.Field] = v.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"username":                 "string.pattern",
+		"email":                    "string.email",
+		`metadata[""]`:             "string.min_len",
+		"addresses[0].postal_code": "string.pattern",
+	}, fields)
+
+	// The server assigns IDs to new users, but updates need one
+	err = Validate(&pb.UpdateUserRequest{User: &pb.User{Username: "alice"}})
+	verr, ok = FromError(err)
+	require.True(t, ok)
+	require.Len(t, verr.Violations, 1)
+	assert.Equal(t, "user", verr.Violations[0].Field)
+	assert.Equal(t, "user.id", verr.Violations[0].Reason)
+}
+
+func TestErrorStatus(t *testing.T) {
+	err := Validate(&pb.GetUserRequest{})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "id: value must be greater than 0", st.Message())
+
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.BadRequest:
+			badRequest = d
+		}
+	}
+	require.NotNil(t, info)
+	assert.Equal(t, ReasonInvalidField, info.Reason)
+	require.NotNil(t, badRequest)
+	assert.Equal(t, "id", badRequest.FieldViolations[0].Field)
+}
@@ -0,0 +1,369 @@
+package webrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Connect content types; unary calls send bare messages, streaming calls
+// send enveloped ones
+const (
+	connectUnaryProto  = "application/proto"
+	connectUnaryJSON   = "application/json"
+	connectStreamProto = "application/connect+proto"
+	connectStreamJSON  = "application/connect+json"
+)
+
+// Connect request headers that aren't metadata
+var connectHeaders = []string{
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Connect-Content-Encoding",
+	"Connect-Accept-Encoding",
+	"Content-Encoding",
+	"Accept-Encoding",
+	"Grpc-Accept-Encoding",
+}
+
+// isConnect reports whether contentType is one the Connect protocol uses
+func isConnect(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(mediaType) {
+	case connectUnaryProto, connectUnaryJSON, connectStreamProto, connectStreamJSON:
+		return true
+	}
+	return false
+}
+
+// serveConnect handles a Connect protocol request
+func (t *Translator) serveConnect(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	streaming := strings.HasPrefix(mediaType, "application/connect+")
+	c := &connectResponder{
+		w:           w,
+		streaming:   streaming,
+		contentType: mediaType,
+	}
+	if strings.HasSuffix(mediaType, "json") {
+		input, output, err := methodTypes(r.URL.Path)
+		if err != nil {
+			c.fail(status.New(codes.Unimplemented, err.Error()))
+			return
+		}
+		c.json = output
+		c.input = input
+	}
+
+	for _, key := range []string{"Content-Encoding", "Connect-Content-Encoding"} {
+		if enc := r.Header.Get(key); enc != "" && enc != "identity" {
+			c.fail(status.Newf(codes.Unimplemented, "unsupported %s %q", strings.ToLower(key), enc))
+			return
+		}
+	}
+
+	var body io.Reader
+	if streaming {
+		if r.ProtoMajor == 1 {
+			_ = http.NewResponseController(w).EnableFullDuplex()
+		}
+		body = &envelopeReader{r: r.Body, input: c.input}
+	} else {
+		msg, err := io.ReadAll(io.LimitReader(r.Body, MaxMessageSize+1))
+		if err != nil {
+			c.fail(status.Newf(codes.Internal, "failed to read request: %v", err))
+			return
+		}
+		if len(msg) > MaxMessageSize {
+			c.fail(status.Newf(codes.ResourceExhausted, "request exceeds the %d byte limit", MaxMessageSize))
+			return
+		}
+		if c.input != nil {
+			if msg, err = jsonToProto(c.input, msg); err != nil {
+				c.fail(status.New(codes.InvalidArgument, err.Error()))
+				return
+			}
+		}
+		body = bytes.NewReader(frame(0, msg))
+	}
+
+	inner := r.Clone(r.Context())
+	if ms := r.Header.Get("Connect-Timeout-Ms"); ms != "" {
+		if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+			c.fail(status.Newf(codes.InvalidArgument, "invalid connect-timeout-ms %q", ms))
+			return
+		}
+		inner.Header.Set("Grpc-Timeout", ms+"m")
+	}
+	for _, key := range connectHeaders {
+		inner.Header.Del(key)
+	}
+	t.invoke(inner, body, c)
+}
+
+// connectResponder writes a gRPC response in the Connect format
+type connectResponder struct {
+	w           http.ResponseWriter
+	streaming   bool
+	contentType string
+	// input and json are the request and response types when the codec is JSON
+	input   protoreflect.MessageType
+	json    protoreflect.MessageType
+	header  http.Header
+	started bool
+	unary   []byte
+}
+
+func (c *connectResponder) start(header http.Header) {
+	c.header = header
+	if c.streaming {
+		c.writeHeader()
+	}
+}
+
+// writeHeader sends the response headers of a successful call or stream
+func (c *connectResponder) writeHeader() {
+	c.started = true
+	copyHeader(c.w, c.header)
+	c.w.Header().Set("Content-Type", c.contentType)
+	c.w.WriteHeader(http.StatusOK)
+}
+
+func (c *connectResponder) message(flags byte, msg []byte) error {
+	if flags&flagCompressed != 0 {
+		return errors.New("compressed response message")
+	}
+	if c.json != nil {
+		var err error
+		if msg, err = protoToJSON(c.json, msg); err != nil {
+			return err
+		}
+	}
+	if !c.streaming {
+		c.unary = msg
+		return nil
+	}
+	if _, err := c.w.Write(frame(0, msg)); err != nil {
+		return err
+	}
+	return http.NewResponseController(c.w).Flush()
+}
+
+func (c *connectResponder) finish(st *status.Status, trailer metadata.MD) {
+	if c.streaming {
+		c.endStream(st, trailer)
+		return
+	}
+	// Unary calls carry trailers as prefixed headers
+	for key, values := range trailer {
+		for _, v := range values {
+			c.w.Header().Add("Trailer-"+key, v)
+		}
+	}
+	if st.Code() != codes.OK {
+		copyHeader(c.w, c.header)
+		c.fail(st)
+		return
+	}
+	c.writeHeader()
+	_, _ = c.w.Write(c.unary)
+}
+
+// endStream writes the end-of-stream message holding the status and trailers
+func (c *connectResponder) endStream(st *status.Status, trailer metadata.MD) {
+	if !c.started {
+		c.writeHeader()
+	}
+	end := struct {
+		Error    *connectError       `json:"error,omitempty"`
+		Metadata map[string][]string `json:"metadata,omitempty"`
+	}{}
+	if st.Code() != codes.OK {
+		end.Error = newConnectError(st)
+	}
+	if len(trailer) > 0 {
+		end.Metadata = trailer
+	}
+	b, _ := json.Marshal(end)
+	_, _ = c.w.Write(frame(flagEndStream, b))
+	_ = http.NewResponseController(c.w).Flush()
+}
+
+// fail reports an error before or instead of a response. Unary errors
+// are JSON with a matching HTTP status; streams end with the error.
+func (c *connectResponder) fail(st *status.Status) {
+	if c.streaming {
+		c.endStream(st, nil)
+		return
+	}
+	b, _ := json.Marshal(newConnectError(st))
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(connectHTTPStatus(st.Code()))
+	_, _ = c.w.Write(b)
+}
+
+// connectError is the Connect protocol's JSON error
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+// connectDetail is an error detail, a google.protobuf.Any in JSON
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newConnectError(st *status.Status) *connectError {
+	e := &connectError{Code: connectCode(st.Code()), Message: st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		name := detail.GetTypeUrl()
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		e.Details = append(e.Details, connectDetail{
+			Type:  name,
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+	return e
+}
+
+// connectCode names a code the way Connect does, e.g. "not_found"
+func connectCode(code codes.Code) string {
+	if code == codes.Canceled {
+		return "canceled"
+	}
+	var b strings.Builder
+	for i, r := range code.String() {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// connectHTTPStatus is the HTTP status of a unary error, per the Connect spec
+func connectHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// envelopeReader turns a Connect request stream into gRPC frames,
+// converting JSON messages to the binary format
+type envelopeReader struct {
+	r     io.Reader
+	input protoreflect.MessageType
+	buf   []byte
+}
+
+// Read implements io.Reader
+func (e *envelopeReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		flags, msg, err := readFrame(e.r)
+		if err != nil {
+			return 0, err
+		}
+		if flags&flagCompressed != 0 {
+			return 0, errors.New("compressed request messages aren't supported")
+		}
+		if e.input != nil {
+			if msg, err = jsonToProto(e.input, msg); err != nil {
+				return 0, err
+			}
+		}
+		e.buf = frame(0, msg)
+	}
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	return n, nil
+}
+
+// methodTypes looks up the request and response types of a method path
+func methodTypes(path string) (input, output protoreflect.MessageType, err error) {
+	name := strings.Replace(strings.TrimPrefix(path, "/"), "/", ".", 1)
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown method %s", path)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown method %s", path)
+	}
+	if input, err = protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName()); err != nil {
+		return nil, nil, err
+	}
+	if output, err = protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName()); err != nil {
+		return nil, nil, err
+	}
+	return input, output, nil
+}
+
+func jsonToProto(mt protoreflect.MessageType, b []byte) ([]byte, error) {
+	msg := mt.New().Interface()
+	if len(bytes.TrimSpace(b)) > 0 {
+		if err := protojson.Unmarshal(b, msg); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", mt.Descriptor().FullName(), err)
+		}
+	}
+	return proto.Marshal(msg)
+}
+
+func protoToJSON(mt protoreflect.MessageType, b []byte) ([]byte, error) {
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(msg)
+}
+
+// statusDetailsBin encodes a status for the grpc-status-details-bin trailer
+func statusDetailsBin(st *status.Status) (string, error) {
+	b, err := proto.Marshal(st.Proto())
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,80 @@
+package webrpc
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Headers browsers may send on cross-origin calls: REST, gRPC-Web and Connect
+var corsAllowHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Grpc-Timeout",
+	"X-Grpc-Web",
+	"X-User-Agent",
+	"X-Request-Id",
+	"Idempotency-Key",
+}
+
+// Headers scripts may read from cross-origin responses
+var corsExposeHeaders = []string{
+	"Grpc-Status",
+	"Grpc-Message",
+	"Grpc-Status-Details-Bin",
+	"X-Request-Id",
+	"Zstd-Dictionaries",
+	"Idempotency-Replayed",
+	"Grpc-Metadata-Idempotency-Replayed",
+}
+
+// CORSConfig configures cross-origin access
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API; "*" allows any
+	AllowedOrigins []string
+	// MaxAge is how long, in seconds, browsers may cache a preflight response
+	MaxAge int
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins. Credentials (cookies) are never allowed; clients
+// authenticate with an Authorization header instead.
+func CORS(cfg CORSConfig, next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	allowHeaders := strings.Join(corsAllowHeaders, ", ")
+	exposeHeaders := strings.Join(corsExposeHeaders, ", ")
+	maxAge := "7200"
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,167 @@
+package webrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// serveGRPCWeb handles a gRPC-Web request. The binary format frames
+// messages exactly like gRPC; the text format is the same base64 encoded.
+// Trailers arrive in a final frame flagged 0x80 rather than as HTTP trailers.
+func (t *Translator) serveGRPCWeb(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if subtype := contentSubtype(contentType); subtype != "" && subtype != "proto" {
+		http.Error(w, fmt.Sprintf("unsupported gRPC-Web content type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "gRPC-Web requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.ProtoMajor == 1 {
+		// Let half-duplex clients keep sending while responses stream back
+		_ = http.NewResponseController(w).EnableFullDuplex()
+	}
+
+	var body io.Reader = r.Body
+	responseType := "application/grpc-web+proto"
+	if text {
+		body = newBase64Reader(r.Body)
+		responseType = "application/grpc-web-text+proto"
+	}
+	t.invoke(r, body, &grpcWebResponder{w: w, text: text, contentType: responseType})
+}
+
+// contentSubtype returns the codec after '+' in a content type, if any
+func contentSubtype(contentType string) string {
+	_, subtype, _ := strings.Cut(contentType, "+")
+	subtype, _, _ = strings.Cut(subtype, ";")
+	return strings.TrimSpace(subtype)
+}
+
+// grpcWebResponder writes a gRPC response in the gRPC-Web format
+type grpcWebResponder struct {
+	w           http.ResponseWriter
+	text        bool
+	contentType string
+	started     bool
+}
+
+func (g *grpcWebResponder) start(header http.Header) {
+	g.started = true
+	copyHeader(g.w, header)
+	g.w.Header().Set("Content-Type", g.contentType)
+	g.w.WriteHeader(http.StatusOK)
+}
+
+func (g *grpcWebResponder) message(flags byte, msg []byte) error {
+	return g.write(frame(flags, msg))
+}
+
+func (g *grpcWebResponder) finish(st *status.Status, trailer metadata.MD) {
+	if !g.started {
+		g.start(nil)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "grpc-status: %d\r\n", st.Code())
+	if msg := st.Message(); msg != "" {
+		fmt.Fprintf(&b, "grpc-message: %s\r\n", encodeMessage(msg))
+	}
+	if details := st.Proto().GetDetails(); len(details) > 0 {
+		if bin, err := statusDetailsBin(st); err == nil {
+			fmt.Fprintf(&b, "grpc-status-details-bin: %s\r\n", bin)
+		}
+	}
+	for key, values := range trailer {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, v)
+		}
+	}
+	_ = g.write(frame(flagTrailer, b.Bytes()))
+}
+
+// write sends a frame, base64 encoded in the text format, and flushes it
+// so streamed messages reach the client immediately
+func (g *grpcWebResponder) write(b []byte) error {
+	if g.text {
+		b = []byte(base64.StdEncoding.EncodeToString(b))
+	}
+	if _, err := g.w.Write(b); err != nil {
+		return err
+	}
+	return http.NewResponseController(g.w).Flush()
+}
+
+// encodeMessage percent-encodes a status message as gRPC does, so it is
+// safe in a header line
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// base64Reader decodes gRPC-Web text bodies. Clients may send several
+// independently padded base64 chunks, which a streaming decoder rejects,
+// so it decodes one 4 character quantum at a time.
+type base64Reader struct {
+	r       io.Reader
+	quantum []byte
+	out     []byte
+	err     error
+}
+
+func newBase64Reader(r io.Reader) *base64Reader {
+	return &base64Reader{r: r}
+}
+
+// Read implements io.Reader
+func (b *base64Reader) Read(p []byte) (int, error) {
+	for len(b.out) == 0 && b.err == nil {
+		var buf [512]byte
+		n, err := b.r.Read(buf[:])
+		for _, c := range buf[:n] {
+			if c == '\r' || c == '\n' || c == ' ' {
+				continue
+			}
+			b.quantum = append(b.quantum, c)
+			if len(b.quantum) == 4 {
+				var decoded [3]byte
+				dn, derr := base64.StdEncoding.Decode(decoded[:], b.quantum)
+				if derr != nil {
+					b.err = fmt.Errorf("invalid base64 body: %w", derr)
+					break
+				}
+				b.out = append(b.out, decoded[:dn]...)
+				b.quantum = b.quantum[:0]
+			}
+		}
+		if err != nil && b.err == nil {
+			if err == io.EOF && len(b.quantum) != 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			b.err = err
+		}
+	}
+	if len(b.out) > 0 {
+		n := copy(p, b.out)
+		b.out = b.out[n:]
+		return n, nil
+	}
+	return 0, b.err
+}
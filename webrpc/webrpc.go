@@ -0,0 +1,293 @@
+// Package webrpc serves gRPC-Web and Connect clients, such as browsers, from
+// a grpc.Server. Each request is translated into a gRPC call handled
+// in-process through grpc.Server.ServeHTTP, so the server's interceptors
+// (auth, logging, metrics) apply as they do to native gRPC clients.
+//
+// Browsers can't stream request bodies, so client and bidirectional
+// streaming calls are half-duplex: all request messages are sent, then the
+// responses are read. Server streaming works over HTTP/1.1 and HTTP/2.
+package webrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+// MaxMessageSize limits a buffered request message, matching gRPC's default
+const MaxMessageSize = 4 << 20
+
+// Frame flags shared by gRPC, gRPC-Web and Connect envelopes
+const (
+	flagCompressed = 0x01
+	flagEndStream  = 0x02 // Connect end-of-stream message
+	flagTrailer    = 0x80 // gRPC-Web trailers
+)
+
+// Translator routes gRPC-Web and Connect requests to a grpc.Server
+type Translator struct {
+	server *grpc.Server
+}
+
+// New creates a Translator for the services registered on server
+func New(server *grpc.Server) *Translator {
+	return &Translator{server: server}
+}
+
+// Wrap serves gRPC-Web and Connect requests and passes everything else to next
+func (t *Translator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case IsGRPCWeb(contentType):
+			t.serveGRPCWeb(w, r)
+		case r.Method == http.MethodPost && isConnect(contentType) && t.isMethod(r.URL.Path):
+			t.serveConnect(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// IsGRPCWeb reports whether contentType is a gRPC-Web request
+func IsGRPCWeb(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+// isMethod reports whether path names a method registered on the server
+func (t *Translator) isMethod(path string) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return false
+	}
+	info, ok := t.server.GetServiceInfo()[service]
+	if !ok {
+		return false
+	}
+	for _, m := range info.Methods {
+		if m.Name == method {
+			return true
+		}
+	}
+	return false
+}
+
+// responder writes a gRPC response back in a web protocol
+type responder interface {
+	// start receives the response headers, before any message
+	start(header http.Header)
+	// message receives each response message
+	message(flags byte, msg []byte) error
+	// finish receives the final status and trailers
+	finish(st *status.Status, trailer metadata.MD)
+}
+
+// invoke runs r as a gRPC call with body as its framed request messages
+// and relays the response to resp
+func (t *Translator) invoke(r *http.Request, body io.Reader, resp responder) {
+	inner := r.Clone(r.Context())
+	inner.Method = http.MethodPost
+	inner.Proto, inner.ProtoMajor, inner.ProtoMinor = "HTTP/2.0", 2, 0
+	inner.Body = io.NopCloser(body)
+	inner.ContentLength = -1
+	inner.Header.Set("Content-Type", "application/grpc+proto")
+	inner.Header.Del("Content-Length")
+	if id := requestid.FromContext(r.Context()); id != "" {
+		inner.Header.Set(requestid.Header, id)
+	}
+
+	rw := &grpcResponseWriter{header: make(http.Header), resp: resp}
+	t.server.ServeHTTP(rw, inner)
+	st, trailer := rw.result()
+	resp.finish(st, trailer)
+}
+
+// grpcResponseWriter receives the gRPC server's HTTP/2 response and hands
+// each message to a responder as soon as it's complete
+type grpcResponseWriter struct {
+	header  http.Header
+	resp    responder
+	code    int
+	started bool
+	buf     []byte
+	errBody bytes.Buffer
+	err     error
+}
+
+// Header implements http.ResponseWriter
+func (w *grpcResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *grpcResponseWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	if code != http.StatusOK {
+		// The transport rejected the request before it became a gRPC call
+		return
+	}
+	w.started = true
+	w.resp.start(responseHeader(w.header))
+}
+
+// Write implements http.ResponseWriter, splitting the body into messages
+func (w *grpcResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.started {
+		return w.errBody.Write(p)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= 5 {
+		size := int(binary.BigEndian.Uint32(w.buf[1:5]))
+		if len(w.buf) < 5+size {
+			break
+		}
+		flags := w.buf[0]
+		msg := bytes.Clone(w.buf[5 : 5+size])
+		w.buf = w.buf[5+size:]
+		if err := w.resp.message(flags, msg); err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher; responders flush as they write
+func (w *grpcResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// result returns the call's status and trailers once the server is done
+func (w *grpcResponseWriter) result() (*status.Status, metadata.MD) {
+	if w.err != nil {
+		return status.New(codes.Internal, w.err.Error()), nil
+	}
+	if w.code != 0 && w.code != http.StatusOK {
+		return status.New(httpStatusCode(w.code), strings.TrimSpace(w.errBody.String())), nil
+	}
+
+	trailer := metadata.MD{}
+	for key, values := range w.header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			trailer.Append(strings.ToLower(name), values...)
+		}
+	}
+
+	code, err := strconv.Atoi(w.header.Get("Grpc-Status"))
+	if err != nil {
+		return status.New(codes.Internal, "missing grpc-status"), trailer
+	}
+	msg, _ := url.PathUnescape(w.header.Get("Grpc-Message"))
+	if bin := w.header.Get("Grpc-Status-Details-Bin"); bin != "" {
+		if b, err := decodeBinary(bin); err == nil {
+			var pb spb.Status
+			if proto.Unmarshal(b, &pb) == nil {
+				return status.FromProto(&pb), trailer
+			}
+		}
+	}
+	return status.New(codes.Code(code), msg), trailer
+}
+
+// responseHeader copies the headers a client should see, leaving out the
+// ones that describe the gRPC transport
+func responseHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for key, values := range h {
+		switch key {
+		case "Trailer", "Content-Type", "Date", "Grpc-Encoding", "Grpc-Accept-Encoding":
+			continue
+		}
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			continue
+		}
+		out[key] = values
+	}
+	return out
+}
+
+// copyHeader sets h on w, replacing values middleware may already have set
+func copyHeader(w http.ResponseWriter, h http.Header) {
+	for key, values := range h {
+		w.Header()[key] = values
+	}
+}
+
+// httpStatusCode maps an HTTP error from the gRPC transport to a code
+func httpStatusCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// frame encodes msg as a length-prefixed message
+func frame(flags byte, msg []byte) []byte {
+	out := make([]byte, 5+len(msg))
+	out[0] = flags
+	binary.BigEndian.PutUint32(out[1:5], uint32(len(msg)))
+	copy(out[5:], msg)
+	return out
+}
+
+// readFrame reads one length-prefixed message, returning io.EOF at a clean end
+func readFrame(r io.Reader) (flags byte, msg []byte, err error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated message prefix: %w", err)
+		}
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > MaxMessageSize {
+		return 0, nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", size, MaxMessageSize)
+	}
+	msg = make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return 0, nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return prefix[0], msg, nil
+}
+
+// decodeBinary decodes a -bin metadata value, which may or may not be padded
+func decodeBinary(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
@@ -0,0 +1,161 @@
+package webrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/server"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	grpcServer := grpc.NewServer()
+	pb.RegisterUserServiceServer(grpcServer, server.New(server.NewMemoryStorage()))
+	ts := httptest.NewServer(New(grpcServer).Wrap(http.NotFoundHandler()))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func post(t *testing.T, url, contentType string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readFrames splits a gRPC-Web body into its messages and trailer block
+func readFrames(t *testing.T, body io.Reader) (msgs [][]byte, trailer string) {
+	t.Helper()
+	for {
+		flags, msg, err := readFrame(body)
+		if err == io.EOF {
+			return msgs, trailer
+		}
+		require.NoError(t, err)
+		if flags&flagTrailer != 0 {
+			trailer = string(msg)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func addUser(t *testing.T, ts *httptest.Server, user *pb.User) {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{"id": user.Id, "username": user.Username, "role": user.Role.String()})
+	require.NoError(t, err)
+	resp := post(t, ts.URL+"/proto.UserService/AddUser", "application/json", b)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGRPCWeb(t *testing.T) {
+	ts := newTestServer(t)
+	addUser(t, ts, &pb.User{Id: 1, Username: "alice", Role: pb.Role_ADMIN})
+	addUser(t, ts, &pb.User{Id: 2, Username: "bob", Role: pb.Role_MEMBER})
+
+	req, err := proto.Marshal(&pb.ListUsersRequest{})
+	require.NoError(t, err)
+	resp := post(t, ts.URL+"/proto.UserService/ListUsers", "application/grpc-web+proto", frame(0, req))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc-web+proto", resp.Header.Get("Content-Type"))
+
+	msgs, trailer := readFrames(t, resp.Body)
+	var names []string
+	for _, msg := range msgs {
+		var user pb.User
+		require.NoError(t, proto.Unmarshal(msg, &user))
+		names = append(names, user.Username)
+	}
+	assert.ElementsMatch(t, []string{"alice", "bob"}, names)
+	assert.Contains(t, trailer, "grpc-status: 0\r\n")
+
+	// The text format base64 encodes each frame
+	body := base64.StdEncoding.EncodeToString(frame(0, mustMarshal(t, &pb.GetUserRequest{Id: 42})))
+	resp = post(t, ts.URL+"/proto.UserService/GetUser", "application/grpc-web-text", []byte(body))
+	raw, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, resp.Body))
+	require.NoError(t, err)
+	msgs, trailer = readFrames(t, bytes.NewReader(raw))
+	assert.Empty(t, msgs)
+	assert.Contains(t, trailer, "grpc-status: 5\r\n")
+}
+
+func TestConnect(t *testing.T) {
+	ts := newTestServer(t)
+	addUser(t, ts, &pb.User{Id: 1, Username: "alice", Role: pb.Role_ADMIN})
+
+	resp := post(t, ts.URL+"/proto.UserService/GetUser", "application/json", []byte(`{"id": 1}`))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, "alice", got["username"])
+
+	resp = post(t, ts.URL+"/proto.UserService/GetUser", "application/json", []byte(`{"id": 99}`))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var connectErr connectError
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&connectErr))
+	assert.Equal(t, "not_found", connectErr.Code)
+
+	// Server streaming with enveloped JSON messages
+	resp = post(t, ts.URL+"/proto.UserService/ListUsers", "application/connect+json", frame(0, []byte(`{}`)))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	flags, msg, err := readFrame(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), flags)
+	assert.Contains(t, string(msg), `"username":"alice"`)
+	flags, msg, err = readFrame(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, byte(flagEndStream), flags)
+	assert.JSONEq(t, `{}`, string(msg))
+}
+
+func TestRouting(t *testing.T) {
+	ts := newTestServer(t)
+	// REST-style JSON requests that aren't for a gRPC method fall through
+	resp := post(t, ts.URL+"/api/v1/users", "application/json", []byte(`{}`))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS(CORSConfig{AllowedOrigins: []string{"https://ux.example"}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/proto.UserService/ListUsers", nil)
+	req.Header.Set("Origin", "https://ux.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://ux.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.True(t, strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "X-Grpc-Web"))
+
+	req = httptest.NewRequest(http.MethodPost, "/proto.UserService/ListUsers", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	require.NoError(t, err)
+	return b
+}
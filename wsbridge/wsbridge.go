@@ -0,0 +1,292 @@
+// Package wsbridge exposes bidirectional streaming RPCs to WebSocket
+// clients. Each text frame from the client is a request message in
+// protojson; each response message is sent back as a text frame.
+//
+// An empty text frame ends the request stream (CloseSend) while responses
+// keep flowing. When the RPC ends the socket is closed with a code derived
+// from its gRPC status, see CloseCode.
+package wsbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/paulstuart/grpc-example/logging"
+	"github.com/paulstuart/grpc-example/requestid"
+)
+
+const loggerName = "wsbridge"
+
+// Defaults for Config
+const (
+	DefaultWriteTimeout   = 10 * time.Second
+	DefaultMaxMessageSize = 1 << 20
+)
+
+// BearerSubprotocol lets browsers, which can't set headers on a WebSocket
+// handshake, pass a token as the subprotocols "bearer, <token>"
+const BearerSubprotocol = "bearer"
+
+// CloseStatusBase is added to a gRPC code to form the close code of a
+// failed RPC, e.g. 4005 for NOT_FOUND. 4000-4999 is reserved for
+// applications by RFC 6455.
+const CloseStatusBase = 4000
+
+// Config configures a Bridge
+type Config struct {
+	// Authorize validates the bearer token presented at upgrade time; nil
+	// accepts any request. The token is forwarded to the RPC either way.
+	Authorize func(token string) error
+	// OriginPatterns lists the hosts of other origins allowed to connect
+	OriginPatterns []string
+	// WriteTimeout bounds sending one message to a slow client
+	WriteTimeout time.Duration
+	// MaxMessageSize limits a client message; larger ones close the socket
+	MaxMessageSize int64
+}
+
+// Bridge serves one bidirectional streaming method over WebSockets
+type Bridge struct {
+	conn     grpc.ClientConnInterface
+	method   string
+	request  protoreflect.MessageType
+	response protoreflect.MessageType
+	cfg      Config
+}
+
+// New creates a Bridge for the bidirectional streaming method fullMethod
+// (e.g. "/proto.UserService/SyncUsers") called over conn
+func New(conn grpc.ClientConnInterface, fullMethod string, cfg Config) (*Bridge, error) {
+	name := strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("unknown method %s: %w", fullMethod, err)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok || !method.IsStreamingClient() || !method.IsStreamingServer() {
+		return nil, fmt.Errorf("%s is not a bidirectional streaming method", fullMethod)
+	}
+	request, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+	if err != nil {
+		return nil, err
+	}
+	response, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = DefaultMaxMessageSize
+	}
+	return &Bridge{conn: conn, method: fullMethod, request: request, response: response, cfg: cfg}, nil
+}
+
+// CloseCode maps the final status of an RPC to a WebSocket close code
+func CloseCode(st *status.Status) websocket.StatusCode {
+	if st.Code() == codes.OK {
+		return websocket.StatusNormalClosure
+	}
+	return websocket.StatusCode(CloseStatusBase + int(st.Code()))
+}
+
+// ServeHTTP upgrades the request and bridges it to a new stream
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.For(loggerName)
+
+	token, viaSubprotocol := bearerToken(r)
+	if b.cfg.Authorize != nil {
+		if token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if err := b.cfg.Authorize(token); err != nil {
+			logger.WarnContext(r.Context(), "unauthorized websocket upgrade", "method", b.method, "error", err)
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	opts := &websocket.AcceptOptions{OriginPatterns: b.cfg.OriginPatterns}
+	if viaSubprotocol {
+		opts.Subprotocols = []string{BearerSubprotocol}
+	}
+	ws, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		// Accept has already written the error response
+		logger.DebugContext(r.Context(), "websocket upgrade failed", "method", b.method, "error", err)
+		return
+	}
+	ws.SetReadLimit(b.cfg.MaxMessageSize)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	md := metadata.MD{}
+	if token != "" {
+		md.Set("authorization", "Bearer "+token)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		md.Set(requestid.Header, id)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	s := &session{ws: ws, bridge: b}
+	stream, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, b.method)
+	if err != nil {
+		s.closeStatus(status.Convert(err))
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if s.forwardRequests(ctx, stream) {
+			// The client broke the protocol or went away, abandon the RPC
+			cancel()
+		}
+	}()
+	s.forwardResponses(ctx, stream)
+	cancel()
+	<-done
+
+	logger.DebugContext(r.Context(), "websocket stream finished", "method", b.method,
+		"sent", s.sent, "received", s.received)
+}
+
+// bearerToken returns the token from the Authorization header or the
+// "bearer, <token>" subprotocols, reporting whether it came from the latter
+func bearerToken(r *http.Request) (token string, viaSubprotocol bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token, false
+	}
+	var protocols []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	if len(protocols) == 2 && protocols[0] == BearerSubprotocol {
+		return protocols[1], true
+	}
+	return "", false
+}
+
+// session is one bridged connection
+type session struct {
+	ws       *websocket.Conn
+	bridge   *Bridge
+	once     sync.Once
+	sent     int
+	received int
+}
+
+// close closes the socket once; the first reason wins
+func (s *session) close(code websocket.StatusCode, reason string) {
+	s.once.Do(func() {
+		// Close reasons are limited to 123 bytes
+		if len(reason) > 123 {
+			reason = reason[:120] + "..."
+		}
+		_ = s.ws.Close(code, reason)
+	})
+}
+
+func (s *session) closeStatus(st *status.Status) {
+	s.close(CloseCode(st), st.Message())
+}
+
+// forwardRequests sends each client message on the stream. Sending blocks
+// under gRPC flow control, which stops reading from the socket and so
+// pushes back on the client. It reports whether the RPC should be abandoned.
+func (s *session) forwardRequests(ctx context.Context, stream grpc.ClientStream) bool {
+	halfClosed := false
+	for {
+		typ, data, err := s.ws.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure && !halfClosed {
+				_ = stream.CloseSend()
+				return false
+			}
+			return !halfClosed
+		}
+
+		switch {
+		case typ != websocket.MessageText:
+			s.close(websocket.StatusUnsupportedData, "messages must be JSON text frames")
+			return true
+		case len(data) == 0:
+			halfClosed = true
+			if err := stream.CloseSend(); err != nil {
+				return true
+			}
+			continue
+		case halfClosed:
+			s.close(websocket.StatusPolicyViolation, "message after end of requests")
+			return true
+		}
+
+		msg := s.bridge.request.New().Interface()
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			s.close(websocket.StatusInvalidFramePayloadData, fmt.Sprintf("invalid %s: %v", s.bridge.request.Descriptor().FullName(), err))
+			return true
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			// The stream failed; its status is reported by forwardResponses
+			return false
+		}
+		s.received++
+	}
+}
+
+// forwardResponses writes each response to the client until the stream
+// ends, then closes the socket with the stream's status
+func (s *session) forwardResponses(ctx context.Context, stream grpc.ClientStream) {
+	for {
+		msg := s.bridge.response.New().Interface()
+		if err := stream.RecvMsg(msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				s.close(websocket.StatusNormalClosure, "")
+			} else {
+				s.closeStatus(status.Convert(err))
+			}
+			return
+		}
+		if err := s.write(ctx, msg); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				s.close(websocket.StatusPolicyViolation, "client too slow to read responses")
+			}
+			return
+		}
+		s.sent++
+	}
+}
+
+// write sends one response, giving up on a client that doesn't read it in time
+func (s *session) write(ctx context.Context, msg proto.Message) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.bridge.cfg.WriteTimeout)
+	defer cancel()
+	return s.ws.Write(ctx, websocket.MessageText, data)
+}
@@ -0,0 +1,120 @@
+package wsbridge
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/paulstuart/grpc-example/proto/pkg"
+	"github.com/paulstuart/grpc-example/server"
+)
+
+func newBridge(t *testing.T, method string, cfg Config) string {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	storage := server.NewMemoryStorage()
+	require.NoError(t, storage.AddUser(context.Background(), &pb.User{Id: 1, Username: "alice", Role: pb.Role_ADMIN}))
+	pb.RegisterUserServiceServer(grpcServer, server.New(storage))
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	bridge, err := New(conn, method, cfg)
+	require.NoError(t, err)
+	ts := httptest.NewServer(bridge)
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func TestActivityStream(t *testing.T) {
+	url := newBridge(t, "/proto.UserService/UserActivityStream", Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer ws.CloseNow()
+
+	require.NoError(t, ws.Write(ctx, websocket.MessageText, []byte(`{"userId": 1, "activityType": "VIEW_PAGE"}`)))
+	typ, data, err := ws.Read(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, websocket.MessageText, typ)
+	assert.Contains(t, string(data), `"acknowledged":true`)
+
+	// An empty frame ends the requests; the server then finishes the RPC
+	require.NoError(t, ws.Write(ctx, websocket.MessageText, nil))
+	_, _, err = ws.Read(ctx)
+	assert.Equal(t, websocket.StatusNormalClosure, websocket.CloseStatus(err))
+}
+
+func TestInvalidMessage(t *testing.T) {
+	url := newBridge(t, "/proto.UserService/SyncUsers", Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer ws.CloseNow()
+
+	require.NoError(t, ws.Write(ctx, websocket.MessageText, []byte(`{"bogus": true}`)))
+	_, _, err = ws.Read(ctx)
+	assert.Equal(t, websocket.StatusInvalidFramePayloadData, websocket.CloseStatus(err))
+}
+
+func TestAuthorize(t *testing.T) {
+	url := newBridge(t, "/proto.UserService/SyncUsers", Config{
+		Authorize: func(token string) error {
+			if token != "good" {
+				return errors.New("bad token")
+			}
+			return nil
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, resp, err := websocket.Dial(ctx, url, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ws, resp, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{BearerSubprotocol, "good"}})
+	require.NoError(t, err)
+	defer ws.CloseNow()
+	assert.Equal(t, BearerSubprotocol, resp.Header.Get("Sec-WebSocket-Protocol"))
+}
+
+func TestCloseCode(t *testing.T) {
+	assert.Equal(t, websocket.StatusNormalClosure, CloseCode(statusOf(codes.OK)))
+	assert.Equal(t, websocket.StatusCode(4005), CloseCode(statusOf(codes.NotFound)))
+	assert.Equal(t, websocket.StatusCode(4016), CloseCode(statusOf(codes.Unauthenticated)))
+}
+
+func TestNewRejectsUnaryMethods(t *testing.T) {
+	_, err := New(nil, "/proto.UserService/GetUser", Config{})
+	assert.Error(t, err)
+}
+
+func statusOf(code codes.Code) *status.Status {
+	return status.New(code, code.String())
+}